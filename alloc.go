@@ -1,6 +1,7 @@
 package goumem
 
 import (
+	"context"
 	"github.com/exapsy/goumem/allocator"
 	"reflect"
 )
@@ -15,12 +16,16 @@ func Alloc(t interface{}) (*allocator.AllocatedBlock, error) {
 	return b, nil
 }
 
-func Free(block *allocator.AllocatedBlock) error {
-	return mem.Free(block)
+// AllocTagged allocates size bytes tagged with tag, see [allocator.RegisterTag].
+func AllocTagged(size uintptr, tag allocator.Tag) (*allocator.AllocatedBlock, error) {
+	return allocator.AllocTagged(mem, size, tag)
 }
 
-func init() {
-	if mem == nil {
-		mem = allocator.Default()
-	}
+// AllocContext allocates size bytes tagged with the tag ctx carries, see [allocator.ContextWithTag].
+func AllocContext(ctx context.Context, size uintptr) (*allocator.AllocatedBlock, error) {
+	return allocator.AllocContext(ctx, mem, size)
+}
+
+func Free(block *allocator.AllocatedBlock) error {
+	return mem.Free(block)
 }
@@ -1,6 +1,7 @@
 package goumem
 
 import (
+	"context"
 	"github.com/exapsy/goumem/allocator"
 	"github.com/stretchr/testify/suite"
 	"testing"
@@ -52,6 +53,28 @@ func (s *TestAllocSuite) TestAlloc() {
 	}
 }
 
+func (s *TestAllocSuite) TestAllocTagged() {
+	tag := allocator.RegisterTag("alloc test")
+
+	tagged, err := AllocTagged(16, tag)
+	if err != nil {
+		s.FailNow("Failed to allocate block")
+	}
+	s.Equal(tag, tagged.Tag())
+
+	fromContext, err := AllocContext(allocator.ContextWithTag(context.Background(), tag), 16)
+	if err != nil {
+		s.FailNow("Failed to allocate block")
+	}
+	s.Equal(tag, fromContext.Tag())
+
+	stats, _ := allocator.ReadStats(mem)
+	s.Equal(uint64(2), stats.Tags[tag].LiveBlocks)
+
+	s.NoError(Free(tagged))
+	s.NoError(Free(fromContext))
+}
+
 func TestAlloc(t *testing.T) {
 	suite.Run(t, new(TestAllocSuite))
 }
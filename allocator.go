@@ -9,3 +9,14 @@ var (
 func SetMemoryAllocator(m allocator.MemoryAllocator) {
 	mem = m
 }
+
+// GetMemoryAllocator returns the memory allocator configured with [SetMemoryAllocator].
+func GetMemoryAllocator() allocator.MemoryAllocator {
+	return mem
+}
+
+// NewChildAllocator carves a child allocator with its own quota
+// out of the global memory allocator.
+func NewChildAllocator(quota uintptr) *allocator.ChildAllocator {
+	return allocator.NewChildAllocator(mem, quota)
+}
@@ -13,11 +13,6 @@ var (
 	allocThresholdWithoutAllocatingAnotherChunk uintptr            = PageSize / 2
 )
 
-var (
-	ErrAllocatedBlockAlreadyFreed  = fmt.Errorf("goumem: allocated block already freed")
-	ErrAllocatedBlockDifferentSize = fmt.Errorf("goumem: allocated block different size")
-)
-
 type (
 	MemoryAllocator interface {
 		Alloc(size uintptr) (*AllocatedBlock, error)
@@ -25,40 +20,65 @@ type (
 		Copy(dst, src *AllocatedBlock) error
 	}
 	allocationStrategy interface {
-		alloc(chunks *chunkList, size uintptr) (*AllocatedBlock, error)
+		alloc(chunks *chunkList, size uintptr, tag Tag) (*AllocatedBlock, error)
 		free(chunks *chunkList, block *AllocatedBlock) error
 	}
 	allocationPolicy interface {
-		SelectChunk(chunks *chunkList, size uintptr) *chunk
+		SelectChunk(chunks *chunkList, size uintptr) (*chunk, error)
 	}
 	chunkList struct {
-		chunks *chunk
-		len    int
+		chunks   *chunk
+		len      int
+		budget   *memoryBudget
+		sys      memsyscall.Syscall
+		pageSize uintptr
+		// mapFlags are requested for every new chunk, if sys is a [memsyscall.Mapper].
+		mapFlags     memsyscall.MapFlags
+		hugePageSize uintptr
+		// observers are notified of the chunks mapped and unmapped, nil if nobody can observe the list.
+		observers *observers
+		log       logger
+		// reserved is sys when it is a [memsyscall.Reserved], its chunks are then indexed by page in byPage.
+		reserved *memsyscall.Reserved
+		byPage   []*chunk
 	}
 	chunk struct {
-		size   atomic.Uintptr
-		addr   uintptr
+		// list is the list the chunk was mapped by, to tell the blocks of an allocator from foreign ones.
+		list *chunkList
+		size atomic.Uintptr
+		addr uintptr
+		// blocks are sorted by address and cover the whole chunk.
 		blocks []*chunkBlock
 		// freeBytes is meta-data for the total of free bytes left in the memory.
 		freeBytes atomic.Uintptr
-		next      *chunk
-		prev      *chunk
+		// scavengedBytes is the free memory of the chunk handed back to the system.
+		scavengedBytes uintptr
+		// mapFlags are the flags the chunk was actually mapped with.
+		mapFlags memsyscall.MapFlags
+		next     *chunk
+		prev     *chunk
 	}
 	chunkBlock struct {
-		addr     atomic.Uintptr
-		size     atomic.Uintptr
-		isFree   atomic.Bool
-		next     *chunkBlock
-		prev     *chunkBlock
-		nextFree *chunkBlock
-		prevFree *chunkBlock
+		addr   atomic.Uintptr
+		size   atomic.Uintptr
+		isFree atomic.Bool
+		// scavenged is the amount of bytes of a free block handed back to the system.
+		scavenged uintptr
+		// allocated is the block handed out in the chunk block, nil while it is free.
+		allocated *AllocatedBlock
+		next      *chunkBlock
+		prev      *chunkBlock
 	}
 	AllocatedBlock struct {
 		size          uintptr
 		addr          uintptr
 		chunk         *chunk
 		chunkBlockMem *chunkBlock
-		flags         AllocatedBlockFlags
+		// flags are atomic, so that IsFreed can be called without the lock of the allocator.
+		flags atomic.Uintptr
+		// allocated is the monotonic time of the allocation, only kept with [WithHistograms].
+		allocated int64
+		tag       Tag
 	}
 	AllocatedBlockFlags uintptr
 )
@@ -68,98 +88,224 @@ const (
 	AllocatedBlockFlagsFree AllocatedBlockFlags = 1 << iota
 )
 
-func newChunkList() *chunkList {
-	list := &chunkList{
-		chunks: nil,
-		len:    1,
+// blockAlignment is the alignment of every block handed out by the chunks,
+// big enough for any Go type to be stored at the start of a block.
+const blockAlignment uintptr = 16
+
+func alignUp(n, alignment uintptr) uintptr {
+	return (n + alignment - 1) &^ (alignment - 1)
+}
+
+func alignDown(n, alignment uintptr) uintptr {
+	return n &^ (alignment - 1)
+}
+
+// blockSize is the size of the chunk block that holds a requested size.
+func blockSize(size uintptr) uintptr {
+	if size == 0 {
+		return blockAlignment
 	}
 
-	newChunk, err := newChunk(PageSize, nil, nil)
-	if err != nil {
-		panic(err)
+	return alignUp(size, blockAlignment)
+}
+
+func newChunkList(sys memsyscall.Syscall, budget *memoryBudget, mapFlags memsyscall.MapFlags, log logger) *chunkList {
+	list := &chunkList{
+		chunks:   nil,
+		budget:   budget,
+		sys:      sys,
+		pageSize: sys.PageSize(),
+		mapFlags: mapFlags,
+		log:      log,
 	}
 
-	list.chunks = newChunk
+	if mapper, ok := sys.(memsyscall.Mapper); ok {
+		list.hugePageSize = mapper.HugePageSize()
+	}
+	list.reserved, _ = sys.(*memsyscall.Reserved)
 
 	return list
 }
 
-func (cl *chunkList) alloc(size uintptr) (block *AllocatedBlock, chunkIndex int, blockIndex int, err error) {
-	head := cl.chunks
-	for head != nil {
-		var addr uintptr
-		addr, blockIndex, err = head.getFreeAddrForSize(size)
-		if err != nil {
-			return nil, 0, 0, err
+// chunkAt returns the chunk of the list addr belongs to, nil if there is none.
+// With a reserved range it is an index into byPage, otherwise the chunks are walked.
+func (cl *chunkList) chunkAt(addr uintptr) *chunk {
+	if cl.reserved != nil {
+		offset, ok := cl.reserved.Offset(addr)
+		if !ok || offset/cl.pageSize >= uintptr(len(cl.byPage)) {
+			return nil
 		}
 
-		return &AllocatedBlock{
-			addr:          addr,
-			size:          size,
-			chunk:         head,
-			chunkBlockMem: head.blocks[blockIndex],
-		}, chunkIndex, blockIndex, nil
+		return cl.byPage[offset/cl.pageSize]
 	}
-	return nil, 0, 0, nil
+
+	for c := cl.chunks; c != nil; c = c.next {
+		if addr >= c.addr && addr-c.addr < c.size.Load() {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// indexPages records c, nil once it is unmapped, as the chunk of the pages from addr to addr+size.
+func (cl *chunkList) indexPages(addr, size uintptr, c *chunk) {
+	if cl.reserved == nil {
+		return
+	}
+
+	offset, _ := cl.reserved.Offset(addr)
+	first := offset / cl.pageSize
+	last := first + size/cl.pageSize
+	if last > uintptr(len(cl.byPage)) {
+		cl.byPage = append(cl.byPage, make([]*chunk, last-uintptr(len(cl.byPage)))...)
+	}
+	for page := first; page < last; page++ {
+		cl.byPage[page] = c
+	}
+}
+
+// mapFirst maps the first chunk, a page, unless it is mapped already.
+// It is mapped by the first allocation rather than with the list,
+// so that creating an allocator does not fail, even with a hard limit below a page.
+func (cl *chunkList) mapFirst() error {
+	if cl.chunks != nil {
+		return nil
+	}
+
+	c, err := cl.newChunk(cl.pageSize, cl.mapFlags, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	cl.chunks = c
+	cl.len = 1
+
+	return nil
 }
 
 func (cl *chunkList) freeBytes() uintptr {
 	var freeBytes uintptr
 	head := cl.chunks
 	for head != nil {
-		head.freeBytes.Add(freeBytes)
+		freeBytes += head.freeBytes.Load()
 		head = head.next
 	}
 
 	return freeBytes
 }
 
+func (cl *chunkList) scavengedBytes() uintptr {
+	var scavengedBytes uintptr
+	head := cl.chunks
+	for head != nil {
+		scavengedBytes += head.scavengedBytes
+		head = head.next
+	}
+
+	return scavengedBytes
+}
+
 func (cl *chunkList) freeChunk(chunkMem *chunk) error {
+	// free memory first, the chunk stays in the list if it can not be freed
+	err := cl.sys.Free(chunkMem.addr, chunkMem.size.Load())
+	if err != nil {
+		cl.log.warn("goumem: could not unmap chunk", "addr", chunkMem.addr, "size", chunkMem.size.Load(), "err", err)
+		return fmt.Errorf("could not free memory: %w", err)
+	}
+
 	// remove chunk from list
 	if chunkMem.prev != nil { // not the first chunk
 		chunkMem.prev.next = chunkMem.next
+	} else {
+		cl.chunks = chunkMem.next
 	}
 
 	if chunkMem.next != nil { // not the last chunk
 		chunkMem.next.prev = chunkMem.prev
 	}
 
-	// free memory
-	err := syscall.Free(chunkMem.addr, chunkMem.size.Load())
-	if err != nil {
-		return fmt.Errorf("could not free memory: %w", err)
+	if cl.observers != nil {
+		cl.observers.chunkUnmap(chunkMem)
 	}
 
+	cl.log.debug("goumem: chunk unmapped", "addr", chunkMem.addr, "size", chunkMem.size.Load(), "chunks", cl.len-1)
+	cl.indexPages(chunkMem.addr, chunkMem.size.Load(), nil)
+
 	cl.len--
+	cl.budget.release(chunkMem.size.Load())
 
 	return nil
 }
 
-func newChunk(size uintptr, previous, next *chunk) (*chunk, error) {
+// appendChunk maps a new chunk of at least size bytes with flags
+// and appends it at the tail of the list.
+func (cl *chunkList) appendChunk(size uintptr, flags memsyscall.MapFlags) (*chunk, error) {
+	if err := cl.mapFirst(); err != nil {
+		return nil, err
+	}
+
+	lastChunk := cl.chunks.get(cl.len - 1)
+	c, err := cl.newChunk(size, flags, lastChunk, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	lastChunk.next = c
+	cl.len++
+
+	return c, nil
+}
+
+// newChunk maps a new chunk of at least size bytes,
+// as long as it fits in the memory budget of the list.
+func (cl *chunkList) newChunk(size uintptr, flags memsyscall.MapFlags, previous, next *chunk) (*chunk, error) {
 	var addr uintptr
 	var err error
 
 	memoryAlignedSize := size
-	if size%PageSize != 0 {
-		memoryAlignedSize = size + (PageSize - size%PageSize) // align to next page
+	if size%cl.pageSize != 0 {
+		memoryAlignedSize = size + (cl.pageSize - size%cl.pageSize) // align to next page
+	}
+
+	// only large chunks are backed by huge pages, and their size is aligned to the huge page
+	if flags&hugePageFlags != 0 {
+		if cl.hugePageSize != 0 && memoryAlignedSize >= cl.hugePageSize {
+			memoryAlignedSize = alignUp(memoryAlignedSize, cl.hugePageSize)
+		} else {
+			cl.log.debug("goumem: chunk too small for huge pages", "size", memoryAlignedSize, "huge_page_size", cl.hugePageSize)
+			flags &^= hugePageFlags
+		}
+	}
+
+	err = cl.budget.reserve(memoryAlignedSize)
+	if err != nil {
+		return nil, err
 	}
 
 	// alloc space from kernel
-	addr, err = syscall.Alloc(memoryAlignedSize)
+	var applied memsyscall.MapFlags
+	if mapper, ok := cl.sys.(memsyscall.Mapper); ok && flags != 0 {
+		addr, applied, err = mapper.Map(memoryAlignedSize, flags)
+	} else {
+		addr, err = cl.sys.Alloc(memoryAlignedSize)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("could not alloc memory: %w", err)
+		cl.budget.release(memoryAlignedSize)
+		cl.log.warn("goumem: could not map chunk", "size", memoryAlignedSize, "err", err)
+		return nil, fmt.Errorf("could not alloc memory: %w", mapError(err))
 	}
 
 	c := &chunk{
-		addr: addr,
-		prev: previous,
-		next: next,
+		list:     cl,
+		addr:     addr,
+		mapFlags: applied,
+		prev:     previous,
+		next:     next,
 		blocks: []*chunkBlock{
 			{
-				next:     nil,
-				prev:     nil,
-				nextFree: nil,
-				prevFree: nil,
+				next: nil,
+				prev: nil,
 			},
 		},
 	}
@@ -169,6 +315,12 @@ func newChunk(size uintptr, previous, next *chunk) (*chunk, error) {
 	c.blocks[0].isFree.Store(true)
 	c.blocks[0].size.Store(memoryAlignedSize)
 	c.blocks[0].addr.Store(addr)
+	cl.indexPages(addr, memoryAlignedSize, c)
+
+	cl.log.debug("goumem: chunk mapped", "addr", addr, "size", memoryAlignedSize, "flags", flags, "applied_flags", applied)
+	if cl.observers != nil {
+		cl.observers.chunkMap(c)
+	}
 
 	return c, nil
 }
@@ -182,140 +334,106 @@ func (c *chunk) get(index int) *chunk {
 	return head
 }
 
-// allocAndAppendNewChunk allocates a new chunk at the end of the tail of chunks
-// of size being the page-size of the system.
-func (c *chunk) allocAndAppendNewChunk() (chunck *chunk, err error) {
-	var addr uintptr
-
-	// alloc space from kernel
-	addr, err = syscall.Alloc(PageSize)
-	if err != nil {
-		return nil, fmt.Errorf("could not alloc memory: %w", err)
+// freeBlock returns the first free block of the chunk with at least size bytes.
+func (c *chunk) freeBlock(size uintptr) *chunkBlock {
+	for _, block := range c.blocks {
+		if block.isFree.Load() && block.size.Load() >= size {
+			return block
+		}
 	}
 
-	// Get the tail
-	head := c
-	for head.next != nil {
-		head = head.next
-	}
+	return nil
+}
 
-	// Append new chunk on the tail
-	head.next = &chunk{
-		addr: addr,
-		blocks: []*chunkBlock{
-			{},
-		},
-		next: nil,
-		prev: head,
+func (c *chunk) splitAndGetFirstPart(block *chunkBlock, size uintptr) (uintptr, error) {
+	if !block.isFree.Load() || block.size.Load() < size {
+		return 0, fmt.Errorf("%w: block at %#x is not a free block of %d bytes", ErrHeapCorrupted, block.addr.Load(), size)
 	}
 
-	head.freeBytes.Add(PageSize)
-	head.size.Add(PageSize)
-	head.blocks[0].isFree.Store(true)
-	head.blocks[0].size.Store(PageSize)
-	head.blocks[0].addr.Store(addr)
-	head.blocks[0].isFree.Store(true)
+	// split the block, if there is anything left after the first part
+	if block.size.Load() > size {
+		secondBlock := &chunkBlock{
+			prev: block,
+			next: block.next,
+		}
+		secondBlock.addr.Store(block.addr.Load() + size)
+		secondBlock.size.Store(block.size.Load() - size)
+		secondBlock.isFree.Store(true)
 
-	return head.next, nil
-}
+		if block.next != nil {
+			block.next.prev = secondBlock
+		}
+		block.next = secondBlock
+		block.size.Store(size)
+		c.insertBlockAfter(block, secondBlock)
 
-func (c *chunk) splitAndGetFirstPart(block *chunkBlock, size uintptr) (uintptr, error) {
-	// handle blocks
-	firstBlock := block
-	firstBlock.isFree.Store(false)
-	secondBlock := &chunkBlock{
-		prev:     firstBlock,
-		next:     firstBlock.next,
-		nextFree: firstBlock.nextFree,
-		prevFree: firstBlock.prevFree,
+		// the pages of the remainder stay scavenged
+		secondBlock.scavenged = min(block.scavenged, c.list.pageSpan(secondBlock))
+		block.scavenged -= secondBlock.scavenged
 	}
-	secondBlock.addr.Store(firstBlock.addr.Load() + size + 1)
-	secondBlock.size.Store(firstBlock.size.Load() - size)
-	secondBlock.isFree.Store(true)
-	firstBlock.next = secondBlock
 
-	if firstBlock.prevFree != nil {
-		firstBlock.prevFree.nextFree = secondBlock
-	}
-	if firstBlock.nextFree != nil {
-		firstBlock.nextFree.nextFree = secondBlock.nextFree
-	}
+	// the scavenged pages of the block are committed again as soon as they are touched
+	c.scavengedBytes -= block.scavenged
+	block.scavenged = 0
 
-	// handle chunk
+	block.isFree.Store(false)
 	c.freeBytes.Add(-size)
-	c.blocks = append(c.blocks, secondBlock)
 
-	return firstBlock.addr.Load(), nil
+	return block.addr.Load(), nil
 }
 
-// mergeAdjacent merges every adjacent block nearby.
+// mergeAdjacent merges the block with its adjacent free blocks
+// and returns the merged block.
 // Reduces fragmentation of memory, even when seemed not necessary.
 // Used by [free] method.
-func (c *chunkBlock) mergeAdjacent() {
-	// check for backwards adjacent free blocks
-	head := c
-	for head.isFree.Load() && head.addr.Load()+c.size.Load()+1 == c.addr.Load() {
-		head.size.Add(c.size.Load())
-		head.prev = c.next
+func (c *chunk) mergeAdjacent(block *chunkBlock) *chunkBlock {
+	// check for forward adjacent free block
+	if next := block.next; next != nil && next.isFree.Load() {
+		block.size.Add(next.size.Load())
+		block.scavenged += next.scavenged
+		block.next = next.next
+		if next.next != nil {
+			next.next.prev = block
+		}
+		c.removeBlock(next)
 	}
 
-	// check for forward adjacent free blocks
-	head = c
-	for head.isFree.Load() && c.addr.Load()+c.size.Load() == head.addr.Load()-1 {
-		c.size.Add(head.size.Load())
-		c.next = head.next
+	// check for backwards adjacent free block
+	if prev := block.prev; prev != nil && prev.isFree.Load() {
+		prev.size.Add(block.size.Load())
+		prev.scavenged += block.scavenged
+		prev.next = block.next
+		if block.next != nil {
+			block.next.prev = prev
+		}
+		c.removeBlock(block)
+		block = prev
 	}
 
-	if c.prevFree != nil {
-		prevNextFree := c.prevFree.nextFree
-		c.prevFree.nextFree = c
+	return block
+}
 
-		c.prevFree = prevNextFree
+// insertBlockAfter keeps the blocks of the chunk sorted by address.
+func (c *chunk) insertBlockAfter(block, newBlock *chunkBlock) {
+	for i, b := range c.blocks {
+		if b == block {
+			c.blocks = append(c.blocks, nil)
+			copy(c.blocks[i+2:], c.blocks[i+1:])
+			c.blocks[i+1] = newBlock
+			return
+		}
 	}
 
-	if c.nextFree != nil {
-		c.nextFree.prevFree = c
-		nextPrevFree := c.nextFree.prevFree
-		c.nextFree = nextPrevFree
-	}
+	c.blocks = append(c.blocks, newBlock)
 }
 
-func (c *chunk) getFreeAddrForSize(requestedSize uintptr) (addr uintptr, blockIndex int, err error) {
-	// O(n) max cycles
-	// 2 branches - 1 with 2 other branches
-	for i, block := range c.blocks {
-		// split block if it's free and has enough space and occupy the first part of it
-		if block.isFree.Load() && block.size.Load() > requestedSize {
-			addr, err := c.splitAndGetFirstPart(block, requestedSize)
-			if err != nil {
-				return 0, 0, err
-			}
-
-			return addr, i, nil
-		} else if block.isFree.Load() {
-			// free blocks should be already merged by free method
-			// so, we do not need to check for adjacent free blocks
-			// it should already be at its maximum size
-
-			if block.size.Load() > requestedSize {
-				// check again if it's the requested size and split if
-				addr, err := c.splitAndGetFirstPart(block, requestedSize)
-				if err != nil {
-					return 0, 0, err
-				}
-
-				return addr, i, nil
-			} else if block.size.Load() == requestedSize {
-				// alloc whole block
-				addr := block.addr
-				c.freeBytes.Add(-requestedSize)
-				block.isFree.Store(false)
-				return addr.Load(), i, nil
-			}
+func (c *chunk) removeBlock(block *chunkBlock) {
+	for i, b := range c.blocks {
+		if b == block {
+			c.blocks = append(c.blocks[:i], c.blocks[i+1:]...)
+			return
 		}
 	}
-
-	return 0, 0, fmt.Errorf("could not alloc memory in chunk")
 }
 
 func (b *AllocatedBlock) Addr() uintptr {
@@ -326,8 +444,24 @@ func (b *AllocatedBlock) Size() uintptr {
 	return b.size
 }
 
+// Bytes returns the memory of the block as a slice.
+// The slice must not be used after the block is freed.
+func (b *AllocatedBlock) Bytes() []byte {
+	if b.IsFreed() {
+		return nil
+	}
+
+	return unsafe.Slice((*byte)(unsafe.Pointer(b.addr)), b.size)
+}
+
 func (b *AllocatedBlock) IsFreed() bool {
-	return b.flags&AllocatedBlockFlagsFree != 0
+	return AllocatedBlockFlags(b.flags.Load())&AllocatedBlockFlagsFree != 0
+}
+
+// markFreed flags the block as freed.
+// It must be called with the lock of the allocator that owns the block held.
+func (b *AllocatedBlock) markFreed() {
+	b.flags.Store(b.flags.Load() | uintptr(AllocatedBlockFlagsFree))
 }
 
 func (cb *chunkBlock) copy(dst *chunkBlock) error {
@@ -336,7 +470,7 @@ func (cb *chunkBlock) copy(dst *chunkBlock) error {
 	destSize := dst.size.Load()
 
 	if srcSize != destSize {
-		return ErrAllocatedBlockDifferentSize
+		return ErrSizeMismatch
 	}
 
 	srcSlice := (*(*[1 << 30]byte)(unsafe.Pointer(src.addr.Load())))[:srcSize:srcSize]
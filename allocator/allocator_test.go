@@ -2,6 +2,7 @@ package allocator
 
 import (
 	"github.com/stretchr/testify/suite"
+	"io"
 	"testing"
 	"unsafe"
 )
@@ -29,6 +30,34 @@ func (suite *AllocatorTestSuite) TestAllocFree() {
 	}
 }
 
+func (suite *AllocatorTestSuite) TestClose() {
+	small, err := suite.allocator.Alloc(10)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	_, err = suite.allocator.Alloc(4 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.NoError(suite.allocator.(io.Closer).Close())
+
+	stats, _ := ReadStats(suite.allocator)
+	suite.Zero(stats.Chunks)
+	suite.Zero(stats.MappedBytes)
+	suite.Zero(stats.LiveBlocks)
+	suite.True(small.IsFreed())
+	suite.ErrorIs(suite.allocator.Free(small), ErrDoubleFree)
+
+	// a new chunk is mapped when the allocator is used again
+	block, err := suite.allocator.Alloc(10)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.NoError(suite.allocator.Free(block))
+	suite.NoError(suite.allocator.(Verifier).Verify())
+}
+
 func (suite *AllocatorTestSuite) TestSet() {
 	suite.Run("struct", func() {
 		type MyStruct struct {
@@ -192,22 +221,26 @@ func TestAllocatorTestSuite(t *testing.T) {
 }
 
 func ExampleMemoryAllocator_Alloc() {
+	a := Default()
+
 	// Allocate a block of memory
-	block, err := Default().Alloc(10)
+	block, err := a.Alloc(10)
 	if err != nil {
 		panic(err)
 	}
 
 	// Free the block
-	err = Default().Free(block)
+	err = a.Free(block)
 	if err != nil {
 		panic(err)
 	}
 }
 
-func ExampleAllocatedBlock_Set() {
+func ExampleSet() {
+	a := Default()
+
 	// Allocate a block of memory
-	block, err := Default().Alloc(10)
+	block, err := a.Alloc(10)
 	if err != nil {
 		panic(err)
 	}
@@ -216,15 +249,17 @@ func ExampleAllocatedBlock_Set() {
 	Set[string](block, "test data")
 
 	// Free the block
-	err = Default().Free(block)
+	err = a.Free(block)
 	if err != nil {
 		panic(err)
 	}
 }
 
-func ExampleAllocatedBlock_Get() {
+func ExampleGet() {
+	a := Default()
+
 	// Allocate a block of memory
-	block, err := Default().Alloc(10)
+	block, err := a.Alloc(10)
 	if err != nil {
 		panic(err)
 	}
@@ -237,21 +272,23 @@ func ExampleAllocatedBlock_Get() {
 	print(got)
 
 	// Free the block
-	err = Default().Free(block)
+	err = a.Free(block)
 	if err != nil {
 		panic(err)
 	}
 }
 
-func ExampleAllocatedBlock_Copy() {
+func ExampleMemoryAllocator_Copy() {
+	a := Default()
+
 	// Allocate a block of memory
-	srcBlock, err := Default().Alloc(10)
+	srcBlock, err := a.Alloc(10)
 	if err != nil {
 		panic(err)
 	}
 
 	// Allocate a block of memory
-	dstBlock, err := Default().Alloc(10)
+	dstBlock, err := a.Alloc(10)
 	if err != nil {
 		panic(err)
 	}
@@ -260,19 +297,19 @@ func ExampleAllocatedBlock_Copy() {
 	Set(srcBlock, "test data")
 
 	// Copy data from srcBlock to dstBlock
-	err = Default().Copy(dstBlock, srcBlock)
+	err = a.Copy(dstBlock, srcBlock)
 	if err != nil {
 		panic(err)
 	}
 
 	// Free the srcBlock
-	err = Default().Free(srcBlock)
+	err = a.Free(srcBlock)
 	if err != nil {
 		panic(err)
 	}
 
 	// Free the dstBlock
-	err = Default().Free(dstBlock)
+	err = a.Free(dstBlock)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,309 @@
+// Package allocatortest checks that a [allocator.MemoryAllocator] behaves like one.
+//
+// Implementations prove their conformance with a test and a fuzz target:
+//
+//	func TestConformance(t *testing.T) {
+//		allocatortest.Run(t, func() allocator.MemoryAllocator { return NewMyAllocator() })
+//	}
+//
+//	func FuzzConformance(f *testing.F) {
+//		allocatortest.Fuzz(f, func() allocator.MemoryAllocator { return NewMyAllocator() })
+//	}
+//
+// Allocators that hold more than their blocks, like the memory of their syscall,
+// use [RunTB] and [FuzzTB] instead, whose factories release it with tb.Cleanup.
+package allocatortest
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/exapsy/goumem/allocator"
+	"github.com/stretchr/testify/suite"
+)
+
+// Factory returns a new allocator for [RunTB] and [FuzzTB], every test gets its own.
+// What the allocator holds besides its blocks, like the memory of its syscall,
+// is released with tb.Cleanup once the test that asked for it is done.
+type Factory func(tb testing.TB) allocator.MemoryAllocator
+
+type conformanceTestSuite struct {
+	suite.Suite
+	factory Factory
+	mem     allocator.MemoryAllocator
+}
+
+// sizes are the sizes of the functional tests, around the usual boundaries.
+var sizes = []uintptr{1, 7, 8, 15, 16, 17, 100, 1000, 2047, 2048, 4095, 4096, 4097, 3 * 4096, 1 << 20}
+
+// Run runs the conformance tests against the allocators returned by factory:
+// functional tests, randomized tests against a model of the live blocks, and concurrency tests.
+func Run(t *testing.T, factory func() allocator.MemoryAllocator) {
+	RunTB(t, withoutTB(factory))
+}
+
+// RunTB is [Run] with a factory that is given the test it creates the allocator for.
+func RunTB(t *testing.T, factory Factory) {
+	suite.Run(t, &conformanceTestSuite{factory: factory})
+}
+
+func withoutTB(factory func() allocator.MemoryAllocator) Factory {
+	return func(testing.TB) allocator.MemoryAllocator {
+		return factory()
+	}
+}
+
+const (
+	// fuzzMaxOps are the operations run for a fuzzer input at most, the rest of it is ignored.
+	fuzzMaxOps = 1024
+	// fuzzMaxLiveBytes are the bytes the live blocks of a fuzzer input hold at most,
+	// allocations above it are skipped.
+	fuzzMaxLiveBytes = 16 << 20
+)
+
+// Fuzz runs the model-based test with operations decoded from the fuzzer input.
+// Every operation is checked as it runs, and all the live blocks once at the end.
+func Fuzz(f *testing.F, factory func() allocator.MemoryAllocator) {
+	FuzzTB(f, withoutTB(factory))
+}
+
+// FuzzTB is [Fuzz] with a factory that is given the test it creates the allocator for.
+func FuzzTB(f *testing.F, factory Factory) {
+	f.Add([]byte{0, 1, 0, 2, 1, 0, 2, 0})
+	f.Add([]byte{0, 200, 0, 200, 2, 0, 1, 1, 0, 7, 1, 0})
+	f.Add([]byte{0, 255, 255, 0, 16, 0, 0, 16, 0, 2, 1})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		m := newModel(t, factory(t))
+		for n := 0; n < fuzzMaxOps && len(ops) >= 2; n++ {
+			op, arg := ops[0], ops[1]
+			ops = ops[2:]
+
+			switch op % 3 {
+			case 0:
+				size := uintptr(arg)
+				if len(ops) >= 1 {
+					size = uintptr(binary.LittleEndian.Uint16([]byte{arg, ops[0]}))
+					ops = ops[1:]
+				}
+				if m.liveBytes+size+1 <= fuzzMaxLiveBytes {
+					m.alloc(size + 1)
+				}
+			case 1:
+				m.free(int(arg))
+			case 2:
+				m.copy(int(arg))
+			}
+			m.checkTouched()
+		}
+		m.close()
+	})
+}
+
+func (suite *conformanceTestSuite) SetupTest() {
+	suite.mem = suite.factory(suite.T())
+}
+
+func (suite *conformanceTestSuite) TestAllocFree() {
+	for _, size := range sizes {
+		block, err := suite.mem.Alloc(size)
+		if err != nil {
+			suite.FailNow("Failed to allocate block", "size %d: %v", size, err)
+		}
+
+		suite.Equal(size, block.Size())
+		suite.NotZero(block.Addr())
+		suite.False(block.IsFreed())
+		suite.Len(block.Bytes(), int(size))
+
+		// the whole block is usable
+		fill(block, byte(size))
+		suite.True(verify(block, byte(size)), "size %d", size)
+
+		suite.NoError(suite.mem.Free(block), "size %d", size)
+		suite.True(block.IsFreed())
+	}
+}
+
+func (suite *conformanceTestSuite) TestDoubleFree() {
+	block, err := suite.mem.Alloc(64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.NoError(suite.mem.Free(block))
+	suite.ErrorIs(suite.mem.Free(block), allocator.ErrDoubleFree)
+}
+
+func (suite *conformanceTestSuite) TestForeignBlock() {
+	other := suite.factory(suite.T())
+	foreign, err := other.Alloc(64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	block, err := suite.mem.Alloc(64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.ErrorIs(suite.mem.Free(foreign), allocator.ErrForeignBlock)
+	suite.ErrorIs(suite.mem.Copy(block, foreign), allocator.ErrForeignBlock)
+	suite.ErrorIs(suite.mem.Copy(foreign, block), allocator.ErrForeignBlock)
+	suite.False(foreign.IsFreed())
+
+	suite.NoError(other.Free(foreign))
+	suite.NoError(suite.mem.Free(block))
+}
+
+func (suite *conformanceTestSuite) TestNilBlock() {
+	block, err := suite.mem.Alloc(64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.ErrorIs(suite.mem.Free(nil), allocator.ErrForeignBlock)
+	suite.ErrorIs(suite.mem.Copy(nil, block), allocator.ErrForeignBlock)
+	suite.ErrorIs(suite.mem.Copy(block, nil), allocator.ErrForeignBlock)
+
+	suite.NoError(suite.mem.Free(block))
+}
+
+func (suite *conformanceTestSuite) TestCopy() {
+	for _, size := range sizes {
+		src, err := suite.mem.Alloc(size)
+		if err != nil {
+			suite.FailNow("Failed to allocate block", err)
+		}
+		dst, err := suite.mem.Alloc(size)
+		if err != nil {
+			suite.FailNow("Failed to allocate block", err)
+		}
+
+		fill(src, 1)
+		fill(dst, 2)
+		suite.NoError(suite.mem.Copy(dst, src))
+		suite.True(verify(dst, 1), "size %d", size)
+		suite.True(verify(src, 1), "size %d", size)
+
+		suite.NoError(suite.mem.Free(src))
+		suite.NoError(suite.mem.Free(dst))
+	}
+}
+
+func (suite *conformanceTestSuite) TestCopyDifferentSize() {
+	src, err := suite.mem.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	dst, err := suite.mem.Alloc(32)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.ErrorIs(suite.mem.Copy(dst, src), allocator.ErrSizeMismatch)
+
+	suite.NoError(suite.mem.Free(src))
+	suite.NoError(suite.mem.Copy(dst, dst))
+	suite.Error(suite.mem.Copy(dst, src))
+	suite.NoError(suite.mem.Free(dst))
+}
+
+func (suite *conformanceTestSuite) TestNoOverlap() {
+	m := newModel(suite.T(), suite.mem)
+	for _, size := range sizes {
+		m.alloc(size)
+		m.alloc(size)
+	}
+	m.check()
+
+	// reuse the holes left by every other block
+	for i := 0; i < len(m.live); i++ {
+		m.free(i)
+	}
+	for _, size := range sizes {
+		m.alloc(size)
+	}
+	m.check()
+	m.close()
+}
+
+func (suite *conformanceTestSuite) TestModel() {
+	for seed := int64(1); seed <= 4; seed++ {
+		random := rand.New(rand.NewSource(seed))
+		m := newModel(suite.T(), suite.factory(suite.T()))
+
+		for i := 0; i < 1000; i++ {
+			switch n := random.Intn(10); {
+			case n < 5 && len(m.live) < 64:
+				m.alloc(randomSize(random))
+			case n < 9:
+				m.free(random.Int())
+			default:
+				m.copy(random.Int())
+			}
+			m.checkTouched()
+		}
+
+		if stats, ok := allocator.ReadStats(m.mem); ok {
+			suite.Equal(uint64(len(m.live)), stats.LiveBlocks, "seed %d", seed)
+		}
+
+		m.close()
+	}
+}
+
+func (suite *conformanceTestSuite) TestConcurrent() {
+	const goroutines = 8
+	const iterations = 200
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+
+			random := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < iterations; i++ {
+				size := randomSize(random)
+				block, err := suite.mem.Alloc(size)
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				fill(block, byte(g))
+				if !verify(block, byte(g)) {
+					errs <- errors.New("block overwritten by another goroutine")
+					return
+				}
+
+				if err := suite.mem.Free(block); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		suite.NoError(err)
+	}
+
+	if stats, ok := allocator.ReadStats(suite.mem); ok {
+		suite.Zero(stats.LiveBlocks)
+	}
+	if verifier, ok := suite.mem.(allocator.Verifier); ok {
+		suite.NoError(verifier.Verify())
+	}
+}
+
+// randomSize favours small sizes, with now and then a size of a few pages.
+func randomSize(random *rand.Rand) uintptr {
+	return uintptr(random.Intn(1<<uint(random.Intn(15)))) + 1
+}
@@ -0,0 +1,206 @@
+package allocatortest
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"unsafe"
+
+	"github.com/exapsy/goumem/allocator"
+)
+
+// model runs operations against an allocator and checks them
+// against a reference of the blocks that must be live.
+type model struct {
+	tb     testing.TB
+	mem    allocator.MemoryAllocator
+	live   []*liveBlock
+	nextID byte
+	// liveBytes are the bytes of the live blocks.
+	liveBytes uintptr
+	// touched are the blocks allocated or copied to since the last check.
+	touched []*liveBlock
+}
+
+type liveBlock struct {
+	block *allocator.AllocatedBlock
+	// fill is the byte the content of the block starts at.
+	fill byte
+}
+
+func newModel(tb testing.TB, mem allocator.MemoryAllocator) *model {
+	return &model{tb: tb, mem: mem}
+}
+
+func (m *model) alloc(size uintptr) {
+	m.tb.Helper()
+
+	block, err := m.mem.Alloc(size)
+	if errors.Is(err, allocator.ErrOutOfMemory) {
+		return
+	}
+	if err != nil {
+		m.tb.Fatalf("alloc %d: %v", size, err)
+	}
+
+	if block.Size() != size {
+		m.tb.Fatalf("alloc %d: got a block of %d bytes", size, block.Size())
+	}
+	if block.IsFreed() {
+		m.tb.Fatalf("alloc %d: got a freed block", size)
+	}
+	if size != 0 && block.Addr()%unsafe.Alignof(uintptr(0)) != 0 {
+		m.tb.Fatalf("alloc %d: block at %#x is not aligned", size, block.Addr())
+	}
+
+	m.nextID++
+	fill(block, m.nextID)
+	live := &liveBlock{block: block, fill: m.nextID}
+	m.live = append(m.live, live)
+	m.liveBytes += size
+	m.touched = append(m.touched, live)
+}
+
+func (m *model) free(i int) {
+	m.tb.Helper()
+
+	if len(m.live) == 0 {
+		return
+	}
+
+	i %= len(m.live)
+	block := m.live[i].block
+	m.live = append(m.live[:i], m.live[i+1:]...)
+	m.liveBytes -= block.Size()
+
+	if err := m.mem.Free(block); err != nil {
+		m.tb.Fatalf("free block of %d bytes: %v", block.Size(), err)
+	}
+	if !block.IsFreed() {
+		m.tb.Fatalf("free block of %d bytes: block is not marked freed", block.Size())
+	}
+
+	if err := m.mem.Free(block); !errors.Is(err, allocator.ErrDoubleFree) {
+		m.tb.Fatalf("double free of a block of %d bytes: got %v, want %v", block.Size(), err, allocator.ErrDoubleFree)
+	}
+}
+
+// copy copies block i into the next live block of the same size, if any.
+func (m *model) copy(i int) {
+	m.tb.Helper()
+
+	if len(m.live) == 0 {
+		return
+	}
+
+	src := m.live[i%len(m.live)]
+	for j := 1; j < len(m.live); j++ {
+		dst := m.live[(i+j)%len(m.live)]
+		if dst.block.Size() != src.block.Size() {
+			continue
+		}
+
+		if err := m.mem.Copy(dst.block, src.block); err != nil {
+			m.tb.Fatalf("copy %d bytes: %v", src.block.Size(), err)
+		}
+		dst.fill = src.fill
+		m.touched = append(m.touched, dst)
+
+		return
+	}
+}
+
+// checkTouched checks the blocks touched since the last check,
+// that they were not overwritten and do not overlap any live block.
+// It is cheaper than [model.check] to run after every operation.
+func (m *model) checkTouched() {
+	m.tb.Helper()
+
+	for _, touched := range m.touched {
+		if touched.block.IsFreed() {
+			continue
+		}
+
+		if !verify(touched.block, touched.fill) {
+			m.tb.Fatalf("block of %d bytes at %#x was overwritten", touched.block.Size(), touched.block.Addr())
+		}
+
+		for _, live := range m.live {
+			if live != touched && overlap(live.block, touched.block) {
+				m.tb.Fatalf("block of %d bytes at %#x overlaps block at %#x", touched.block.Size(), touched.block.Addr(), live.block.Addr())
+			}
+		}
+	}
+
+	m.touched = m.touched[:0]
+}
+
+// check checks that the live blocks do not overlap
+// and that none of them was overwritten.
+func (m *model) check() {
+	m.tb.Helper()
+
+	m.touched = m.touched[:0]
+
+	sorted := make([]*allocator.AllocatedBlock, 0, len(m.live))
+	for _, live := range m.live {
+		if !verify(live.block, live.fill) {
+			m.tb.Fatalf("block of %d bytes at %#x was overwritten", live.block.Size(), live.block.Addr())
+		}
+
+		if live.block.Size() != 0 {
+			sorted = append(sorted, live.block)
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Addr() < sorted[j].Addr()
+	})
+	for i := 1; i < len(sorted); i++ {
+		if prev := sorted[i-1]; prev.Addr()+prev.Size() > sorted[i].Addr() {
+			m.tb.Fatalf("block of %d bytes at %#x overlaps block at %#x", prev.Size(), prev.Addr(), sorted[i].Addr())
+		}
+	}
+}
+
+// close checks the live blocks, frees them and verifies the allocator.
+func (m *model) close() {
+	m.tb.Helper()
+
+	m.check()
+	for len(m.live) > 0 {
+		m.free(0)
+	}
+
+	if verifier, ok := m.mem.(allocator.Verifier); ok {
+		if err := verifier.Verify(); err != nil {
+			m.tb.Fatalf("verify: %v", err)
+		}
+	}
+}
+
+// overlap reports whether the memory of a and b overlaps.
+func overlap(a, b *allocator.AllocatedBlock) bool {
+	if a.Size() == 0 || b.Size() == 0 {
+		return false
+	}
+
+	return a.Addr() < b.Addr()+b.Size() && b.Addr() < a.Addr()+a.Size()
+}
+
+func fill(block *allocator.AllocatedBlock, start byte) {
+	mem := block.Bytes()
+	for i := range mem {
+		mem[i] = start + byte(i)
+	}
+}
+
+func verify(block *allocator.AllocatedBlock, start byte) bool {
+	for i, b := range block.Bytes() {
+		if b != start+byte(i) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,158 @@
+package allocator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrCanaryCorrupted = fmt.Errorf("goumem: block canary corrupted")
+)
+
+// canary is written right before and right after every block of a [CanaryAllocator].
+// Its size keeps the blocks aligned.
+var canary = [blockAlignment]byte{0xca, 0xfe, 0xba, 0xbe, 0xde, 0xad, 0xbe, 0xef, 0xca, 0xfe, 0xba, 0xbe, 0xde, 0xad, 0xbe, 0xef}
+
+// CanaryAllocator is a debugging allocator that surrounds every block
+// with canary bytes, and checks that they are intact when the block is freed,
+// to catch writes out of the bounds of blocks.
+type CanaryAllocator struct {
+	parent MemoryAllocator
+	mutex  sync.Mutex
+	// blocks maps the blocks handed out to the blocks of the parent that hold them with their canaries.
+	blocks map[*AllocatedBlock]*AllocatedBlock
+}
+
+// NewCanaryAllocator creates a canary allocator that allocates its blocks from parent.
+func NewCanaryAllocator(parent MemoryAllocator) *CanaryAllocator {
+	return &CanaryAllocator{
+		parent: parent,
+		blocks: map[*AllocatedBlock]*AllocatedBlock{},
+	}
+}
+
+func (a *CanaryAllocator) Alloc(size uintptr) (*AllocatedBlock, error) {
+	return a.AllocTagged(size, NoTag)
+}
+
+func (a *CanaryAllocator) AllocTagged(size uintptr, tag Tag) (*AllocatedBlock, error) {
+	if err := checkSize(size); err != nil {
+		return nil, err
+	}
+
+	inner, err := AllocTagged(a.parent, size+2*uintptr(len(canary)), tag)
+	if err != nil {
+		return nil, err
+	}
+
+	mem := inner.Bytes()
+	copy(mem, canary[:])
+	copy(mem[uintptr(len(canary))+size:], canary[:])
+
+	block := &AllocatedBlock{
+		size: size,
+		addr: inner.Addr() + uintptr(len(canary)),
+		tag:  tag,
+	}
+
+	a.mutex.Lock()
+	a.blocks[block] = inner
+	a.mutex.Unlock()
+
+	return block, nil
+}
+
+// Free frees the block, and returns an error wrapping [ErrCanaryCorrupted]
+// if anything was written right before or right after it.
+func (a *CanaryAllocator) Free(block *AllocatedBlock) error {
+	a.mutex.Lock()
+	inner, ok := a.blocks[block]
+	if !ok {
+		a.mutex.Unlock()
+		if block != nil && block.IsFreed() {
+			return ErrDoubleFree
+		}
+
+		return ErrForeignBlock
+	}
+
+	delete(a.blocks, block)
+	corrupted := checkCanaries(block, inner)
+	block.markFreed()
+	block.addr = 0
+	a.mutex.Unlock()
+
+	return errors.Join(corrupted, a.parent.Free(inner))
+}
+
+func (a *CanaryAllocator) Copy(dst, src *AllocatedBlock) error {
+	a.mutex.Lock()
+	innerDst, dstOk := a.blocks[dst]
+	innerSrc, srcOk := a.blocks[src]
+	a.mutex.Unlock()
+
+	if !dstOk || !srcOk {
+		if (dst != nil && dst.IsFreed()) || (src != nil && src.IsFreed()) {
+			return ErrDoubleFree
+		}
+
+		return ErrForeignBlock
+	}
+
+	if dst.size != src.size {
+		return ErrSizeMismatch
+	}
+
+	return a.parent.Copy(innerDst, innerSrc)
+}
+
+// Verify checks the canaries of every live block, then verifies the parent if it can.
+func (a *CanaryAllocator) Verify() error {
+	a.mutex.Lock()
+	blocks := make([]*AllocatedBlock, 0, len(a.blocks))
+	for block := range a.blocks {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].addr < blocks[j].addr
+	})
+
+	var errs []error
+	for _, block := range blocks {
+		if err := checkCanaries(block, a.blocks[block]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	a.mutex.Unlock()
+
+	if verifier, ok := a.parent.(Verifier); ok {
+		errs = append(errs, verifier.Verify())
+	}
+
+	return errors.Join(errs...)
+}
+
+// Stats returns the statistics of the parent, if it keeps any,
+// which count the canaries in the size of the blocks.
+func (a *CanaryAllocator) Stats() Stats {
+	stats, _ := ReadStats(a.parent)
+	return stats
+}
+
+func checkCanaries(block, inner *AllocatedBlock) error {
+	mem := inner.Bytes()
+	before := mem[:len(canary)]
+	after := mem[uintptr(len(canary))+block.size:][:len(canary)]
+
+	switch {
+	case !bytes.Equal(before, canary[:]):
+		return fmt.Errorf("%w: underflow of the block of %d bytes at %#x", ErrCanaryCorrupted, block.size, block.addr)
+	case !bytes.Equal(after, canary[:]):
+		return fmt.Errorf("%w: overflow of the block of %d bytes at %#x", ErrCanaryCorrupted, block.size, block.addr)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package allocator
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"unsafe"
+)
+
+type CanaryAllocatorTestSuite struct {
+	suite.Suite
+	canary *CanaryAllocator
+}
+
+func (suite *CanaryAllocatorTestSuite) SetupTest() {
+	suite.canary = NewCanaryAllocator(Default())
+}
+
+func (suite *CanaryAllocatorTestSuite) TestIntact() {
+	block, err := suite.canary.Alloc(100)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.Zero(block.Addr() % blockAlignment)
+	for i := range block.Bytes() {
+		block.Bytes()[i] = 0xff
+	}
+
+	suite.NoError(suite.canary.Verify())
+	suite.NoError(suite.canary.Free(block))
+}
+
+func (suite *CanaryAllocatorTestSuite) TestOverflow() {
+	block, err := suite.canary.Alloc(100)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	*(*byte)(unsafe.Pointer(block.Addr() + 100)) = 0
+	suite.ErrorIs(suite.canary.Verify(), ErrCanaryCorrupted)
+
+	err = suite.canary.Free(block)
+	suite.ErrorIs(err, ErrCanaryCorrupted)
+	suite.ErrorContains(err, "overflow")
+	suite.True(block.IsFreed())
+}
+
+func (suite *CanaryAllocatorTestSuite) TestUnderflow() {
+	block, err := suite.canary.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	*(*byte)(unsafe.Pointer(block.Addr() - 1)) = 0
+
+	err = suite.canary.Free(block)
+	suite.ErrorIs(err, ErrCanaryCorrupted)
+	suite.ErrorContains(err, "underflow")
+}
+
+func (suite *CanaryAllocatorTestSuite) TestDoubleFree() {
+	block, err := suite.canary.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.NoError(suite.canary.Free(block))
+	suite.ErrorIs(suite.canary.Free(block), ErrAllocatedBlockAlreadyFreed)
+}
+
+func (suite *CanaryAllocatorTestSuite) TestCopyKeepsCanaries() {
+	src, err := suite.canary.Alloc(32)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	dst, err := suite.canary.Alloc(32)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	src.Bytes()[0] = 42
+	suite.NoError(suite.canary.Copy(dst, src))
+	suite.Equal(byte(42), dst.Bytes()[0])
+
+	suite.NoError(suite.canary.Free(src))
+	suite.NoError(suite.canary.Free(dst))
+}
+
+func TestCanaryAllocatorTestSuite(t *testing.T) {
+	suite.Run(t, new(CanaryAllocatorTestSuite))
+}
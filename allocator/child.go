@@ -0,0 +1,296 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrAllocatorClosed = fmt.Errorf("goumem: allocator closed")
+)
+
+// ChildAllocator carves a quota out of a parent [MemoryAllocator].
+//
+// Every block is allocated from the parent, so the usage of a child
+// is accounted in the statistics and quota of its parent too.
+// Closing a child frees every block it still holds,
+// including the blocks of its own children.
+// A block belongs to the child that allocated it and is meant to be freed through it.
+// A block freed through the parent instead stays accounted by the child
+// until it is freed through the child, which reports [ErrDoubleFree], or the child is closed.
+type ChildAllocator struct {
+	parent    MemoryAllocator
+	quota     atomic.Uintptr
+	counters  allocatorCounters
+	rejected  atomic.Uint64
+	mutex     sync.Mutex
+	blocks    map[*AllocatedBlock]struct{}
+	children  map[*ChildAllocator]struct{}
+	closed    bool
+	closeOnce sync.Once
+}
+
+// NewChildAllocator creates a child of parent that may hold at most quota bytes.
+// Zero quota means the child is only limited by its parent.
+func NewChildAllocator(parent MemoryAllocator, quota uintptr) *ChildAllocator {
+	child := &ChildAllocator{
+		parent:   parent,
+		blocks:   map[*AllocatedBlock]struct{}{},
+		children: map[*ChildAllocator]struct{}{},
+	}
+	child.quota.Store(quota)
+
+	if p, ok := parent.(*ChildAllocator); ok {
+		p.mutex.Lock()
+		p.children[child] = struct{}{}
+		p.mutex.Unlock()
+	}
+
+	return child
+}
+
+// parentChild returns the parent, if it is a child allocator too.
+func (c *ChildAllocator) parentChild() *ChildAllocator {
+	p, _ := c.parent.(*ChildAllocator)
+	return p
+}
+
+func (c *ChildAllocator) Alloc(size uintptr) (*AllocatedBlock, error) {
+	return c.AllocTagged(size, NoTag)
+}
+
+// AllocTagged allocates a block tagged with tag, accounted per tag by the child and its parent.
+func (c *ChildAllocator) AllocTagged(size uintptr, tag Tag) (*AllocatedBlock, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	block, err := c.alloc(size, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	c.blocks[block] = struct{}{}
+
+	return block, nil
+}
+
+// alloc allocates a block from the parent and accounts it against the quota,
+// without recording it as a block of the child.
+// It must be called with the mutex held.
+func (c *ChildAllocator) alloc(size uintptr, tag Tag) (*AllocatedBlock, error) {
+	if c.closed {
+		return nil, ErrAllocatorClosed
+	}
+
+	quota := c.quota.Load()
+	live := c.counters.liveBytes.Load()
+	if quota != 0 && live+size > quota {
+		c.rejected.Add(1)
+		return nil, fmt.Errorf("%w: quota of %d bytes exceeded, %d bytes in use", ErrOutOfMemory, quota, live)
+	}
+
+	var block *AllocatedBlock
+	var err error
+	if p := c.parentChild(); p != nil {
+		block, err = p.allocForChild(size, tag)
+	} else {
+		block, err = AllocTagged(c.parent, size, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.counters.alloc(size, tag)
+
+	return block, nil
+}
+
+// allocForChild allocates a block owned by a child of c, accounted by c too.
+func (c *ChildAllocator) allocForChild(size uintptr, tag Tag) (*AllocatedBlock, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.alloc(size, tag)
+}
+
+func (c *ChildAllocator) Free(block *AllocatedBlock) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.blocks[block]; !ok {
+		if block != nil && block.IsFreed() {
+			return ErrDoubleFree
+		}
+
+		return ErrForeignBlock
+	}
+
+	// the block may have been freed through the parent already
+	freed := block.IsFreed()
+	if err := c.free(block); err != nil {
+		return err
+	}
+	delete(c.blocks, block)
+
+	if freed {
+		return ErrDoubleFree
+	}
+
+	return nil
+}
+
+// free frees block through the parent and stops accounting it.
+// A block freed through the parent already is only no longer accounted.
+// It must be called with the mutex held.
+func (c *ChildAllocator) free(block *AllocatedBlock) error {
+	var err error
+	if p := c.parentChild(); p != nil {
+		err = p.freeForChild(block)
+	} else if !block.IsFreed() {
+		err = c.parent.Free(block)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.counters.free(block.Size(), block.Tag())
+
+	return nil
+}
+
+// freeForChild frees a block allocated with allocForChild.
+func (c *ChildAllocator) freeForChild(block *AllocatedBlock) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.free(block)
+}
+
+func (c *ChildAllocator) Copy(dst, src *AllocatedBlock) error {
+	c.mutex.Lock()
+	_, dstOk := c.blocks[dst]
+	_, srcOk := c.blocks[src]
+	c.mutex.Unlock()
+
+	if !dstOk || !srcOk {
+		return ErrForeignBlock
+	}
+
+	return c.copy(dst, src)
+}
+
+// copy copies through the first ancestor of c that is not a child allocator,
+// the parents that are child allocators do not own the blocks of their children.
+func (c *ChildAllocator) copy(dst, src *AllocatedBlock) error {
+	if p := c.parentChild(); p != nil {
+		return p.copy(dst, src)
+	}
+
+	return c.parent.Copy(dst, src)
+}
+
+// Close frees every block the child and its children still hold.
+// Allocations on a closed child fail with [ErrAllocatorClosed].
+func (c *ChildAllocator) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.close()
+	})
+
+	return err
+}
+
+func (c *ChildAllocator) close() error {
+	c.mutex.Lock()
+	c.closed = true
+	children := make([]*ChildAllocator, 0, len(c.children))
+	for child := range c.children {
+		children = append(children, child)
+	}
+	c.mutex.Unlock()
+
+	var errs []error
+	for _, child := range children {
+		if err := child.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	c.mutex.Lock()
+	for block := range c.blocks {
+		delete(c.blocks, block)
+		if err := c.free(block); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	c.mutex.Unlock()
+
+	if p := c.parentChild(); p != nil {
+		p.mutex.Lock()
+		delete(p.children, c)
+		p.mutex.Unlock()
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("goumem: could not free every block of the child allocator: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// SetQuota changes the quota of the child.
+// Blocks already allocated are kept even if they exceed the new quota.
+func (c *ChildAllocator) SetQuota(quota uintptr) {
+	c.quota.Store(quota)
+}
+
+func (c *ChildAllocator) Quota() uintptr {
+	return c.quota.Load()
+}
+
+func (c *ChildAllocator) Parent() MemoryAllocator {
+	return c.parent
+}
+
+// Stats returns the statistics of the child, including its own children.
+// The quota is reported as [Stats.HardLimit].
+func (c *ChildAllocator) Stats() Stats {
+	return Stats{
+		LiveBytes:      c.counters.liveBytes.Load(),
+		LiveBlocks:     c.counters.liveBlocks.Load(),
+		Allocs:         c.counters.allocs.Load(),
+		Frees:          c.counters.frees.Load(),
+		AllocatedBytes: c.counters.allocatedBytes.Load(),
+		HardLimit:      c.quota.Load(),
+		LimitFailures:  c.rejected.Load(),
+		Tags:           c.counters.tagStats(),
+	}
+}
+
+// WalkHeap calls fn for the live blocks of the child, in address order.
+// The blocks of its own children are not included.
+func (c *ChildAllocator) WalkHeap(fn func(block HeapBlock) bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	blocks := make([]HeapBlock, 0, len(c.blocks))
+	for block := range c.blocks {
+		heapBlock := HeapBlock{Addr: block.Addr(), Size: block.Size(), Block: block}
+		if block.chunk != nil {
+			heapBlock.Chunk = block.chunk.addr
+		}
+		blocks = append(blocks, heapBlock)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Addr < blocks[j].Addr
+	})
+
+	for _, block := range blocks {
+		if !fn(block) {
+			return
+		}
+	}
+}
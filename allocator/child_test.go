@@ -0,0 +1,184 @@
+package allocator
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ChildAllocatorTestSuite struct {
+	suite.Suite
+	parent MemoryAllocator
+}
+
+func (suite *ChildAllocatorTestSuite) SetupTest() {
+	suite.parent = Default()
+}
+
+func (suite *ChildAllocatorTestSuite) TestQuota() {
+	child := NewChildAllocator(suite.parent, 64)
+	defer child.Close()
+
+	block, err := child.Alloc(48)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	_, err = child.Alloc(32)
+	suite.ErrorIs(err, ErrOutOfMemory)
+
+	suite.NoError(child.Free(block))
+
+	block, err = child.Alloc(32)
+	suite.NoError(err)
+
+	stats := child.Stats()
+	suite.Equal(uintptr(32), stats.LiveBytes)
+	suite.Equal(uint64(1), stats.LiveBlocks)
+	suite.Equal(uintptr(64), stats.HardLimit)
+	suite.Equal(uint64(1), stats.LimitFailures)
+
+	child.SetQuota(16)
+	_, err = child.Alloc(1)
+	suite.ErrorIs(err, ErrOutOfMemory)
+}
+
+func (suite *ChildAllocatorTestSuite) TestRollUp() {
+	child := NewChildAllocator(suite.parent, 0)
+	grandchild := NewChildAllocator(child, 0)
+
+	_, err := grandchild.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	_, err = child.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.Equal(uintptr(16), grandchild.Stats().LiveBytes)
+	suite.Equal(uintptr(24), child.Stats().LiveBytes)
+
+	parentStats, _ := ReadStats(suite.parent)
+	suite.Equal(uintptr(24), parentStats.LiveBytes)
+	suite.Equal(uint64(2), parentStats.LiveBlocks)
+
+	// closing the child closes the grandchild too
+	suite.NoError(child.Close())
+
+	_, err = grandchild.Alloc(16)
+	suite.ErrorIs(err, ErrAllocatorClosed)
+	suite.Equal(uintptr(0), grandchild.Stats().LiveBytes)
+	suite.Equal(uintptr(0), child.Stats().LiveBytes)
+
+	parentStats, _ = ReadStats(suite.parent)
+	suite.Equal(uintptr(0), parentStats.LiveBytes)
+	suite.Equal(uint64(0), parentStats.LiveBlocks)
+}
+
+func (suite *ChildAllocatorTestSuite) TestForeignBlock() {
+	child := NewChildAllocator(suite.parent, 0)
+	defer child.Close()
+
+	block, err := suite.parent.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.ErrorIs(child.Free(block), ErrForeignBlock)
+	suite.NoError(suite.parent.Free(block))
+
+	block, err = child.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.NoError(child.Free(block))
+	suite.ErrorIs(child.Free(block), ErrAllocatedBlockAlreadyFreed)
+}
+
+func (suite *ChildAllocatorTestSuite) TestGrandchildBlock() {
+	child := NewChildAllocator(suite.parent, 0)
+	grandchild := NewChildAllocator(child, 0)
+
+	block, err := grandchild.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	// the block belongs to the grandchild only
+	suite.ErrorIs(child.Free(block), ErrForeignBlock)
+	suite.False(block.IsFreed())
+
+	other, err := grandchild.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.NoError(grandchild.Copy(other, block))
+
+	suite.NoError(grandchild.Close())
+	suite.True(block.IsFreed())
+	suite.Equal(uintptr(0), child.Stats().LiveBytes)
+	suite.NoError(child.Close())
+}
+
+func (suite *ChildAllocatorTestSuite) TestCloseErrors() {
+	child := NewChildAllocator(failingFreeAllocator{suite.parent}, 0)
+	for range 2 {
+		if _, err := child.Alloc(8); err != nil {
+			suite.FailNow("Failed to allocate block", err)
+		}
+	}
+
+	// every error is reported
+	err := child.Close()
+	suite.ErrorIs(err, errFreeFailed)
+
+	var joined interface{ Unwrap() []error }
+	suite.Require().ErrorAs(err, &joined)
+	suite.Len(joined.Unwrap(), 2)
+}
+
+func (suite *ChildAllocatorTestSuite) TestFreedThroughParent() {
+	child := NewChildAllocator(suite.parent, 0)
+
+	block, err := child.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	other, err := child.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.NoError(suite.parent.Free(block))
+	suite.NoError(suite.parent.Free(other))
+
+	// the child stops accounting the blocks, but reports the double free
+	suite.ErrorIs(child.Free(block), ErrDoubleFree)
+	suite.Equal(uintptr(16), child.Stats().LiveBytes)
+
+	suite.NoError(child.Close())
+	suite.Equal(uintptr(0), child.Stats().LiveBytes)
+}
+
+func (suite *ChildAllocatorTestSuite) TestFreeFailure() {
+	child := NewChildAllocator(failingFreeAllocator{suite.parent}, 0)
+
+	block, err := child.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	// the block stays accounted while the parent fails to free it
+	suite.ErrorIs(child.Free(block), errFreeFailed)
+	stats := child.Stats()
+	suite.Equal(uintptr(8), stats.LiveBytes)
+	suite.Equal(uint64(1), stats.LiveBlocks)
+	suite.Equal(uint64(0), stats.Frees)
+
+	suite.ErrorIs(child.Close(), errFreeFailed)
+	suite.NoError(suite.parent.Free(block))
+}
+
+func TestChildAllocatorTestSuite(t *testing.T) {
+	suite.Run(t, new(ChildAllocatorTestSuite))
+}
@@ -0,0 +1,98 @@
+package allocator_test
+
+import (
+	"github.com/exapsy/goumem/allocator"
+	"github.com/exapsy/goumem/allocator/allocatortest"
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/exapsy/goumem/mem_syscall/fake"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		allocatortest.Run(t, func() allocator.MemoryAllocator {
+			return allocator.Default()
+		})
+	})
+
+	t.Run("reserved", func(t *testing.T) {
+		allocatortest.RunTB(t, func(tb testing.TB) allocator.MemoryAllocator {
+			reserved, err := memsyscall.NewReserved(1 << 30)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			tb.Cleanup(func() { reserved.Close() })
+
+			return allocator.NewDefaultMemoryAllocator(allocator.WithSyscall(reserved))
+		})
+	})
+
+	t.Run("fake", func(t *testing.T) {
+		allocatortest.RunTB(t, func(tb testing.TB) allocator.MemoryAllocator {
+			sys := fake.New(64<<20, fake.WithPageSize(allocator.PageSize))
+			tb.Cleanup(func() { sys.Close() })
+
+			return allocator.NewDefaultMemoryAllocator(allocator.WithSyscall(sys))
+		})
+	})
+
+	t.Run("heap", func(t *testing.T) {
+		allocatortest.Run(t, func() allocator.MemoryAllocator {
+			return allocator.NewDefaultMemoryAllocator(allocator.WithSyscall(memsyscall.NewHeap()))
+		})
+	})
+
+	t.Run("histograms", func(t *testing.T) {
+		allocatortest.Run(t, func() allocator.MemoryAllocator {
+			return allocator.NewDefaultMemoryAllocator(allocator.WithHistograms())
+		})
+	})
+
+	t.Run("child", func(t *testing.T) {
+		allocatortest.Run(t, func() allocator.MemoryAllocator {
+			return allocator.NewChildAllocator(allocator.Default(), 0)
+		})
+	})
+
+	t.Run("observed", func(t *testing.T) {
+		allocatortest.Run(t, func() allocator.MemoryAllocator {
+			return allocator.NewObservedAllocator(allocator.Default(), allocator.ObserverFuncs{})
+		})
+	})
+
+	t.Run("guard", func(t *testing.T) {
+		if !memsyscall.New().Capabilities().Has(memsyscall.CapProtect) {
+			t.Skip("protect is not supported")
+		}
+
+		allocatortest.RunTB(t, func(tb testing.TB) allocator.MemoryAllocator {
+			guard, err := allocator.NewGuardAllocator(memsyscall.New())
+			if err != nil {
+				tb.Fatal(err)
+			}
+
+			return guard
+		})
+	})
+
+	t.Run("canary", func(t *testing.T) {
+		allocatortest.Run(t, func() allocator.MemoryAllocator {
+			return allocator.NewCanaryAllocator(allocator.Default())
+		})
+	})
+
+	t.Run("leakcheck", func(t *testing.T) {
+		allocatortest.Run(t, func() allocator.MemoryAllocator {
+			return allocator.NewLeakChecker(allocator.Default())
+		})
+	})
+}
+
+func FuzzConformance(f *testing.F) {
+	allocatortest.FuzzTB(f, func(tb testing.TB) allocator.MemoryAllocator {
+		sys := fake.New(64<<20, fake.WithPageSize(allocator.PageSize))
+		tb.Cleanup(func() { sys.Close() })
+
+		return allocator.NewDefaultMemoryAllocator(allocator.WithSyscall(sys))
+	})
+}
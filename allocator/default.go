@@ -1,11 +1,19 @@
 package allocator
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"sync"
+)
 
 var (
 	Default = func() MemoryAllocator { return NewDefaultMemoryAllocator() }
 )
 
+// Option configures the allocator returned by [NewDefaultMemoryAllocator].
+type Option func(a *defaultMemoryAllocator)
+
 type defaultAllocPolicy struct{}
 
 func newDefaultAllocPolicy() allocationPolicy {
@@ -13,33 +21,30 @@ func newDefaultAllocPolicy() allocationPolicy {
 }
 
 // SelectChunk selects a chunk from the list of chunks with more or equal free bytes than the size.
-func (p *defaultAllocPolicy) SelectChunk(chunkList *chunkList, size uintptr) *chunk {
+func (p *defaultAllocPolicy) SelectChunk(chunkList *chunkList, size uintptr) (*chunk, error) {
 	if allocThresholdWithoutAllocatingAnotherChunk >= size {
 		// threshold not reached
-		for i := 0; i < chunkList.len; i++ {
-			// select first chunk with enough free bytes
-			chunk := chunkList.chunks
-			if chunk != nil && chunk.freeBytes.Load() >= size {
-				return chunk
+		for chunk := chunkList.chunks; chunk != nil; chunk = chunk.next {
+			// select first chunk with a free block big enough
+			if chunk.freeBytes.Load() >= size && chunk.freeBlock(size) != nil {
+				return chunk, nil
 			}
 		}
+
+		chunkList.log.debug("goumem: no chunk with a free block big enough, mapping a new one", "size", size, "chunks", chunkList.len)
+	} else {
+		chunkList.log.debug("goumem: large allocation, mapping a chunk of its own", "size", size)
 	}
 
 	// chunk with this amount of free bytes not found
 	// or threshold is reached
 	// allocate new chunk
-	lastChunk := chunkList.chunks.get(chunkList.len - 1)
-	c, err := newChunk(size, lastChunk, nil)
+	c, err := chunkList.appendChunk(size, chunkList.mapFlags)
 	if err != nil {
-		fmt.Println("error allocating new chunk: ", err)
-		return nil
+		return nil, fmt.Errorf("error allocating new chunk: %w", err)
 	}
 
-	lastChunk.next = c
-	c.prev = lastChunk
-	chunkList.len++
-
-	return c
+	return c, nil
 }
 
 type defaultAllocStrategy struct {
@@ -52,62 +57,58 @@ func newDefaultAllocStrategy(allocPolicy allocationPolicy) allocationStrategy {
 	}
 }
 
-func (s *defaultAllocStrategy) alloc(chunks *chunkList, size uintptr) (*AllocatedBlock, error) {
-	c := s.allocationPolicy.SelectChunk(chunks, size)
-	if c == nil {
-		return nil, fmt.Errorf("no chunk found")
+func (s *defaultAllocStrategy) alloc(chunks *chunkList, size uintptr, tag Tag) (*AllocatedBlock, error) {
+	if err := chunks.mapFirst(); err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < len(c.blocks); i++ {
-		block := c.blocks[i]
-		if block.isFree.Load() && block.size.Load() >= size {
-			addr, err := c.splitAndGetFirstPart(block, size)
-			if err != nil {
-				return nil, err
-			}
+	c, err := s.allocationPolicy.SelectChunk(chunks, blockSize(size))
+	if err != nil {
+		return nil, err
+	}
+
+	return allocInChunk(c, size, tag)
+}
+
+// allocInChunk allocates a block of size bytes tagged with tag in the first free block of c big enough.
+// The block is complete by the time it is reachable from c.
+func allocInChunk(c *chunk, size uintptr, tag Tag) (*AllocatedBlock, error) {
+	if block := c.freeBlock(blockSize(size)); block != nil {
+		addr, err := c.splitAndGetFirstPart(block, blockSize(size))
+		if err != nil {
+			return nil, err
+		}
 
-			return &AllocatedBlock{
-				size:          size,
-				addr:          addr,
-				chunk:         c,
-				chunkBlockMem: block,
-			}, nil
+		block.allocated = &AllocatedBlock{
+			size:          size,
+			addr:          addr,
+			chunk:         c,
+			chunkBlockMem: block,
+			tag:           tag,
 		}
+
+		return block.allocated, nil
 	}
 
-	return nil, fmt.Errorf(
-		`no free block found. This should not happen
-as the policy should have selected a chunk with free bytes. chunk: %+v`, c,
-	)
+	return nil, fmt.Errorf("%w: chunk %#x selected without a free block of %d bytes", ErrHeapCorrupted, c.addr, blockSize(size))
 }
 
 func (s *defaultAllocStrategy) free(chunks *chunkList, block *AllocatedBlock) error {
-
+	block.chunk.freeBytes.Add(block.chunkBlockMem.size.Load())
 	block.chunkBlockMem.isFree.Store(true)
+	block.chunkBlockMem.allocated = nil
 
 	// merge adjacent blocks
 	// That is, if there are any adjacent blocks that are free.
 	// Improves fragmentation of memory,
 	// and we don't have to search
 	// and merge everytime on allocation very fragmented memory.
-	block.chunkBlockMem.mergeAdjacent()
+	block.chunk.mergeAdjacent(block.chunkBlockMem)
 
-	block.chunk.freeBytes.Add(block.size)
-
-	if block.chunk.freeBytes == block.chunk.size &&
-		chunks.freeBytes() > PageSize {
-
-		if block.chunk.prev == nil { // first chunk
-			block.chunk.next.prev = nil
-		}
-
-		if block.chunk.next == nil { // last chunk
-			block.chunk.prev.next = nil
-		}
-
-		// free chunk
-		// if it is the last chunk
-		// and the previous chunk has enough free bytes
+	// free the chunk if it is not used anymore,
+	// keeping at least one chunk around for small allocations.
+	if block.chunk.freeBytes.Load() == block.chunk.size.Load() &&
+		chunks.len > 1 {
 		err := chunks.freeChunk(block.chunk)
 		if err != nil {
 			return err
@@ -118,47 +119,243 @@ func (s *defaultAllocStrategy) free(chunks *chunkList, block *AllocatedBlock) er
 }
 
 type defaultMemoryAllocator struct {
+	mutex    sync.Mutex
 	strategy allocationStrategy
 	chunks   *chunkList
+	budget   *memoryBudget
+	syscall  memsyscall.Syscall
+	mapFlags memsyscall.MapFlags
+	counters allocatorCounters
+	// histograms are nil unless enabled with [WithHistograms].
+	histograms *histograms
+	observers
+	log logger
 }
 
-func NewDefaultMemoryAllocator() MemoryAllocator {
-	cl := newChunkList()
-	return &defaultMemoryAllocator{
+func NewDefaultMemoryAllocator(opts ...Option) MemoryAllocator {
+	a := &defaultMemoryAllocator{
 		strategy: newDefaultAllocStrategy(newDefaultAllocPolicy()),
-		chunks:   cl,
+		budget:   newMemoryBudget(),
+		syscall:  syscall,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	a.chunks = newChunkList(a.syscall, a.budget, a.mapFlags, a.log)
+	a.chunks.observers = &a.observers
+
+	return a
+}
+
+// WithSyscall makes the allocator map its chunks through sys
+// instead of the default syscalls of the system.
+func WithSyscall(sys memsyscall.Syscall) Option {
+	return func(a *defaultMemoryAllocator) {
+		a.syscall = sys
 	}
 }
 
 func (a *defaultMemoryAllocator) Alloc(size uintptr) (*AllocatedBlock, error) {
-	return a.strategy.alloc(a.chunks, size)
+	return a.AllocTagged(size, NoTag)
+}
+
+func (a *defaultMemoryAllocator) AllocTagged(size uintptr, tag Tag) (*AllocatedBlock, error) {
+	if err := checkSize(size); err != nil {
+		return nil, a.error("Alloc", err)
+	}
+
+	start := a.now()
+
+	a.mutex.Lock()
+	block, err := a.strategy.alloc(a.chunks, size, tag)
+	a.mutex.Unlock()
+
+	a.budget.notify()
+
+	if err != nil {
+		return nil, a.error("Alloc", err)
+	}
+
+	a.allocated(block, start)
+
+	return block, nil
+}
+
+// now returns the time an operation starts at, if the histograms need it.
+func (a *defaultMemoryAllocator) now() int64 {
+	if a.histograms == nil {
+		return 0
+	}
+
+	return nanotime()
+}
+
+// allocated accounts a new block, which allocation started at start.
+func (a *defaultMemoryAllocator) allocated(block *AllocatedBlock, start int64) {
+	a.counters.alloc(block.size, block.tag)
+	if a.histograms != nil {
+		a.histograms.alloc(block, start)
+	}
+	a.observers.alloc(block)
 }
 
 func (a *defaultMemoryAllocator) Free(block *AllocatedBlock) error {
-	if block.flags&AllocatedBlockFlagsFree != 0 {
-		return ErrAllocatedBlockAlreadyFreed
+	start := a.now()
+
+	err := a.free(block)
+	if err == nil && a.histograms != nil {
+		a.histograms.free(block, start)
 	}
 
-	block.flags |= AllocatedBlockFlagsFree
+	return a.error("Free", err)
+}
+
+func (a *defaultMemoryAllocator) free(block *AllocatedBlock) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if !a.owns(block) {
+		return ErrForeignBlock
+	}
+
+	if block.IsFreed() {
+		return ErrDoubleFree
+	}
+
+	a.observers.free(block)
+	block.markFreed()
 	block.addr = 0
+	a.counters.free(block.size, block.tag)
 
 	return a.strategy.free(a.chunks, block)
 }
 
 func (a *defaultMemoryAllocator) Copy(dst, src *AllocatedBlock) error {
-	if dst.flags&AllocatedBlockFlagsFree != 0 {
-		return ErrAllocatedBlockAlreadyFreed
+	return a.error("Copy", a.copy(dst, src))
+}
+
+func (a *defaultMemoryAllocator) copy(dst, src *AllocatedBlock) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if !a.owns(dst) || !a.owns(src) {
+		return ErrForeignBlock
+	}
+
+	if dst.IsFreed() {
+		return ErrDoubleFree
 	}
 
-	if src.flags&AllocatedBlockFlagsFree != 0 {
-		return ErrAllocatedBlockAlreadyFreed
+	if src.IsFreed() {
+		return ErrDoubleFree
 	}
 
 	if dst.size != src.size {
-		return ErrAllocatedBlockDifferentSize
+		return ErrSizeMismatch
 	}
 
-	dst.chunkBlockMem.copy(src.chunkBlockMem)
+	return src.chunkBlockMem.copy(dst.chunkBlockMem)
+}
 
-	return nil
+// owns reports whether block was allocated from the chunks of a.
+func (a *defaultMemoryAllocator) owns(block *AllocatedBlock) bool {
+	return block != nil && block.chunk != nil && block.chunk.list == a.chunks
+}
+
+// Close unmaps every chunk of the allocator, the one kept for small allocations included.
+// The blocks still allocated are freed with them, freeing them again returns [ErrDoubleFree].
+// The allocator maps a new chunk if it is used again.
+func (a *defaultMemoryAllocator) Close() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var errs []error
+	var next *chunk
+	for c := a.chunks.chunks; c != nil; c = next {
+		next = c.next
+
+		for _, chunkBlock := range c.blocks {
+			if block := chunkBlock.allocated; block != nil {
+				a.observers.free(block)
+				block.markFreed()
+				block.addr = 0
+				a.counters.free(block.size, block.tag)
+				chunkBlock.allocated = nil
+			}
+		}
+
+		if err := a.chunks.freeChunk(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a *defaultMemoryAllocator) Stats() Stats {
+	a.mutex.Lock()
+	chunks := a.chunks.len
+	mappedBytes := a.budget.mappedBytes.Load()
+	scavengedBytes := a.chunks.scavengedBytes()
+	details := a.chunks.stats()
+	a.mutex.Unlock()
+
+	var histograms *Histograms
+	if a.histograms != nil {
+		histograms = a.histograms.snapshot()
+	}
+
+	var gcLimit *GCLimitStats
+	if a.budget.gcLimit != nil {
+		stats := a.budget.gcLimit.Stats()
+		gcLimit = &stats
+	}
+
+	var hugePageChunks int
+	var hugePageBytes uintptr
+	for _, c := range details {
+		if c.HugePages() {
+			hugePageChunks++
+			hugePageBytes += c.Size
+		}
+	}
+
+	return Stats{
+		MappedBytes:    mappedBytes,
+		CommittedBytes: mappedBytes - scavengedBytes,
+		LiveBytes:      a.counters.liveBytes.Load(),
+		LiveBlocks:     a.counters.liveBlocks.Load(),
+		Chunks:         chunks,
+		Allocs:         a.counters.allocs.Load(),
+		Frees:          a.counters.frees.Load(),
+		AllocatedBytes: a.counters.allocatedBytes.Load(),
+		HardLimit:      a.budget.hardLimit.Load(),
+		SoftLimits:     a.budget.watermarks(),
+		LimitFailures:  a.budget.limitFailures.Load(),
+		ScavengedBytes: a.counters.scavenged.Load(),
+		HugePageChunks: hugePageChunks,
+		HugePageBytes:  hugePageBytes,
+		ChunkDetails:   details,
+		Histograms:     histograms,
+		GCLimit:        gcLimit,
+		Tags:           a.counters.tagStats(),
+	}
+}
+
+func (a *defaultMemoryAllocator) SetHardLimit(limit uintptr) {
+	a.budget.hardLimit.Store(limit)
+}
+
+func (a *defaultMemoryAllocator) HardLimit() uintptr {
+	return a.budget.hardLimit.Load()
+}
+
+func (a *defaultMemoryAllocator) SetSoftLimit(watermark uintptr, fn SoftLimitFunc) {
+	a.budget.setSoftLimit(watermark, fn)
+}
+
+func (a *defaultMemoryAllocator) RemoveSoftLimit(watermark uintptr) {
+	a.budget.removeSoftLimit(watermark)
 }
@@ -0,0 +1,55 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+)
+
+// MaxBlockSize is the largest size of a block, larger sizes fail with [ErrInvalidSize].
+const MaxBlockSize = ^uintptr(0) >> 2
+
+// The errors of the allocators, wrapped with details: compare them with [errors.Is].
+var (
+	// ErrOutOfMemory is returned when memory runs out, be it because of a hard limit,
+	// a quota, or the system refusing to map more, in which case the error also wraps ENOMEM.
+	ErrOutOfMemory = fmt.Errorf("goumem: out of memory")
+	// ErrInvalidSize is returned for sizes that can never be allocated.
+	ErrInvalidSize = fmt.Errorf("goumem: invalid size")
+	// ErrDoubleFree is returned when freeing or copying a block that was already freed.
+	ErrDoubleFree = fmt.Errorf("goumem: block already freed")
+	// ErrForeignBlock is returned for blocks that were not allocated by the allocator.
+	ErrForeignBlock = fmt.Errorf("goumem: block was not allocated by this allocator")
+	// ErrSizeMismatch is returned when copying between blocks of different sizes.
+	ErrSizeMismatch = fmt.Errorf("goumem: blocks of different sizes")
+	// ErrUnsupported is returned for operations the allocator or the system can not do.
+	// It is [memsyscall.ErrUnsupported], which wraps [errors.ErrUnsupported].
+	ErrUnsupported = memsyscall.ErrUnsupported
+
+	// Deprecated: use [ErrDoubleFree].
+	ErrAllocatedBlockAlreadyFreed = ErrDoubleFree
+	// Deprecated: use [ErrSizeMismatch].
+	ErrAllocatedBlockDifferentSize = ErrSizeMismatch
+)
+
+// checkSize returns an error wrapping [ErrInvalidSize] if size is larger than [MaxBlockSize].
+func checkSize(size uintptr) error {
+	if size > MaxBlockSize {
+		return fmt.Errorf("%w: %d bytes is more than the maximum of %d bytes", ErrInvalidSize, size, MaxBlockSize)
+	}
+
+	return nil
+}
+
+// mapError wraps err, returned by a syscall mapping memory, with the error of the allocator it amounts to.
+func mapError(err error) error {
+	switch {
+	case errors.Is(err, memsyscall.ErrNoMemory):
+		return fmt.Errorf("%w: %w", ErrOutOfMemory, err)
+	case errors.Is(err, memsyscall.ErrInvalid):
+		return fmt.Errorf("%w: %w", ErrInvalidSize, err)
+	}
+
+	return err
+}
@@ -0,0 +1,129 @@
+package allocator
+
+import (
+	"errors"
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/exapsy/goumem/mem_syscall/fake"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ErrorsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ErrorsTestSuite) TestInvalidSize() {
+	allocators := []MemoryAllocator{NewDefaultMemoryAllocator(), NewCanaryAllocator(Default())}
+	if guard, err := NewGuardAllocator(memsyscall.New()); err == nil {
+		allocators = append(allocators, guard)
+	}
+
+	for _, a := range allocators {
+		_, err := a.Alloc(MaxBlockSize + 1)
+		suite.ErrorIs(err, ErrInvalidSize)
+
+		_, err = a.Alloc(^uintptr(0))
+		suite.ErrorIs(err, ErrInvalidSize)
+	}
+
+	_, err := NewDefaultMemoryAllocator().(MappingAllocator).AllocMapped(^uintptr(0), 0)
+	suite.ErrorIs(err, ErrInvalidSize)
+}
+
+func (suite *ErrorsTestSuite) TestSystemOutOfMemory() {
+	a := NewDefaultMemoryAllocator(WithSyscall(fake.New(16*PageSize, fake.WithPageSize(PageSize))))
+
+	_, err := a.Alloc(32 * PageSize)
+	suite.ErrorIs(err, ErrOutOfMemory)
+	suite.ErrorIs(err, memsyscall.ErrNoMemory)
+}
+
+func (suite *ErrorsTestSuite) TestReservationExhausted() {
+	reserved, err := memsyscall.NewReserved(4 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to reserve memory", err)
+	}
+	defer reserved.Close()
+
+	_, err = NewDefaultMemoryAllocator(WithSyscall(reserved)).Alloc(8 * PageSize)
+	suite.ErrorIs(err, ErrOutOfMemory)
+	suite.ErrorIs(err, memsyscall.ErrReservationExhausted)
+}
+
+func (suite *ErrorsTestSuite) TestHardLimit() {
+	a := NewDefaultMemoryAllocator()
+	a.(MemoryLimiter).SetHardLimit(PageSize)
+
+	_, err := a.Alloc(2 * PageSize)
+	suite.ErrorIs(err, ErrOutOfMemory)
+	suite.ErrorContains(err, "hard limit reached")
+}
+
+func (suite *ErrorsTestSuite) TestMisuse() {
+	a := NewDefaultMemoryAllocator()
+
+	first, err := a.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	second, err := a.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.ErrorIs(a.Copy(first, second), ErrSizeMismatch)
+	suite.NoError(a.Free(first))
+	suite.ErrorIs(a.Free(first), ErrDoubleFree)
+	suite.ErrorIs(NewChildAllocator(a, 0).Free(second), ErrForeignBlock)
+	suite.NoError(a.Free(second))
+}
+
+func (suite *ErrorsTestSuite) TestForeignBlock() {
+	a := NewDefaultMemoryAllocator()
+	b := NewDefaultMemoryAllocator()
+
+	foreign, err := a.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	own, err := b.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.ErrorIs(b.Free(foreign), ErrForeignBlock)
+	suite.ErrorIs(b.Copy(own, foreign), ErrForeignBlock)
+	suite.ErrorIs(b.Copy(foreign, own), ErrForeignBlock)
+	suite.False(foreign.IsFreed())
+
+	stats, _ := ReadStats(b)
+	suite.Equal(uint64(1), stats.LiveBlocks)
+	suite.Equal(uintptr(16), stats.LiveBytes)
+
+	suite.NoError(a.Free(foreign))
+	suite.NoError(b.Free(own))
+}
+
+func (suite *ErrorsTestSuite) TestNilBlock() {
+	a := NewDefaultMemoryAllocator()
+
+	block, err := a.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.ErrorIs(a.Free(nil), ErrForeignBlock)
+	suite.ErrorIs(a.Copy(nil, block), ErrForeignBlock)
+	suite.ErrorIs(a.Copy(block, nil), ErrForeignBlock)
+	suite.NoError(a.Free(block))
+}
+
+func (suite *ErrorsTestSuite) TestAliases() {
+	suite.ErrorIs(ErrAllocatedBlockAlreadyFreed, ErrDoubleFree)
+	suite.ErrorIs(ErrAllocatedBlockDifferentSize, ErrSizeMismatch)
+	suite.ErrorIs(ErrUnsupported, errors.ErrUnsupported)
+}
+
+func TestErrorsTestSuite(t *testing.T) {
+	suite.Run(t, new(ErrorsTestSuite))
+}
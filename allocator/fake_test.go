@@ -0,0 +1,81 @@
+package allocator
+
+import (
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/exapsy/goumem/mem_syscall/fake"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type FakeSyscallTestSuite struct {
+	suite.Suite
+	sys *fake.Syscall
+	a   *defaultMemoryAllocator
+}
+
+func (suite *FakeSyscallTestSuite) SetupTest() {
+	suite.sys = fake.New(16*PageSize, fake.WithPageSize(PageSize))
+	suite.a = NewDefaultMemoryAllocator(WithSyscall(suite.sys)).(*defaultMemoryAllocator)
+}
+
+func (suite *FakeSyscallTestSuite) TestSmallAllocs() {
+	first, err := suite.a.Alloc(64)
+	suite.NoError(err)
+	second, err := suite.a.Alloc(64)
+	suite.NoError(err)
+	suite.NoError(suite.a.Free(first))
+	suite.NoError(suite.a.Free(second))
+
+	// small blocks are carved out of the initial chunk, which is never unmapped
+	calls := suite.sys.Calls()
+	suite.Len(calls, 1)
+	suite.Equal(fake.OpAlloc, calls[0].Op)
+	suite.Equal(PageSize, calls[0].Size)
+}
+
+func (suite *FakeSyscallTestSuite) TestLargeAlloc() {
+	block, err := suite.a.Alloc(2 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	addr := block.Addr()
+	suite.NoError(suite.a.Free(block))
+
+	calls := suite.sys.Calls()
+	suite.Equal([]fake.Call{
+		{Op: fake.OpAlloc, Addr: calls[0].Addr, Size: PageSize},
+		{Op: fake.OpAlloc, Addr: addr, Size: 2 * PageSize},
+		{Op: fake.OpFree, Addr: addr, Size: 2 * PageSize},
+	}, calls)
+	suite.Equal(PageSize, suite.sys.Mapped())
+}
+
+func (suite *FakeSyscallTestSuite) TestScavenge() {
+	block, err := suite.a.Alloc(64)
+	suite.NoError(err)
+	addr := block.Addr()
+	suite.NoError(suite.a.Free(block))
+
+	suite.sys.Reset()
+	suite.Equal(PageSize, suite.a.Scavenge())
+	suite.Equal([]fake.Call{
+		{Op: fake.OpAdvise, Addr: addr, Size: PageSize, Advice: memsyscall.AdviceFree},
+	}, suite.sys.Calls())
+
+	// nothing left to scavenge
+	suite.Zero(suite.a.Scavenge())
+	suite.Len(suite.sys.Calls(), 1)
+}
+
+func (suite *FakeSyscallTestSuite) TestAddressSpaceExhausted() {
+	_, err := suite.a.Alloc(16 * PageSize)
+	suite.ErrorIs(err, memsyscall.ErrNoMemory)
+
+	stats := suite.a.Stats()
+	suite.Equal(PageSize, stats.MappedBytes)
+	suite.Equal(1, stats.Chunks)
+}
+
+func TestFakeSyscallTestSuite(t *testing.T) {
+	suite.Run(t, new(FakeSyscallTestSuite))
+}
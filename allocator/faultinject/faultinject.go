@@ -0,0 +1,192 @@
+// Package faultinject makes allocations fail on purpose,
+// to test how code behaves when memory runs out.
+//
+// [Allocator] wraps an [allocator.MemoryAllocator] and fails allocations
+// with [allocator.ErrOutOfMemory], and [Syscall] wraps a [memsyscall.Syscall]
+// and fails mappings with ENOMEM, the same errors a real out of memory returns.
+// Which allocations fail is decided by rules.
+package faultinject
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/exapsy/goumem/allocator"
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+)
+
+type (
+	// Rule decides whether an allocation of size bytes fails.
+	// Rules may keep state, they are called with the lock of their wrapper held.
+	// The rules of a wrapper are called in order until one of them fails the allocation,
+	// so a rule only sees the allocations the rules before it let through.
+	Rule func(size uintptr) bool
+
+	// Allocator is an [allocator.MemoryAllocator] that fails allocations by rule.
+	// Free and Copy are passed through.
+	Allocator struct {
+		allocator.MemoryAllocator
+		injector
+	}
+
+	// Syscall is a [memsyscall.Syscall] that fails mappings by rule.
+	// Every other call is passed through.
+	Syscall struct {
+		memsyscall.Syscall
+		injector
+	}
+
+	injector struct {
+		mutex    sync.Mutex
+		rules    []Rule
+		failures atomic.Uint64
+	}
+)
+
+var (
+	_ allocator.MemoryAllocator = (*Allocator)(nil)
+	_ allocator.StatsReporter   = (*Allocator)(nil)
+	_ memsyscall.Syscall        = (*Syscall)(nil)
+	_ memsyscall.Mapper         = (*Syscall)(nil)
+)
+
+// EveryNth fails every nth allocation.
+func EveryNth(n uint64) Rule {
+	var calls uint64
+	return func(size uintptr) bool {
+		calls++
+		return n != 0 && calls%n == 0
+	}
+}
+
+// Probability fails allocations with probability p,
+// drawn from a random source seeded with seed so that runs are reproducible.
+func Probability(p float64, seed int64) Rule {
+	random := rand.New(rand.NewSource(seed))
+	return func(size uintptr) bool {
+		return random.Float64() < p
+	}
+}
+
+// AboveSize fails allocations of more than size bytes.
+func AboveSize(size uintptr) Rule {
+	return func(requested uintptr) bool {
+		return requested > size
+	}
+}
+
+// AfterBytes fails allocations that would take the bytes it let through over budget.
+// Pass it after the other rules, so that its budget only counts allocations they let through.
+func AfterBytes(budget uintptr) Rule {
+	var requested uintptr
+	return func(size uintptr) bool {
+		if size > budget-requested {
+			return true
+		}
+
+		requested += size
+		return false
+	}
+}
+
+// NewAllocator wraps a, failing any allocation one of rules fails.
+func NewAllocator(a allocator.MemoryAllocator, rules ...Rule) *Allocator {
+	return &Allocator{
+		MemoryAllocator: a,
+		injector:        injector{rules: rules},
+	}
+}
+
+func (a *Allocator) Alloc(size uintptr) (*allocator.AllocatedBlock, error) {
+	return a.AllocTagged(size, allocator.NoTag)
+}
+
+func (a *Allocator) AllocTagged(size uintptr, tag allocator.Tag) (*allocator.AllocatedBlock, error) {
+	if a.fail(size) {
+		return nil, fmt.Errorf("%w: injected failure allocating %d bytes", allocator.ErrOutOfMemory, size)
+	}
+
+	return allocator.AllocTagged(a.MemoryAllocator, size, tag)
+}
+
+// Stats returns the statistics of the wrapped allocator.
+// Injected failures are not part of them, they are counted by [Allocator.Failures].
+func (a *Allocator) Stats() allocator.Stats {
+	stats, _ := allocator.ReadStats(a.MemoryAllocator)
+	return stats
+}
+
+// NewSyscall wraps sys, failing any mapping one of rules fails.
+func NewSyscall(sys memsyscall.Syscall, rules ...Rule) *Syscall {
+	return &Syscall{
+		Syscall:  sys,
+		injector: injector{rules: rules},
+	}
+}
+
+func (s *Syscall) Alloc(size uintptr) (uintptr, error) {
+	if s.fail(size) {
+		return 0, s.error(size)
+	}
+
+	return s.Syscall.Alloc(size)
+}
+
+// Map maps like the wrapped syscall, if it is a [memsyscall.Mapper],
+// otherwise it falls back to Alloc and applies no flags.
+func (s *Syscall) Map(size uintptr, flags memsyscall.MapFlags) (uintptr, memsyscall.MapFlags, error) {
+	mapper, ok := s.Syscall.(memsyscall.Mapper)
+	if !ok {
+		addr, err := s.Alloc(size)
+		return addr, 0, err
+	}
+
+	if s.fail(size) {
+		return 0, 0, s.error(size)
+	}
+
+	return mapper.Map(size, flags)
+}
+
+func (s *Syscall) HugePageSize() uintptr {
+	if mapper, ok := s.Syscall.(memsyscall.Mapper); ok {
+		return mapper.HugePageSize()
+	}
+
+	return 0
+}
+
+// Remap fails like a mapping of newSize bytes.
+func (s *Syscall) Remap(addr, oldSize, newSize uintptr) (uintptr, error) {
+	if s.fail(newSize) {
+		return 0, s.error(newSize)
+	}
+
+	return s.Syscall.Remap(addr, oldSize, newSize)
+}
+
+func (s *Syscall) error(size uintptr) error {
+	return fmt.Errorf("faultinject: injected failure mapping %d bytes: %w", size, memsyscall.ErrNoMemory)
+}
+
+// Failures returns the count of failures injected so far.
+func (i *injector) Failures() uint64 {
+	return i.failures.Load()
+}
+
+// fail evaluates the rules in order, up to the first one that fails the allocation.
+func (i *injector) fail(size uintptr) bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	for _, rule := range i.rules {
+		if rule(size) {
+			i.failures.Add(1)
+			return true
+		}
+	}
+
+	return false
+}
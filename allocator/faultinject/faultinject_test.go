@@ -0,0 +1,115 @@
+package faultinject
+
+import (
+	"github.com/exapsy/goumem/allocator"
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/exapsy/goumem/mem_syscall/fake"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type FaultInjectTestSuite struct {
+	suite.Suite
+}
+
+func (suite *FaultInjectTestSuite) TestEveryNth() {
+	a := NewAllocator(allocator.NewDefaultMemoryAllocator(), EveryNth(3))
+
+	for i := 1; i <= 9; i++ {
+		block, err := a.Alloc(64)
+		if i%3 == 0 {
+			suite.ErrorIs(err, allocator.ErrOutOfMemory)
+			continue
+		}
+
+		suite.NoError(err)
+		suite.NoError(a.Free(block))
+	}
+
+	suite.Equal(uint64(3), a.Failures())
+	suite.Equal(uint64(0), a.Stats().LimitFailures)
+	suite.Equal(uint64(6), a.Stats().Allocs)
+}
+
+func (suite *FaultInjectTestSuite) TestProbability() {
+	run := func() []bool {
+		a := NewAllocator(allocator.NewDefaultMemoryAllocator(), Probability(0.5, 42))
+
+		failed := make([]bool, 0, 64)
+		for i := 0; i < 64; i++ {
+			block, err := a.Alloc(64)
+			failed = append(failed, err != nil)
+			if err == nil {
+				suite.NoError(a.Free(block))
+			}
+		}
+
+		return failed
+	}
+
+	// the same seed fails the same allocations
+	first := run()
+	suite.Equal(first, run())
+	suite.Contains(first, true)
+	suite.Contains(first, false)
+}
+
+func (suite *FaultInjectTestSuite) TestAboveSize() {
+	a := NewAllocator(allocator.NewDefaultMemoryAllocator(), AboveSize(128))
+
+	_, err := a.Alloc(128)
+	suite.NoError(err)
+	_, err = a.Alloc(129)
+	suite.ErrorIs(err, allocator.ErrOutOfMemory)
+}
+
+func (suite *FaultInjectTestSuite) TestAfterBytes() {
+	a := NewAllocator(allocator.NewDefaultMemoryAllocator(), AfterBytes(256))
+
+	_, err := a.Alloc(200)
+	suite.NoError(err)
+	_, err = a.Alloc(100)
+	suite.ErrorIs(err, allocator.ErrOutOfMemory)
+	_, err = a.Alloc(56)
+	suite.NoError(err)
+	_, err = a.Alloc(1)
+	suite.ErrorIs(err, allocator.ErrOutOfMemory)
+}
+
+func (suite *FaultInjectTestSuite) TestCombinedRules() {
+	a := NewAllocator(allocator.NewDefaultMemoryAllocator(), AboveSize(128), EveryNth(2), AfterBytes(256))
+
+	// the budget only counts the allocations the other rules let through
+	_, err := a.Alloc(200)
+	suite.ErrorIs(err, allocator.ErrOutOfMemory)
+	_, err = a.Alloc(100)
+	suite.NoError(err)
+	_, err = a.Alloc(100)
+	suite.ErrorIs(err, allocator.ErrOutOfMemory)
+	_, err = a.Alloc(100)
+	suite.NoError(err)
+	_, err = a.Alloc(50)
+	suite.ErrorIs(err, allocator.ErrOutOfMemory)
+	_, err = a.Alloc(56)
+	suite.NoError(err)
+
+	suite.Equal(uint64(3), a.Failures())
+	suite.Equal(uintptr(256), a.Stats().LiveBytes)
+}
+
+func (suite *FaultInjectTestSuite) TestSyscall() {
+	sys := NewSyscall(fake.New(1<<20, fake.WithPageSize(allocator.PageSize)), AboveSize(allocator.PageSize))
+	a := allocator.NewDefaultMemoryAllocator(allocator.WithSyscall(sys))
+
+	_, err := a.Alloc(64)
+	suite.NoError(err)
+
+	// large allocations map a chunk of their own
+	_, err = a.Alloc(2 * allocator.PageSize)
+	suite.ErrorIs(err, memsyscall.ErrNoMemory)
+	suite.Equal(uint64(1), sys.Failures())
+}
+
+func TestFaultInjectTestSuite(t *testing.T) {
+	suite.Run(t, new(FaultInjectTestSuite))
+}
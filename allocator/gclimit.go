@@ -0,0 +1,152 @@
+package allocator
+
+import (
+	"math"
+	"runtime/debug"
+	"sync"
+)
+
+type (
+	// GCLimitController shares a total memory budget between the Go heap and the allocators reporting to it.
+	// The runtime's memory limit ignores the memory the allocators map,
+	// so the controller sets it, with [debug.SetMemoryLimit], to the budget minus that memory,
+	// and adjusts it every time the allocators map or unmap memory.
+	//
+	// The memory limit is global to the process, there should be a single controller at a time.
+	GCLimitController struct {
+		budget  uintptr
+		minimum uintptr
+
+		mutex       sync.Mutex
+		offHeap     uintptr
+		limit       int64
+		adjustments uint64
+		clamped     bool
+		// previous is the memory limit before the controller, restored by Stop.
+		previous int64
+		stopped  bool
+	}
+
+	// GCLimitStats are the decisions of a [GCLimitController].
+	GCLimitStats struct {
+		// Budget is the memory shared between the Go heap and the allocators.
+		Budget uintptr
+		// Minimum is the lowest memory limit the controller sets.
+		Minimum uintptr
+		// OffHeapBytes is the memory mapped by the allocators reporting to the controller.
+		OffHeapBytes uintptr
+		// Limit is the memory limit of the Go runtime set by the controller.
+		Limit int64
+		// Adjustments is the count of times the controller changed the memory limit.
+		Adjustments uint64
+		// Clamped is set when the allocators leave less than Minimum of the budget to the Go heap,
+		// in which case the limit is Minimum and the budget is exceeded.
+		Clamped bool
+	}
+)
+
+// NewGCLimitController sets the memory limit of the Go runtime to budget
+// and lowers it as the allocators created [WithGCLimit] map memory,
+// never below minimum, so that a heap squeezed by off-heap memory does not collect continuously.
+func NewGCLimitController(budget, minimum uintptr) *GCLimitController {
+	c := &GCLimitController{
+		budget:   budget,
+		minimum:  minimum,
+		previous: debug.SetMemoryLimit(-1),
+	}
+
+	c.mutex.Lock()
+	c.adjust()
+	c.mutex.Unlock()
+
+	return c
+}
+
+// WithGCLimit reports the memory the allocator maps and unmaps to c.
+// One controller can take the reports of several allocators.
+func WithGCLimit(c *GCLimitController) Option {
+	return func(a *defaultMemoryAllocator) {
+		a.budget.gcLimit = c
+	}
+}
+
+// Stop restores the memory limit in effect before the controller was created,
+// the controller does not change it anymore.
+func (c *GCLimitController) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stopped {
+		return
+	}
+
+	c.stopped = true
+	debug.SetMemoryLimit(c.previous)
+}
+
+func (c *GCLimitController) Stats() GCLimitStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return GCLimitStats{
+		Budget:       c.budget,
+		Minimum:      c.minimum,
+		OffHeapBytes: c.offHeap,
+		Limit:        c.limit,
+		Adjustments:  c.adjustments,
+		Clamped:      c.clamped,
+	}
+}
+
+// mapped accounts size bytes mapped by an allocator.
+func (c *GCLimitController) mapped(size uintptr) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.offHeap += size
+	c.adjust()
+}
+
+// unmapped accounts size bytes unmapped by an allocator.
+func (c *GCLimitController) unmapped(size uintptr) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.offHeap -= size
+	c.adjust()
+}
+
+// adjust sets the memory limit to what the allocators leave of the budget.
+// It must be called with the mutex held.
+func (c *GCLimitController) adjust() {
+	if c.stopped {
+		return
+	}
+
+	left := c.minimum
+	c.clamped = c.offHeap > c.budget || c.budget-c.offHeap < c.minimum
+	if !c.clamped {
+		left = c.budget - c.offHeap
+	}
+
+	limit := int64(math.MaxInt64)
+	if uint64(left) < math.MaxInt64 {
+		limit = int64(left)
+	}
+
+	if limit == c.limit {
+		return
+	}
+
+	debug.SetMemoryLimit(limit)
+	c.limit = limit
+	c.adjustments++
+}
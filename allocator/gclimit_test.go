@@ -0,0 +1,109 @@
+package allocator
+
+import (
+	"github.com/stretchr/testify/suite"
+	"math"
+	"runtime/debug"
+	"testing"
+)
+
+type GCLimitTestSuite struct {
+	suite.Suite
+	previous int64
+}
+
+func (suite *GCLimitTestSuite) SetupTest() {
+	suite.previous = debug.SetMemoryLimit(-1)
+}
+
+func (suite *GCLimitTestSuite) TearDownTest() {
+	debug.SetMemoryLimit(suite.previous)
+}
+
+func (suite *GCLimitTestSuite) TestAdjust() {
+	c := NewGCLimitController(64*PageSize, 8*PageSize)
+	defer c.Stop()
+	suite.Equal(int64(64*PageSize), debug.SetMemoryLimit(-1))
+
+	a := NewDefaultMemoryAllocator(WithGCLimit(c))
+	block, err := a.Alloc(2 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	stats, _ := ReadStats(a)
+	suite.Require().NotNil(stats.GCLimit)
+	suite.Equal(stats.MappedBytes, stats.GCLimit.OffHeapBytes)
+	suite.Equal(int64(64*PageSize-stats.MappedBytes), stats.GCLimit.Limit)
+	suite.Equal(stats.GCLimit.Limit, debug.SetMemoryLimit(-1))
+	suite.False(stats.GCLimit.Clamped)
+	adjustments := stats.GCLimit.Adjustments
+
+	// freeing the block unmaps its chunk and gives the memory back to the heap
+	suite.NoError(a.Free(block))
+	stats, _ = ReadStats(a)
+	suite.Equal(stats.MappedBytes, stats.GCLimit.OffHeapBytes)
+	suite.Equal(int64(64*PageSize-stats.MappedBytes), debug.SetMemoryLimit(-1))
+	suite.Greater(stats.GCLimit.Adjustments, adjustments)
+}
+
+func (suite *GCLimitTestSuite) TestClamped() {
+	c := NewGCLimitController(8*PageSize, 4*PageSize)
+	defer c.Stop()
+
+	a := NewDefaultMemoryAllocator(WithGCLimit(c))
+	block, err := a.Alloc(8 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	stats := c.Stats()
+	suite.True(stats.Clamped)
+	suite.Equal(int64(4*PageSize), stats.Limit)
+	suite.Equal(int64(4*PageSize), debug.SetMemoryLimit(-1))
+
+	suite.NoError(a.Free(block))
+	suite.False(c.Stats().Clamped)
+}
+
+func (suite *GCLimitTestSuite) TestSharedBetweenAllocators() {
+	c := NewGCLimitController(64*PageSize, 0)
+	defer c.Stop()
+
+	a := NewDefaultMemoryAllocator(WithGCLimit(c))
+	b := NewDefaultMemoryAllocator(WithGCLimit(c))
+	for _, allocator := range []MemoryAllocator{a, b} {
+		if _, err := allocator.Alloc(PageSize); err != nil {
+			suite.FailNow("Failed to allocate block", err)
+		}
+	}
+
+	statsA, _ := ReadStats(a)
+	statsB, _ := ReadStats(b)
+	suite.Equal(statsA.MappedBytes+statsB.MappedBytes, c.Stats().OffHeapBytes)
+}
+
+func (suite *GCLimitTestSuite) TestStop() {
+	debug.SetMemoryLimit(math.MaxInt64 - 1)
+
+	c := NewGCLimitController(64*PageSize, 0)
+	suite.Equal(int64(64*PageSize), debug.SetMemoryLimit(-1))
+
+	c.Stop()
+	suite.Equal(int64(math.MaxInt64-1), debug.SetMemoryLimit(-1))
+
+	// the controller does not change the limit anymore
+	a := NewDefaultMemoryAllocator(WithGCLimit(c))
+	_, err := a.Alloc(PageSize)
+	suite.NoError(err)
+	suite.Equal(int64(math.MaxInt64-1), debug.SetMemoryLimit(-1))
+}
+
+func (suite *GCLimitTestSuite) TestDisabled() {
+	stats, _ := ReadStats(NewDefaultMemoryAllocator())
+	suite.Nil(stats.GCLimit)
+}
+
+func TestGCLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(GCLimitTestSuite))
+}
@@ -0,0 +1,161 @@
+package allocator
+
+import (
+	"fmt"
+	"sync"
+
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+)
+
+// GuardAllocator is a debugging allocator that maps every block on pages of its own,
+// followed by an inaccessible guard page, so that overflowing a block faults right away.
+// Freed blocks are made inaccessible and their pages are never reused,
+// so that using a block after it was freed faults too.
+//
+// Blocks end at the guard page, up to the padding that keeps them aligned to 16 bytes,
+// so overflows of less than 16 bytes may go unnoticed, [CanaryAllocator] catches those.
+// Every block costs at least two pages of address space, that is never given back,
+// though the memory of freed blocks is, if the system can advise it.
+type GuardAllocator struct {
+	mutex    sync.Mutex
+	sys      memsyscall.Syscall
+	pageSize uintptr
+	mappings map[*AllocatedBlock]guardMapping
+	mapped   uintptr
+	counters allocatorCounters
+}
+
+type guardMapping struct {
+	addr uintptr
+	size uintptr
+}
+
+// NewGuardAllocator creates a guard allocator that maps its blocks through sys,
+// which must support [memsyscall.CapProtect].
+func NewGuardAllocator(sys memsyscall.Syscall) (*GuardAllocator, error) {
+	if !sys.Capabilities().Has(memsyscall.CapProtect) {
+		return nil, fmt.Errorf("goumem: guard pages need memory protection: %w", ErrUnsupported)
+	}
+
+	return &GuardAllocator{
+		sys:      sys,
+		pageSize: sys.PageSize(),
+		mappings: map[*AllocatedBlock]guardMapping{},
+	}, nil
+}
+
+func (a *GuardAllocator) Alloc(size uintptr) (*AllocatedBlock, error) {
+	return a.AllocTagged(size, NoTag)
+}
+
+func (a *GuardAllocator) AllocTagged(size uintptr, tag Tag) (*AllocatedBlock, error) {
+	if err := checkSize(size); err != nil {
+		return nil, err
+	}
+
+	dataSize := alignUp(blockSize(size), a.pageSize)
+	mapping := guardMapping{size: dataSize + a.pageSize}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var err error
+	mapping.addr, err = a.sys.Alloc(mapping.size)
+	if err != nil {
+		return nil, fmt.Errorf("could not alloc memory: %w", mapError(err))
+	}
+
+	err = a.sys.Protect(mapping.addr+dataSize, a.pageSize, memsyscall.ProtNone)
+	if err != nil {
+		a.sys.Free(mapping.addr, mapping.size)
+		return nil, fmt.Errorf("could not protect guard page: %w", err)
+	}
+
+	block := &AllocatedBlock{
+		size: size,
+		addr: mapping.addr + dataSize - blockSize(size),
+		tag:  tag,
+	}
+	a.mappings[block] = mapping
+	a.mapped += mapping.size
+	a.counters.alloc(size, tag)
+
+	return block, nil
+}
+
+// Free makes the pages of the block inaccessible, without unmapping them.
+func (a *GuardAllocator) Free(block *AllocatedBlock) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	mapping, ok := a.mappings[block]
+	if !ok {
+		if block != nil && block.IsFreed() {
+			return ErrDoubleFree
+		}
+
+		return ErrForeignBlock
+	}
+
+	// give the memory back, keeping the address space
+	if a.sys.Capabilities().Has(memsyscall.CapAdvise) {
+		err := a.sys.Advise(mapping.addr, mapping.size-a.pageSize, memsyscall.AdviceDontNeed)
+		if err != nil {
+			return fmt.Errorf("could not release freed block: %w", err)
+		}
+	}
+
+	err := a.sys.Protect(mapping.addr, mapping.size-a.pageSize, memsyscall.ProtNone)
+	if err != nil {
+		return fmt.Errorf("could not protect freed block: %w", err)
+	}
+
+	delete(a.mappings, block)
+	block.markFreed()
+	block.addr = 0
+	a.counters.free(block.size, block.tag)
+
+	return nil
+}
+
+func (a *GuardAllocator) Copy(dst, src *AllocatedBlock) error {
+	a.mutex.Lock()
+	_, dstOk := a.mappings[dst]
+	_, srcOk := a.mappings[src]
+	a.mutex.Unlock()
+
+	if !dstOk || !srcOk {
+		if (dst != nil && dst.IsFreed()) || (src != nil && src.IsFreed()) {
+			return ErrDoubleFree
+		}
+
+		return ErrForeignBlock
+	}
+
+	if dst.size != src.size {
+		return ErrSizeMismatch
+	}
+
+	copy(dst.Bytes(), src.Bytes())
+
+	return nil
+}
+
+// Stats returns the statistics of the allocator.
+// The pages of the freed blocks are still accounted in [Stats.MappedBytes].
+func (a *GuardAllocator) Stats() Stats {
+	a.mutex.Lock()
+	mapped := a.mapped
+	a.mutex.Unlock()
+
+	return Stats{
+		MappedBytes:    mapped,
+		CommittedBytes: mapped,
+		LiveBytes:      a.counters.liveBytes.Load(),
+		LiveBlocks:     a.counters.liveBlocks.Load(),
+		Allocs:         a.counters.allocs.Load(),
+		Frees:          a.counters.frees.Load(),
+		AllocatedBytes: a.counters.allocatedBytes.Load(),
+		Tags:           a.counters.tagStats(),
+	}
+}
@@ -0,0 +1,108 @@
+package allocator
+
+import (
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/exapsy/goumem/mem_syscall/fake"
+	"github.com/stretchr/testify/suite"
+	"runtime/debug"
+	"testing"
+	"unsafe"
+)
+
+type GuardAllocatorTestSuite struct {
+	suite.Suite
+	guard *GuardAllocator
+}
+
+func (suite *GuardAllocatorTestSuite) SetupTest() {
+	if !memsyscall.New().Capabilities().Has(memsyscall.CapProtect) {
+		suite.T().Skip("protect is not supported")
+	}
+
+	guard, err := NewGuardAllocator(memsyscall.New())
+	if err != nil {
+		suite.FailNow("Failed to create guard allocator", err)
+	}
+
+	suite.guard = guard
+}
+
+func (suite *GuardAllocatorTestSuite) TestBlockEndsAtGuardPage() {
+	block, err := suite.guard.Alloc(100)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	end := block.Addr() + blockSize(block.Size())
+	suite.Zero(end % PageSize)
+
+	suite.True(faults(func() { *(*byte)(unsafe.Pointer(end)) = 1 }))
+	suite.False(faults(func() { block.Bytes()[99] = 1 }))
+
+	suite.NoError(suite.guard.Free(block))
+}
+
+func (suite *GuardAllocatorTestSuite) TestUseAfterFree() {
+	block, err := suite.guard.Alloc(64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	addr := block.Addr()
+	suite.NoError(suite.guard.Free(block))
+	suite.True(faults(func() { *(*byte)(unsafe.Pointer(addr)) = 1 }))
+
+	// the freed pages are not reused
+	other, err := suite.guard.Alloc(64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.NotEqual(addr, other.Addr())
+	suite.NoError(suite.guard.Free(other))
+}
+
+func (suite *GuardAllocatorTestSuite) TestStats() {
+	block, err := suite.guard.Alloc(64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	stats := suite.guard.Stats()
+	suite.Equal(uint64(1), stats.LiveBlocks)
+	suite.Equal(2*PageSize, stats.MappedBytes)
+
+	suite.NoError(suite.guard.Free(block))
+	stats = suite.guard.Stats()
+	suite.Zero(stats.LiveBlocks)
+	suite.Equal(2*PageSize, stats.MappedBytes)
+}
+
+func (suite *GuardAllocatorTestSuite) TestForeignBlock() {
+	block, err := Default().Alloc(64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.ErrorIs(suite.guard.Free(block), ErrForeignBlock)
+}
+
+func (suite *GuardAllocatorTestSuite) TestNeedsProtect() {
+	_, err := NewGuardAllocator(fake.New(1<<20, fake.WithCapabilities(0)))
+	suite.ErrorIs(err, memsyscall.ErrUnsupported)
+}
+
+// faults reports whether f faults accessing memory.
+func faults(f func()) (faulted bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		faulted = recover() != nil
+	}()
+
+	f()
+
+	return false
+}
+
+func TestGuardAllocatorTestSuite(t *testing.T) {
+	suite.Run(t, new(GuardAllocatorTestSuite))
+}
@@ -0,0 +1,248 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrInvalidHandle = fmt.Errorf("goumem: invalid handle")
+	ErrStaleHandle   = fmt.Errorf("goumem: stale handle, block was freed")
+)
+
+type (
+	// Handle is a stable reference to a block owned by a [HandleTable].
+	//
+	// The lower 32 bits are the slot index in the table
+	// and the upper 32 bits are the generation of the slot.
+	// Every time a slot is released its generation is bumped,
+	// so a handle to a freed block never resolves to the block that reused the slot.
+	//
+	// The zero value is never a valid handle.
+	Handle uint64
+
+	// HandleTable hands out generational handles for blocks allocated
+	// by any [MemoryAllocator].
+	// Off-heap structures can store handles instead of raw addresses
+	// and detect stale references deterministically with [HandleTable.Resolve].
+	HandleTable struct {
+		allocator MemoryAllocator
+		mutex     sync.RWMutex
+		slots     []handleSlot
+		freeSlots []uint32
+	}
+	handleSlot struct {
+		block *AllocatedBlock
+		// addr is the address of the block, read by Resolve without touching the block,
+		// which may be freed concurrently through its allocator.
+		addr uintptr
+		// generation starts at 1, so that the zero Handle is always invalid.
+		generation uint32
+	}
+)
+
+// NilHandle is the zero Handle, it never resolves.
+const NilHandle Handle = 0
+
+func newHandle(index, generation uint32) Handle {
+	return Handle(uint64(generation)<<32 | uint64(index))
+}
+
+func (h Handle) Index() uint32 {
+	return uint32(h)
+}
+
+func (h Handle) Generation() uint32 {
+	return uint32(h >> 32)
+}
+
+func (h Handle) String() string {
+	return fmt.Sprintf("handle(%d#%d)", h.Index(), h.Generation())
+}
+
+// NewHandleTable creates a handle table that allocates and frees through allocator.
+func NewHandleTable(allocator MemoryAllocator) *HandleTable {
+	return &HandleTable{
+		allocator: allocator,
+	}
+}
+
+// Alloc allocates a block of size bytes and returns a handle to it.
+func (t *HandleTable) Alloc(size uintptr) (Handle, error) {
+	block, err := t.allocator.Alloc(size)
+	if err != nil {
+		return NilHandle, err
+	}
+
+	return t.Register(block), nil
+}
+
+// Register hands out a handle for a block that has already been allocated
+// by the table's allocator. The table takes ownership of the block.
+// A nil block gets [NilHandle], which never resolves.
+func (t *HandleTable) Register(block *AllocatedBlock) Handle {
+	if block == nil {
+		return NilHandle
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if n := len(t.freeSlots); n > 0 {
+		index := t.freeSlots[n-1]
+		t.freeSlots = t.freeSlots[:n-1]
+		t.slots[index].block = block
+		t.slots[index].addr = block.Addr()
+
+		return newHandle(index, t.slots[index].generation)
+	}
+
+	index := uint32(len(t.slots))
+	t.slots = append(t.slots, handleSlot{block: block, addr: block.Addr(), generation: 1})
+
+	return newHandle(index, 1)
+}
+
+// Resolve returns the address of the block referenced by h.
+// It returns [ErrStaleHandle] if the block has been freed since the handle was handed out.
+func (t *HandleTable) Resolve(h Handle) (uintptr, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	slot, err := t.slot(h)
+	if err != nil {
+		return 0, err
+	}
+
+	return slot.addr, nil
+}
+
+// Block returns the block referenced by h.
+func (t *HandleTable) Block(h Handle) (*AllocatedBlock, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	slot, err := t.slot(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return slot.block, nil
+}
+
+// Valid reports whether h still references a live block.
+func (t *HandleTable) Valid(h Handle) bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	_, err := t.slot(h)
+	return err == nil
+}
+
+// Free frees the block referenced by h and invalidates every copy of the handle.
+// If the allocator fails to free the block, the handle stays valid.
+func (t *HandleTable) Free(h Handle) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	slot, err := t.writableSlot(h)
+	if err != nil {
+		return err
+	}
+
+	err = t.allocator.Free(slot.block)
+	if err != nil {
+		return err
+	}
+
+	t.release(h.Index())
+
+	return nil
+}
+
+// Release invalidates h and gives the ownership of its block back to the caller,
+// without freeing it.
+func (t *HandleTable) Release(h Handle) (*AllocatedBlock, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	slot, err := t.writableSlot(h)
+	if err != nil {
+		return nil, err
+	}
+
+	block := slot.block
+	t.release(h.Index())
+
+	return block, nil
+}
+
+// Reclaim invalidates the handles of the blocks freed directly through the allocator,
+// so that their slots can be reused, and returns how many it invalidated.
+// Free and Release reclaim the slot of such a handle on their own.
+func (t *HandleTable) Reclaim() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	reclaimed := 0
+	for i := range t.slots {
+		if block := t.slots[i].block; block != nil && block.IsFreed() {
+			t.release(uint32(i))
+			reclaimed++
+		}
+	}
+
+	return reclaimed
+}
+
+// release invalidates the slot at index and puts it back in the free list.
+// It must be called with the mutex held.
+func (t *HandleTable) release(index uint32) {
+	slot := &t.slots[index]
+	slot.block = nil
+	slot.addr = 0
+	slot.generation++
+	if slot.generation == 0 { // wrapped around, skip the invalid generation
+		slot.generation = 1
+	}
+	t.freeSlots = append(t.freeSlots, index)
+}
+
+// Len returns the number of live handles,
+// counting the ones of blocks freed directly through the allocator until they are reclaimed.
+func (t *HandleTable) Len() int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return len(t.slots) - len(t.freeSlots)
+}
+
+// writableSlot is slot, that also reclaims the slot of h
+// if its block was freed directly through the allocator.
+// It must be called with the mutex held for writing.
+func (t *HandleTable) writableSlot(h Handle) (*handleSlot, error) {
+	slot, err := t.slot(h)
+	if errors.Is(err, ErrStaleHandle) {
+		if stale := &t.slots[h.Index()]; stale.generation == h.Generation() && stale.block != nil {
+			t.release(h.Index())
+		}
+	}
+
+	return slot, err
+}
+
+// slot must be called with the mutex held.
+func (t *HandleTable) slot(h Handle) (*handleSlot, error) {
+	index := h.Index()
+	if h == NilHandle || int(index) >= len(t.slots) {
+		return nil, ErrInvalidHandle
+	}
+
+	slot := &t.slots[index]
+	if slot.generation != h.Generation() || slot.block == nil || slot.block.IsFreed() {
+		return nil, ErrStaleHandle
+	}
+
+	return slot, nil
+}
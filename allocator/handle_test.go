@@ -0,0 +1,166 @@
+package allocator
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type HandleTableTestSuite struct {
+	suite.Suite
+	mem   MemoryAllocator
+	table *HandleTable
+}
+
+func (suite *HandleTableTestSuite) SetupTest() {
+	suite.mem = Default()
+	suite.table = NewHandleTable(suite.mem)
+}
+
+func (suite *HandleTableTestSuite) TestResolve() {
+	h, err := suite.table.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate handle", err)
+	}
+
+	block, err := suite.table.Block(h)
+	suite.NoError(err)
+
+	addr, err := suite.table.Resolve(h)
+	suite.NoError(err)
+	suite.Equal(block.Addr(), addr)
+	suite.True(suite.table.Valid(h))
+	suite.Equal(1, suite.table.Len())
+
+	suite.NoError(suite.table.Free(h))
+	suite.Equal(0, suite.table.Len())
+}
+
+func (suite *HandleTableTestSuite) TestStaleHandle() {
+	h, err := suite.table.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate handle", err)
+	}
+
+	suite.NoError(suite.table.Free(h))
+
+	// the slot is reused by the next allocation
+	reused, err := suite.table.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate handle", err)
+	}
+	suite.Equal(h.Index(), reused.Index())
+	suite.NotEqual(h.Generation(), reused.Generation())
+
+	_, err = suite.table.Resolve(h)
+	suite.ErrorIs(err, ErrStaleHandle)
+	suite.False(suite.table.Valid(h))
+	suite.ErrorIs(suite.table.Free(h), ErrStaleHandle)
+
+	_, err = suite.table.Resolve(reused)
+	suite.NoError(err)
+
+	suite.NoError(suite.table.Free(reused))
+}
+
+func (suite *HandleTableTestSuite) TestInvalidHandle() {
+	_, err := suite.table.Resolve(NilHandle)
+	suite.ErrorIs(err, ErrInvalidHandle)
+
+	_, err = suite.table.Resolve(newHandle(42, 1))
+	suite.ErrorIs(err, ErrInvalidHandle)
+}
+
+func (suite *HandleTableTestSuite) TestRegisterNil() {
+	h := suite.table.Register(nil)
+	suite.Equal(NilHandle, h)
+	suite.False(suite.table.Valid(h))
+	suite.Equal(0, suite.table.Len())
+}
+
+func (suite *HandleTableTestSuite) TestRelease() {
+	block, err := suite.mem.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	h := suite.table.Register(block)
+
+	released, err := suite.table.Release(h)
+	suite.NoError(err)
+	suite.Same(block, released)
+	suite.False(released.IsFreed())
+
+	_, err = suite.table.Block(h)
+	suite.ErrorIs(err, ErrStaleHandle)
+
+	suite.NoError(suite.mem.Free(released))
+}
+
+func (suite *HandleTableTestSuite) TestFreeFailure() {
+	table := NewHandleTable(failingFreeAllocator{suite.mem})
+	h, err := table.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate handle", err)
+	}
+
+	// the block can still be reached through the table
+	suite.ErrorIs(table.Free(h), errFreeFailed)
+	suite.True(table.Valid(h))
+
+	block, err := table.Release(h)
+	suite.NoError(err)
+	suite.NoError(suite.mem.Free(block))
+}
+
+func (suite *HandleTableTestSuite) TestReclaim() {
+	a := Default()
+	table := NewHandleTable(a)
+
+	handles := make([]Handle, 3)
+	for i := range handles {
+		h, err := table.Alloc(8)
+		if err != nil {
+			suite.FailNow("Failed to allocate handle", err)
+		}
+		handles[i] = h
+	}
+
+	// blocks freed directly through the allocator
+	for _, h := range handles[:2] {
+		block, err := table.Block(h)
+		suite.NoError(err)
+		suite.NoError(a.Free(block))
+	}
+	suite.Equal(3, table.Len())
+
+	suite.ErrorIs(table.Free(handles[0]), ErrStaleHandle)
+	suite.Equal(2, table.Len())
+
+	suite.Equal(1, table.Reclaim())
+	suite.Equal(1, table.Len())
+
+	reused, err := table.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate handle", err)
+	}
+	suite.Less(reused.Index(), handles[2].Index())
+
+	suite.NoError(table.Free(reused))
+	suite.NoError(table.Free(handles[2]))
+}
+
+var errFreeFailed = fmt.Errorf("free failed")
+
+// failingFreeAllocator fails every free.
+type failingFreeAllocator struct {
+	MemoryAllocator
+}
+
+func (failingFreeAllocator) Free(block *AllocatedBlock) error {
+	return errFreeFailed
+}
+
+func TestHandleTableTestSuite(t *testing.T) {
+	suite.Run(t, new(HandleTableTestSuite))
+}
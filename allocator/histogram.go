@@ -0,0 +1,136 @@
+package allocator
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// histogramBuckets is the count of buckets of a [Histogram], one per power of two of a uint64, and one for zero.
+const histogramBuckets = 65
+
+type (
+	// Histogram counts values in buckets of powers of two:
+	// Buckets[0] counts zeros, and Buckets[i] the values in [2^(i-1), 2^i).
+	Histogram struct {
+		Buckets [histogramBuckets]uint64
+	}
+
+	// Histograms are the distributions kept by allocators created with [WithHistograms].
+	Histograms struct {
+		// Size is the distribution of the sizes requested, in bytes.
+		Size Histogram
+		// AllocLatency and FreeLatency are the distributions of the time Alloc and Free took.
+		AllocLatency Histogram
+		FreeLatency  Histogram
+		// Lifetime is the distribution of the age of the blocks when they were freed.
+		Lifetime Histogram
+	}
+
+	histogram struct {
+		buckets [histogramBuckets]atomic.Uint64
+	}
+
+	histograms struct {
+		size         histogram
+		allocLatency histogram
+		freeLatency  histogram
+		lifetime     histogram
+	}
+)
+
+// epoch is the origin of the monotonic times kept by the histograms.
+var epoch = time.Now()
+
+// WithHistograms makes the allocator keep the histograms of the sizes requested,
+// of the latency of Alloc and Free, and of the lifetime of the blocks, reported in [Stats.Histograms].
+// They cost a few atomic increments and reads of the clock per operation.
+func WithHistograms() Option {
+	return func(a *defaultMemoryAllocator) {
+		a.histograms = &histograms{}
+	}
+}
+
+// Count returns the count of values in the histogram.
+func (h Histogram) Count() uint64 {
+	var count uint64
+	for _, n := range h.Buckets {
+		count += n
+	}
+
+	return count
+}
+
+// Quantile returns an upper bound of the q-quantile of the values, with 0 <= q <= 1:
+// the upper bound of the bucket it falls in.
+func (h Histogram) Quantile(q float64) uint64 {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(count))
+	if rank >= count {
+		rank = count - 1
+	}
+
+	var seen uint64
+	for i, n := range h.Buckets {
+		seen += n
+		if seen > rank {
+			return HistogramBucketBound(i)
+		}
+	}
+
+	return HistogramBucketBound(histogramBuckets - 1)
+}
+
+// HistogramBucketBound returns the largest value counted in the bucket i of a [Histogram].
+func HistogramBucketBound(i int) uint64 {
+	if i == 0 {
+		return 0
+	}
+
+	return 1<<uint(i) - 1
+}
+
+func (h *histogram) record(v uint64) {
+	h.buckets[bits.Len64(v)].Add(1)
+}
+
+func (h *histogram) snapshot() Histogram {
+	var snapshot Histogram
+	for i := range h.buckets {
+		snapshot.Buckets[i] = h.buckets[i].Load()
+	}
+
+	return snapshot
+}
+
+// nanotime returns the monotonic time since [epoch], in nanoseconds.
+func nanotime() int64 {
+	return int64(time.Since(epoch))
+}
+
+// alloc records the allocation of block, that started at start.
+func (h *histograms) alloc(block *AllocatedBlock, start int64) {
+	now := nanotime()
+	block.allocated = now
+	h.size.record(uint64(block.size))
+	h.allocLatency.record(uint64(now - start))
+}
+
+// free records the free of block, that started at start.
+func (h *histograms) free(block *AllocatedBlock, start int64) {
+	h.freeLatency.record(uint64(nanotime() - start))
+	h.lifetime.record(uint64(start - block.allocated))
+}
+
+func (h *histograms) snapshot() *Histograms {
+	return &Histograms{
+		Size:         h.size.snapshot(),
+		AllocLatency: h.allocLatency.snapshot(),
+		FreeLatency:  h.freeLatency.snapshot(),
+		Lifetime:     h.lifetime.snapshot(),
+	}
+}
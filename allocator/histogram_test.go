@@ -0,0 +1,93 @@
+package allocator
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type HistogramTestSuite struct {
+	suite.Suite
+}
+
+func (suite *HistogramTestSuite) TestBuckets() {
+	var h histogram
+	for _, v := range []uint64{0, 1, 2, 3, 4, 1000, 1 << 63} {
+		h.record(v)
+	}
+
+	snapshot := h.snapshot()
+	suite.Equal(uint64(7), snapshot.Count())
+	suite.Equal(uint64(1), snapshot.Buckets[0])
+	suite.Equal(uint64(1), snapshot.Buckets[1])
+	suite.Equal(uint64(2), snapshot.Buckets[2])
+	suite.Equal(uint64(1), snapshot.Buckets[3])
+	suite.Equal(uint64(1), snapshot.Buckets[10])
+	suite.Equal(uint64(1), snapshot.Buckets[64])
+}
+
+func (suite *HistogramTestSuite) TestQuantile() {
+	var h histogram
+	suite.Zero(h.snapshot().Quantile(0.5))
+
+	for i := 0; i < 90; i++ {
+		h.record(10)
+	}
+	for i := 0; i < 10; i++ {
+		h.record(5000)
+	}
+
+	snapshot := h.snapshot()
+	suite.Equal(uint64(15), snapshot.Quantile(0))
+	suite.Equal(uint64(15), snapshot.Quantile(0.5))
+	suite.Equal(uint64(8191), snapshot.Quantile(0.95))
+	suite.Equal(uint64(8191), snapshot.Quantile(1))
+}
+
+func (suite *HistogramTestSuite) TestBucketBound() {
+	suite.Equal(uint64(0), HistogramBucketBound(0))
+	suite.Equal(uint64(1), HistogramBucketBound(1))
+	suite.Equal(uint64(1023), HistogramBucketBound(10))
+	suite.Equal(^uint64(0), HistogramBucketBound(64))
+}
+
+func (suite *HistogramTestSuite) TestWithHistograms() {
+	a := NewDefaultMemoryAllocator(WithHistograms())
+
+	small, err := a.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	large, err := a.Alloc(1000)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	time.Sleep(time.Millisecond)
+	suite.NoError(a.Free(small))
+	suite.ErrorIs(a.Free(small), ErrAllocatedBlockAlreadyFreed)
+
+	stats, _ := ReadStats(a)
+	if stats.Histograms == nil {
+		suite.FailNow("No histograms")
+	}
+
+	h := stats.Histograms
+	suite.Equal(uint64(1), h.Size.Buckets[5])
+	suite.Equal(uint64(1), h.Size.Buckets[10])
+	suite.Equal(uint64(2), h.AllocLatency.Count())
+	suite.Equal(uint64(1), h.FreeLatency.Count())
+	suite.Equal(uint64(1), h.Lifetime.Count())
+	suite.True(h.Lifetime.Quantile(0) >= uint64(time.Millisecond))
+
+	suite.NoError(a.Free(large))
+}
+
+func (suite *HistogramTestSuite) TestDisabled() {
+	stats, _ := ReadStats(NewDefaultMemoryAllocator())
+	suite.Nil(stats.Histograms)
+}
+
+func TestHistogramTestSuite(t *testing.T) {
+	suite.Run(t, new(HistogramTestSuite))
+}
@@ -0,0 +1,19 @@
+package allocator
+
+import memsyscall "github.com/exapsy/goumem/mem_syscall"
+
+// hugePageFlags are the map flags that request huge pages.
+const hugePageFlags = memsyscall.MapHugeTLB | memsyscall.MapHugePageAdvise
+
+// WithHugePages backs the chunks of at least one huge page with huge pages,
+// rounding their size up to a multiple of the huge page size.
+//
+// flags is [memsyscall.MapHugeTLB] and/or [memsyscall.MapHugePageAdvise].
+// A chunk that can not get huge pages falls back to transparent huge pages,
+// and then to regular pages, so the allocation never fails because of it.
+// [Stats.ChunkDetails] reports which chunks actually got huge pages.
+func WithHugePages(flags memsyscall.MapFlags) Option {
+	return func(a *defaultMemoryAllocator) {
+		a.mapFlags |= flags & hugePageFlags
+	}
+}
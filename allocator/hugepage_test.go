@@ -0,0 +1,59 @@
+package allocator
+
+import (
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type HugePagesTestSuite struct {
+	suite.Suite
+	hugePageSize uintptr
+}
+
+func (suite *HugePagesTestSuite) SetupTest() {
+	mapper, ok := syscall.(memsyscall.Mapper)
+	if !ok || mapper.HugePageSize() == 0 {
+		suite.T().Skip("huge pages are not supported")
+	}
+
+	suite.hugePageSize = mapper.HugePageSize()
+}
+
+func (suite *HugePagesTestSuite) TestLargeChunks() {
+	a := NewDefaultMemoryAllocator(WithHugePages(memsyscall.MapHugeTLB | memsyscall.MapHugePageAdvise))
+
+	small, err := a.Alloc(PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	large, err := a.Alloc(suite.hugePageSize + PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	Set(large, [4]int{1, 2, 3, 4})
+	suite.Equal([4]int{1, 2, 3, 4}, Get[[4]int](large))
+
+	stats, _ := ReadStats(a)
+	suite.Len(stats.ChunkDetails, 3)
+
+	// small chunks keep regular pages
+	suite.Equal(PageSize, stats.ChunkDetails[1].Size)
+	suite.False(stats.ChunkDetails[1].HugePages())
+
+	// large chunks are rounded to the huge page size
+	suite.Equal(2*suite.hugePageSize, stats.ChunkDetails[2].Size)
+	suite.Equal(stats.ChunkDetails[2].HugePages(), stats.HugePageChunks == 1)
+	if stats.ChunkDetails[2].HugePages() {
+		suite.Equal(2*suite.hugePageSize, stats.HugePageBytes)
+	}
+
+	suite.NoError(a.Free(small))
+	suite.NoError(a.Free(large))
+}
+
+func TestHugePagesTestSuite(t *testing.T) {
+	suite.Run(t, new(HugePagesTestSuite))
+}
@@ -0,0 +1,198 @@
+package allocator
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxLeakStack is the depth of the stacks recorded by [LeakChecker].
+const maxLeakStack = 32
+
+// wrapperPackages are the packages whose frames [Leak.Site] skips,
+// as they only forward allocations.
+var wrapperPackages = []string{
+	"github.com/exapsy/goumem.",
+	"github.com/exapsy/goumem/allocator/trace.",
+}
+
+type (
+	// LeakChecker is a debugging allocator that records where every live block was allocated,
+	// so that the blocks never freed can be reported with their allocation stack.
+	LeakChecker struct {
+		parent MemoryAllocator
+		mutex  sync.Mutex
+		blocks map[*AllocatedBlock][]uintptr
+	}
+
+	// Leak is a block that is still live.
+	Leak struct {
+		Addr uintptr
+		Size uintptr
+		Tag  Tag
+		// Stack are the program counters of the allocation, as returned by [runtime.Callers].
+		Stack []uintptr
+	}
+)
+
+// NewLeakChecker creates a leak checker that allocates its blocks from parent.
+func NewLeakChecker(parent MemoryAllocator) *LeakChecker {
+	return &LeakChecker{
+		parent: parent,
+		blocks: map[*AllocatedBlock][]uintptr{},
+	}
+}
+
+func (l *LeakChecker) Alloc(size uintptr) (*AllocatedBlock, error) {
+	return l.alloc(size, NoTag)
+}
+
+func (l *LeakChecker) AllocTagged(size uintptr, tag Tag) (*AllocatedBlock, error) {
+	return l.alloc(size, tag)
+}
+
+func (l *LeakChecker) alloc(size uintptr, tag Tag) (*AllocatedBlock, error) {
+	block, err := AllocTagged(l.parent, size, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var pcs [maxLeakStack]uintptr
+	n := runtime.Callers(3, pcs[:])
+
+	l.mutex.Lock()
+	l.blocks[block] = append([]uintptr(nil), pcs[:n]...)
+	l.mutex.Unlock()
+
+	return block, nil
+}
+
+func (l *LeakChecker) Free(block *AllocatedBlock) error {
+	err := l.parent.Free(block)
+
+	// the block may be freed even if freeing it reported an error, like a corrupted canary
+	if block != nil && block.IsFreed() {
+		l.mutex.Lock()
+		delete(l.blocks, block)
+		l.mutex.Unlock()
+	}
+
+	return err
+}
+
+func (l *LeakChecker) Copy(dst, src *AllocatedBlock) error {
+	return l.parent.Copy(dst, src)
+}
+
+// Leaks returns the blocks still live, sorted by address.
+func (l *LeakChecker) Leaks() []Leak {
+	l.mutex.Lock()
+	leaks := make([]Leak, 0, len(l.blocks))
+	for block, stack := range l.blocks {
+		leaks = append(leaks, Leak{Addr: block.Addr(), Size: block.Size(), Tag: block.Tag(), Stack: stack})
+	}
+	l.mutex.Unlock()
+
+	sort.Slice(leaks, func(i, j int) bool {
+		return leaks[i].Addr < leaks[j].Addr
+	})
+
+	return leaks
+}
+
+// WriteLeaks writes a report of the blocks still live to w, grouped by tag, with their allocation stacks.
+func (l *LeakChecker) WriteLeaks(w io.Writer) error {
+	leaks := l.Leaks()
+	sort.SliceStable(leaks, func(i, j int) bool {
+		return leaks[i].Tag < leaks[j].Tag
+	})
+
+	var size uintptr
+	for _, leak := range leaks {
+		size += leak.Size
+	}
+
+	if _, err := fmt.Fprintf(w, "%d blocks leaked, %d bytes\n", len(leaks), size); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(leaks); {
+		tag := leaks[i].Tag
+
+		var blocks int
+		var size uintptr
+		for ; i < len(leaks) && leaks[i].Tag == tag; i++ {
+			blocks++
+			size += leaks[i].Size
+		}
+
+		if _, err := fmt.Fprintf(w, "\t%s: %d blocks, %d bytes\n", tag, blocks, size); err != nil {
+			return err
+		}
+	}
+
+	for _, leak := range leaks {
+		if _, err := fmt.Fprintf(w, "\n%d bytes at %#x tagged %s, allocated at:\n%s", leak.Size, leak.Addr, leak.Tag, leak.stack()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Verify verifies the parent, if it can.
+func (l *LeakChecker) Verify() error {
+	if verifier, ok := l.parent.(Verifier); ok {
+		return verifier.Verify()
+	}
+
+	return nil
+}
+
+// Stats returns the statistics of the parent, if it keeps any.
+func (l *LeakChecker) Stats() Stats {
+	stats, _ := ReadStats(l.parent)
+	return stats
+}
+
+// Site returns the function and line the block was allocated at,
+// skipping the wrappers of the goumem package.
+func (leak Leak) Site() string {
+	frames := runtime.CallersFrames(leak.Stack)
+	for {
+		frame, more := frames.Next()
+		if !isWrapper(frame) || !more {
+			return fmt.Sprintf("%s:%d", frame.Function, frame.Line)
+		}
+	}
+}
+
+// isWrapper reports whether frame is in one of [wrapperPackages], but not in their tests.
+func isWrapper(frame runtime.Frame) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+
+	for _, prefix := range wrapperPackages {
+		if strings.HasPrefix(frame.Function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (leak Leak) stack() string {
+	var stack strings.Builder
+	frames := runtime.CallersFrames(leak.Stack)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&stack, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			return stack.String()
+		}
+	}
+}
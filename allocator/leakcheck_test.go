@@ -0,0 +1,57 @@
+package allocator
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type LeakCheckerTestSuite struct {
+	suite.Suite
+	leaks *LeakChecker
+}
+
+func (suite *LeakCheckerTestSuite) SetupTest() {
+	suite.leaks = NewLeakChecker(Default())
+}
+
+func (suite *LeakCheckerTestSuite) TestLeaks() {
+	freed, err := suite.leaks.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	leaked, err := suite.leaks.Alloc(24)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.NoError(suite.leaks.Free(freed))
+
+	leaks := suite.leaks.Leaks()
+	suite.Len(leaks, 1)
+	suite.Equal(leaked.Addr(), leaks[0].Addr)
+	suite.Equal(uintptr(24), leaks[0].Size)
+	suite.Contains(leaks[0].Site(), "TestLeaks")
+
+	var report bytes.Buffer
+	suite.NoError(suite.leaks.WriteLeaks(&report))
+	suite.Contains(report.String(), "1 blocks leaked, 24 bytes")
+	suite.Contains(report.String(), "leakcheck_test.go")
+
+	suite.NoError(suite.leaks.Free(leaked))
+	suite.Empty(suite.leaks.Leaks())
+}
+
+func (suite *LeakCheckerTestSuite) TestFailedFreeKeepsBlock() {
+	block, err := suite.leaks.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.NoError(suite.leaks.Free(block))
+	suite.ErrorIs(suite.leaks.Free(block), ErrAllocatedBlockAlreadyFreed)
+	suite.Empty(suite.leaks.Leaks())
+}
+
+func TestLeakCheckerTestSuite(t *testing.T) {
+	suite.Run(t, new(LeakCheckerTestSuite))
+}
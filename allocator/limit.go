@@ -0,0 +1,187 @@
+package allocator
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// MemoryLimiter is implemented by allocators that can cap
+	// the amount of memory they map from the system.
+	MemoryLimiter interface {
+		// SetHardLimit sets the maximum of bytes the allocator may map.
+		// Allocations that would exceed it fail with [ErrOutOfMemory].
+		// Zero means no limit.
+		SetHardLimit(limit uintptr)
+		HardLimit() uintptr
+		// SetSoftLimit registers fn to be called every time the mapped bytes
+		// cross watermark upwards. Registering the same watermark twice replaces fn.
+		SetSoftLimit(watermark uintptr, fn SoftLimitFunc)
+		RemoveSoftLimit(watermark uintptr)
+	}
+
+	// SoftLimitFunc is called when the mapped memory crosses a soft limit.
+	// It is called without holding any allocator lock,
+	// so it is free to call back into the allocator to release memory.
+	SoftLimitFunc func(event SoftLimitEvent)
+
+	SoftLimitEvent struct {
+		// Watermark is the soft limit that was crossed.
+		Watermark uintptr
+		// MappedBytes is the mapped memory right after the crossing.
+		MappedBytes uintptr
+	}
+
+	// memoryBudget keeps track of the memory mapped by an allocator
+	// against its hard and soft limits.
+	memoryBudget struct {
+		hardLimit     atomic.Uintptr
+		mappedBytes   atomic.Uintptr
+		limitFailures atomic.Uint64
+
+		mutex      sync.Mutex
+		softLimits []*softLimit
+		pending    []pendingSoftLimit
+
+		log logger
+		// gcLimit is nil unless set with [WithGCLimit].
+		gcLimit *GCLimitController
+	}
+	softLimit struct {
+		watermark uintptr
+		fn        SoftLimitFunc
+		// exceeded is set when the watermark has been crossed,
+		// and cleared when the mapped memory falls back below it.
+		exceeded bool
+	}
+	pendingSoftLimit struct {
+		fn    SoftLimitFunc
+		event SoftLimitEvent
+	}
+)
+
+func newMemoryBudget() *memoryBudget {
+	return &memoryBudget{}
+}
+
+// reserve accounts size bytes that are about to be mapped.
+// It fails with [ErrOutOfMemory] if that would exceed the hard limit.
+func (b *memoryBudget) reserve(size uintptr) error {
+	for {
+		mapped := b.mappedBytes.Load()
+		limit := b.hardLimit.Load()
+		if limit != 0 && mapped+size > limit {
+			b.limitFailures.Add(1)
+			b.log.warn("goumem: hard limit reached", "mapped", mapped, "size", size, "limit", limit)
+			return fmt.Errorf("%w: hard limit reached, mapped %d + %d bytes > limit %d bytes", ErrOutOfMemory, mapped, size, limit)
+		}
+
+		if b.mappedBytes.CompareAndSwap(mapped, mapped+size) {
+			b.gcLimit.mapped(size)
+			b.crossed(mapped + size)
+			return nil
+		}
+	}
+}
+
+// release accounts size bytes that have been unmapped or failed to map.
+func (b *memoryBudget) release(size uintptr) {
+	mapped := b.mappedBytes.Add(-size)
+	b.gcLimit.unmapped(size)
+	b.crossed(mapped)
+}
+
+// crossed re-evaluates the soft limits against mapped
+// and queues the callbacks of the ones that were crossed upwards.
+func (b *memoryBudget) crossed(mapped uintptr) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, l := range b.softLimits {
+		switch {
+		case !l.exceeded && mapped >= l.watermark:
+			l.exceeded = true
+			b.pending = append(b.pending, pendingSoftLimit{
+				fn:    l.fn,
+				event: SoftLimitEvent{Watermark: l.watermark, MappedBytes: mapped},
+			})
+		case l.exceeded && mapped < l.watermark:
+			l.exceeded = false
+		}
+	}
+}
+
+// notify calls the callbacks of the soft limits crossed since the last call.
+// It must not be called while holding the allocator's lock.
+func (b *memoryBudget) notify() {
+	b.mutex.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.mutex.Unlock()
+
+	for _, p := range pending {
+		b.log.warn("goumem: soft limit crossed", "watermark", p.event.Watermark, "mapped", p.event.MappedBytes)
+		p.fn(p.event)
+	}
+}
+
+func (b *memoryBudget) setSoftLimit(watermark uintptr, fn SoftLimitFunc) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, l := range b.softLimits {
+		if l.watermark == watermark {
+			l.fn = fn
+			return
+		}
+	}
+
+	b.softLimits = append(b.softLimits, &softLimit{
+		watermark: watermark,
+		fn:        fn,
+		exceeded:  b.mappedBytes.Load() >= watermark,
+	})
+	sort.Slice(b.softLimits, func(i, j int) bool {
+		return b.softLimits[i].watermark < b.softLimits[j].watermark
+	})
+}
+
+func (b *memoryBudget) removeSoftLimit(watermark uintptr) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for i, l := range b.softLimits {
+		if l.watermark == watermark {
+			b.softLimits = append(b.softLimits[:i], b.softLimits[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *memoryBudget) watermarks() []uintptr {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	watermarks := make([]uintptr, 0, len(b.softLimits))
+	for _, l := range b.softLimits {
+		watermarks = append(watermarks, l.watermark)
+	}
+
+	return watermarks
+}
+
+// WithHardLimit caps the memory the allocator may map to limit bytes.
+func WithHardLimit(limit uintptr) Option {
+	return func(a *defaultMemoryAllocator) {
+		a.budget.hardLimit.Store(limit)
+	}
+}
+
+// WithSoftLimit calls fn every time the mapped memory crosses watermark upwards.
+func WithSoftLimit(watermark uintptr, fn SoftLimitFunc) Option {
+	return func(a *defaultMemoryAllocator) {
+		a.budget.setSoftLimit(watermark, fn)
+	}
+}
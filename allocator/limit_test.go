@@ -0,0 +1,170 @@
+package allocator
+
+import (
+	"errors"
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"testing"
+)
+
+type MemoryLimitTestSuite struct {
+	suite.Suite
+}
+
+// unfreeableSyscall fails to free memory while failFree is set.
+type unfreeableSyscall struct {
+	memsyscall.Syscall
+	failFree bool
+}
+
+func (s *unfreeableSyscall) Free(addr, size uintptr) error {
+	if s.failFree {
+		return errors.New("unfreeable")
+	}
+
+	return s.Syscall.Free(addr, size)
+}
+
+func (suite *MemoryLimitTestSuite) TestHardLimit() {
+	a := NewDefaultMemoryAllocator(WithHardLimit(4 * PageSize))
+
+	block, err := a.Alloc(2 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	_, err = a.Alloc(2 * PageSize)
+	suite.ErrorIs(err, ErrOutOfMemory)
+
+	stats, ok := ReadStats(a)
+	suite.True(ok)
+	suite.Equal(4*PageSize, stats.HardLimit)
+	suite.Equal(3*PageSize, stats.MappedBytes)
+	suite.Equal(uint64(1), stats.LimitFailures)
+
+	// freeing the block unmaps its chunk and makes room again
+	suite.NoError(a.Free(block))
+	stats, _ = ReadStats(a)
+	suite.Equal(PageSize, stats.MappedBytes)
+
+	block, err = a.Alloc(2 * PageSize)
+	suite.NoError(err)
+	suite.NoError(a.Free(block))
+}
+
+func (suite *MemoryLimitTestSuite) TestChunkNotFreed() {
+	sys := &unfreeableSyscall{Syscall: memsyscall.New()}
+	a := NewDefaultMemoryAllocator(WithSyscall(sys), WithHardLimit(4*PageSize))
+
+	block, err := a.Alloc(2 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	sys.failFree = true
+	suite.Error(a.Free(block))
+
+	// the chunk that could not be unmapped is still accounted, and still counts against the limit
+	stats, _ := ReadStats(a)
+	suite.Equal(2, stats.Chunks)
+	suite.Equal(3*PageSize, stats.MappedBytes)
+	suite.NoError(a.(Verifier).Verify())
+
+	// it is unmapped with the others once the allocator is closed
+	sys.failFree = false
+	suite.NoError(a.(io.Closer).Close())
+	stats, _ = ReadStats(a)
+	suite.Zero(stats.MappedBytes)
+	suite.NoError(a.(Verifier).Verify())
+}
+
+func (suite *MemoryLimitTestSuite) TestHardLimitBelowPage() {
+	a := NewDefaultMemoryAllocator(WithHardLimit(100))
+
+	_, err := a.Alloc(16)
+	suite.ErrorIs(err, ErrOutOfMemory)
+
+	stats, _ := ReadStats(a)
+	suite.Zero(stats.MappedBytes)
+	suite.Equal(uint64(1), stats.LimitFailures)
+	suite.NoError(a.(Verifier).Verify())
+}
+
+func (suite *MemoryLimitTestSuite) TestSetHardLimit() {
+	a := NewDefaultMemoryAllocator()
+	limiter := a.(MemoryLimiter)
+
+	limiter.SetHardLimit(PageSize)
+	suite.Equal(PageSize, limiter.HardLimit())
+
+	_, err := a.Alloc(PageSize)
+	suite.ErrorIs(err, ErrOutOfMemory)
+
+	limiter.SetHardLimit(0)
+
+	block, err := a.Alloc(PageSize)
+	suite.NoError(err)
+	suite.NoError(a.Free(block))
+}
+
+func (suite *MemoryLimitTestSuite) TestSoftLimit() {
+	var events []SoftLimitEvent
+	a := NewDefaultMemoryAllocator(WithSoftLimit(3*PageSize, func(event SoftLimitEvent) {
+		events = append(events, event)
+	}))
+
+	block, err := a.Alloc(PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Empty(events)
+
+	block2, err := a.Alloc(PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Equal([]SoftLimitEvent{{Watermark: 3 * PageSize, MappedBytes: 3 * PageSize}}, events)
+
+	// the watermark is only crossed again after falling back below it
+	suite.NoError(a.Free(block2))
+	block2, err = a.Alloc(PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Len(events, 2)
+
+	stats, _ := ReadStats(a)
+	suite.Equal([]uintptr{3 * PageSize}, stats.SoftLimits)
+
+	a.(MemoryLimiter).RemoveSoftLimit(3 * PageSize)
+	suite.NoError(a.Free(block))
+	suite.NoError(a.Free(block2))
+}
+
+func (suite *MemoryLimitTestSuite) TestSoftLimitCallbackFrees() {
+	var cached *AllocatedBlock
+	var a MemoryAllocator
+	a = NewDefaultMemoryAllocator(WithSoftLimit(3*PageSize, func(event SoftLimitEvent) {
+		// shedding a cache entry from the callback must not deadlock
+		suite.NoError(a.Free(cached))
+	}))
+
+	var err error
+	cached, err = a.Alloc(PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	block, err := a.Alloc(PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.True(cached.IsFreed())
+	suite.NoError(a.Free(block))
+}
+
+func TestMemoryLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryLimitTestSuite))
+}
@@ -0,0 +1,34 @@
+package allocator
+
+import (
+	"log/slog"
+)
+
+// logger logs the events of an allocator, if it was given a [slog.Logger].
+// The zero logger is silent.
+type logger struct {
+	l *slog.Logger
+}
+
+// WithLogger makes the allocator log its diagnostics to l:
+// chunks mapped and unmapped, slow paths and scavenging at debug level,
+// soft limits crossed, hard limits reached and failed mappings at warn level.
+// Allocators log nothing by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(a *defaultMemoryAllocator) {
+		a.log = logger{l: l}
+		a.budget.log = a.log
+	}
+}
+
+func (l logger) debug(msg string, args ...any) {
+	if l.l != nil {
+		l.l.Debug(msg, args...)
+	}
+}
+
+func (l logger) warn(msg string, args ...any) {
+	if l.l != nil {
+		l.l.Warn(msg, args...)
+	}
+}
@@ -0,0 +1,75 @@
+package allocator
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/suite"
+	"log/slog"
+	"testing"
+)
+
+type LoggerTestSuite struct {
+	suite.Suite
+	out *bytes.Buffer
+	log *slog.Logger
+}
+
+func (suite *LoggerTestSuite) SetupTest() {
+	suite.out = &bytes.Buffer{}
+	suite.log = slog.New(slog.NewTextHandler(suite.out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func (suite *LoggerTestSuite) TestChunks() {
+	a := NewDefaultMemoryAllocator(WithLogger(suite.log))
+	suite.Empty(suite.out.String())
+
+	// the first allocation maps the first chunk
+	small, err := a.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Contains(suite.out.String(), `level=DEBUG msg="goumem: chunk mapped"`)
+	suite.NoError(a.Free(small))
+	suite.out.Reset()
+
+	block, err := a.Alloc(4 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Contains(suite.out.String(), `msg="goumem: large allocation, mapping a chunk of its own" size=`)
+	suite.Contains(suite.out.String(), `msg="goumem: chunk mapped"`)
+
+	suite.NoError(a.Free(block))
+	suite.Contains(suite.out.String(), `msg="goumem: chunk unmapped"`)
+}
+
+func (suite *LoggerTestSuite) TestLimits() {
+	a := NewDefaultMemoryAllocator(
+		WithLogger(suite.log),
+		WithHardLimit(4*PageSize),
+		WithSoftLimit(2*PageSize, func(SoftLimitEvent) {}),
+	)
+
+	block, err := a.Alloc(2 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Contains(suite.out.String(), `level=WARN msg="goumem: soft limit crossed"`)
+
+	_, err = a.Alloc(8 * PageSize)
+	suite.ErrorIs(err, ErrOutOfMemory)
+	suite.Contains(suite.out.String(), `level=WARN msg="goumem: hard limit reached"`)
+
+	suite.NoError(a.Free(block))
+}
+
+func (suite *LoggerTestSuite) TestSilentByDefault() {
+	a := NewDefaultMemoryAllocator(WithHardLimit(PageSize))
+
+	_, err := a.Alloc(2 * PageSize)
+	suite.ErrorIs(err, ErrOutOfMemory)
+	suite.Empty(suite.out.String())
+}
+
+func TestLoggerTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggerTestSuite))
+}
@@ -0,0 +1,53 @@
+package allocator
+
+import memsyscall "github.com/exapsy/goumem/mem_syscall"
+
+// MappingAllocator is implemented by allocators that can map
+// a dedicated chunk for a large allocation with specific [memsyscall.MapFlags].
+type MappingAllocator interface {
+	// AllocMapped allocates a block of size bytes in a chunk of its own, mapped with flags.
+	// Flags the system does not support are dropped,
+	// [Stats.ChunkDetails] reports the ones that were applied.
+	AllocMapped(size uintptr, flags memsyscall.MapFlags) (*AllocatedBlock, error)
+}
+
+// WithMapFlags maps every chunk of the allocator with flags,
+// for example [memsyscall.MapPopulate] to prefault chunks
+// or [memsyscall.MapNoReserve] to rely on overcommit.
+// Flags the system does not support are dropped.
+func WithMapFlags(flags memsyscall.MapFlags) Option {
+	return func(a *defaultMemoryAllocator) {
+		a.mapFlags |= flags
+	}
+}
+
+func (a *defaultMemoryAllocator) AllocMapped(size uintptr, flags memsyscall.MapFlags) (*AllocatedBlock, error) {
+	if err := checkSize(size); err != nil {
+		return nil, a.error("Alloc", err)
+	}
+
+	start := a.now()
+
+	a.mutex.Lock()
+	block, err := a.allocMapped(size, flags)
+	a.mutex.Unlock()
+
+	a.budget.notify()
+
+	if err != nil {
+		return nil, a.error("Alloc", err)
+	}
+
+	a.allocated(block, start)
+
+	return block, nil
+}
+
+func (a *defaultMemoryAllocator) allocMapped(size uintptr, flags memsyscall.MapFlags) (*AllocatedBlock, error) {
+	c, err := a.chunks.appendChunk(blockSize(size), flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return allocInChunk(c, size, NoTag)
+}
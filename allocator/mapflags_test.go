@@ -0,0 +1,67 @@
+package allocator
+
+import (
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/stretchr/testify/suite"
+	"runtime"
+	"testing"
+)
+
+type MapFlagsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *MapFlagsTestSuite) TestAllocMapped() {
+	a := Default()
+
+	block, err := a.(MappingAllocator).AllocMapped(64, memsyscall.MapPopulate|memsyscall.MapNoReserve)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	Set(block, [2]int{1, 2})
+	suite.Equal([2]int{1, 2}, Get[[2]int](block))
+
+	// the block has a chunk of its own
+	stats, _ := ReadStats(a)
+	suite.Len(stats.ChunkDetails, 2)
+	suite.Equal(block.chunk.addr, stats.ChunkDetails[1].Addr)
+	if mapsFlags() {
+		suite.Equal(memsyscall.MapPopulate|memsyscall.MapNoReserve, stats.ChunkDetails[1].MapFlags)
+	}
+
+	suite.NoError(a.Free(block))
+
+	stats, _ = ReadStats(a)
+	suite.Len(stats.ChunkDetails, 1)
+}
+
+func (suite *MapFlagsTestSuite) TestWithMapFlags() {
+	a := NewDefaultMemoryAllocator(WithMapFlags(memsyscall.MapPopulate | memsyscall.MapLockOnFault))
+
+	block, err := a.Alloc(2 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	stats, _ := ReadStats(a)
+	for _, c := range stats.ChunkDetails {
+		if mapsFlags() {
+			suite.NotZero(c.MapFlags & memsyscall.MapPopulate)
+		}
+		// locking depends on RLIMIT_MEMLOCK, so it may have been dropped
+		suite.Zero(c.MapFlags &^ (memsyscall.MapPopulate | memsyscall.MapLockOnFault))
+	}
+
+	suite.NoError(a.Free(block))
+}
+
+// mapsFlags reports whether the syscall of the default allocator applies map flags.
+func mapsFlags() bool {
+	_, ok := syscall.(memsyscall.Mapper)
+	return ok && runtime.GOOS == "linux"
+}
+
+func TestMapFlagsTestSuite(t *testing.T) {
+	suite.Run(t, new(MapFlagsTestSuite))
+}
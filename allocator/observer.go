@@ -0,0 +1,232 @@
+package allocator
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// Observer is notified of the events of the allocators it is added to, see [Observable].
+	// Its methods are called synchronously, from the goroutine of the operation,
+	// possibly with the allocator locked: they must be fast and must not call back into the allocator.
+	Observer interface {
+		// OnAlloc is called for every block allocated.
+		OnAlloc(block *AllocatedBlock)
+		// OnFree is called for every block freed, before it is handed back to the allocator,
+		// or, by an [ObservedAllocator], once its parent freed it.
+		OnFree(block *AllocatedBlock)
+		// OnChunkMap and OnChunkUnmap are called when the allocator maps and unmaps memory from the system.
+		OnChunkMap(chunk ChunkStats)
+		OnChunkUnmap(chunk ChunkStats)
+		// OnError is called for every failed operation, op being "Alloc", "Free" or "Copy".
+		OnError(op string, err error)
+	}
+
+	// Observable is implemented by allocators that notify observers of their events.
+	// Allocators that do not can be wrapped with [NewObservedAllocator].
+	Observable interface {
+		// AddObserver adds o to the observers of the allocator,
+		// until the returned function is called.
+		AddObserver(o Observer) (remove func())
+	}
+
+	// ObserverFuncs is an [Observer] calling the functions that are set.
+	ObserverFuncs struct {
+		Alloc      func(block *AllocatedBlock)
+		Free       func(block *AllocatedBlock)
+		ChunkMap   func(chunk ChunkStats)
+		ChunkUnmap func(chunk ChunkStats)
+		Error      func(op string, err error)
+	}
+
+	// ObservedAllocator notifies observers of the operations of an allocator that is not [Observable].
+	// It knows nothing of the chunks of the allocator, OnChunkMap and OnChunkUnmap are never called.
+	// It can only tell a block was freed once its parent freed it,
+	// so OnFree sees the block already freed.
+	ObservedAllocator struct {
+		parent MemoryAllocator
+		observers
+	}
+
+	// observers are the observers of an allocator, embedded to make it [Observable].
+	// The list is copied on write, so that notifying no observer is a single atomic load.
+	observers struct {
+		mutex sync.Mutex
+		// list holds pointers, so that observers that are not comparable can be removed.
+		list atomic.Pointer[[]*Observer]
+	}
+)
+
+// AddObserver adds o to the observers, until the returned function is called.
+func (o *observers) AddObserver(observer Observer) (remove func()) {
+	entry := &observer
+	o.update(func(list []*Observer) []*Observer {
+		return append(list, entry)
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			o.update(func(list []*Observer) []*Observer {
+				for i := range list {
+					if list[i] == entry {
+						return append(list[:i], list[i+1:]...)
+					}
+				}
+				return list
+			})
+		})
+	}
+}
+
+// update replaces the list of observers by fn applied to a copy of it.
+func (o *observers) update(fn func(list []*Observer) []*Observer) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	var list []*Observer
+	if current := o.list.Load(); current != nil {
+		list = append(list, *current...)
+	}
+
+	list = fn(list)
+	if len(list) == 0 {
+		o.list.Store(nil)
+		return
+	}
+	o.list.Store(&list)
+}
+
+func (o *observers) alloc(block *AllocatedBlock) {
+	if list := o.list.Load(); list != nil {
+		for _, observer := range *list {
+			(*observer).OnAlloc(block)
+		}
+	}
+}
+
+func (o *observers) free(block *AllocatedBlock) {
+	if list := o.list.Load(); list != nil {
+		for _, observer := range *list {
+			(*observer).OnFree(block)
+		}
+	}
+}
+
+func (o *observers) chunkMap(c *chunk) {
+	if list := o.list.Load(); list != nil {
+		for _, observer := range *list {
+			(*observer).OnChunkMap(c.stats())
+		}
+	}
+}
+
+func (o *observers) chunkUnmap(c *chunk) {
+	if list := o.list.Load(); list != nil {
+		for _, observer := range *list {
+			(*observer).OnChunkUnmap(c.stats())
+		}
+	}
+}
+
+// error notifies err, if not nil, and returns it.
+func (o *observers) error(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if list := o.list.Load(); list != nil {
+		for _, observer := range *list {
+			(*observer).OnError(op, err)
+		}
+	}
+
+	return err
+}
+
+func (f ObserverFuncs) OnAlloc(block *AllocatedBlock) {
+	if f.Alloc != nil {
+		f.Alloc(block)
+	}
+}
+
+func (f ObserverFuncs) OnFree(block *AllocatedBlock) {
+	if f.Free != nil {
+		f.Free(block)
+	}
+}
+
+func (f ObserverFuncs) OnChunkMap(chunk ChunkStats) {
+	if f.ChunkMap != nil {
+		f.ChunkMap(chunk)
+	}
+}
+
+func (f ObserverFuncs) OnChunkUnmap(chunk ChunkStats) {
+	if f.ChunkUnmap != nil {
+		f.ChunkUnmap(chunk)
+	}
+}
+
+func (f ObserverFuncs) OnError(op string, err error) {
+	if f.Error != nil {
+		f.Error(op, err)
+	}
+}
+
+// Observe adds o to the observers of a, if a is [Observable],
+// and reports whether it did.
+func Observe(a MemoryAllocator, o Observer) (remove func(), ok bool) {
+	observable, ok := a.(Observable)
+	if !ok {
+		return func() {}, false
+	}
+
+	return observable.AddObserver(o), true
+}
+
+// NewObservedAllocator wraps parent, notifying observers of its operations.
+func NewObservedAllocator(parent MemoryAllocator, observers ...Observer) *ObservedAllocator {
+	a := &ObservedAllocator{parent: parent}
+	for _, o := range observers {
+		a.AddObserver(o)
+	}
+
+	return a
+}
+
+func (a *ObservedAllocator) Alloc(size uintptr) (*AllocatedBlock, error) {
+	return a.AllocTagged(size, NoTag)
+}
+
+func (a *ObservedAllocator) AllocTagged(size uintptr, tag Tag) (*AllocatedBlock, error) {
+	block, err := AllocTagged(a.parent, size, tag)
+	if err != nil {
+		return nil, a.error("Alloc", err)
+	}
+
+	a.alloc(block)
+
+	return block, nil
+}
+
+// Free frees the block and notifies the observers, if the parent freed it.
+func (a *ObservedAllocator) Free(block *AllocatedBlock) error {
+	if err := a.parent.Free(block); err != nil {
+		return a.error("Free", err)
+	}
+
+	a.free(block)
+
+	return nil
+}
+
+func (a *ObservedAllocator) Copy(dst, src *AllocatedBlock) error {
+	return a.error("Copy", a.parent.Copy(dst, src))
+}
+
+// Stats returns the statistics of the parent, if it keeps any.
+func (a *ObservedAllocator) Stats() Stats {
+	stats, _ := ReadStats(a.parent)
+	return stats
+}
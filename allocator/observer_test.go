@@ -0,0 +1,147 @@
+package allocator
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ObserverTestSuite struct {
+	suite.Suite
+}
+
+// recordingObserver records the events it is notified of.
+type recordingObserver struct {
+	allocs  []uintptr
+	frees   []uintptr
+	maps    []ChunkStats
+	unmaps  []ChunkStats
+	errors  []string
+	freeTag []Tag
+}
+
+func (r *recordingObserver) OnAlloc(block *AllocatedBlock) {
+	r.allocs = append(r.allocs, block.Addr())
+}
+
+func (r *recordingObserver) OnFree(block *AllocatedBlock) {
+	r.frees = append(r.frees, block.Addr())
+	r.freeTag = append(r.freeTag, block.Tag())
+}
+
+func (r *recordingObserver) OnChunkMap(chunk ChunkStats) {
+	r.maps = append(r.maps, chunk)
+}
+
+func (r *recordingObserver) OnChunkUnmap(chunk ChunkStats) {
+	r.unmaps = append(r.unmaps, chunk)
+}
+
+func (r *recordingObserver) OnError(op string, err error) {
+	r.errors = append(r.errors, op+": "+err.Error())
+}
+
+func (suite *ObserverTestSuite) TestDefault() {
+	a := NewDefaultMemoryAllocator()
+	observer := &recordingObserver{}
+	remove, ok := Observe(a, observer)
+	suite.True(ok)
+
+	small, err := a.Alloc(16)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	large, err := a.Alloc(4 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Equal([]uintptr{small.Addr(), large.Addr()}, observer.allocs)
+
+	// the small block mapped the first chunk, the large block got a chunk of its own
+	suite.Len(observer.maps, 2)
+	suite.Equal(PageSize, observer.maps[0].Size)
+	suite.Equal(large.Addr(), observer.maps[1].Addr)
+	suite.Equal(4*PageSize, observer.maps[1].Size)
+
+	largeAddr := large.Addr()
+	suite.NoError(a.Free(large))
+	suite.Equal([]uintptr{largeAddr}, observer.frees)
+	suite.Len(observer.unmaps, 1)
+	suite.Equal(largeAddr, observer.unmaps[0].Addr)
+
+	suite.ErrorIs(a.Free(large), ErrAllocatedBlockAlreadyFreed)
+	suite.ErrorIs(a.Copy(small, large), ErrAllocatedBlockAlreadyFreed)
+	suite.Equal([]string{
+		"Free: " + ErrAllocatedBlockAlreadyFreed.Error(),
+		"Copy: " + ErrAllocatedBlockAlreadyFreed.Error(),
+	}, observer.errors)
+
+	remove()
+	remove()
+	suite.NoError(a.Free(small))
+	suite.Len(observer.frees, 1)
+}
+
+func (suite *ObserverTestSuite) TestRemove() {
+	a := NewDefaultMemoryAllocator()
+
+	var first, second int
+	removeFirst := a.(Observable).AddObserver(ObserverFuncs{Alloc: func(*AllocatedBlock) { first++ }})
+	a.(Observable).AddObserver(ObserverFuncs{Alloc: func(*AllocatedBlock) { second++ }})
+
+	block, err := a.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.NoError(a.Free(block))
+
+	removeFirst()
+	block, err = a.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.NoError(a.Free(block))
+
+	suite.Equal(1, first)
+	suite.Equal(2, second)
+}
+
+func (suite *ObserverTestSuite) TestObservedAllocator() {
+	child := NewChildAllocator(Default(), 32)
+	_, ok := Observe(child, &recordingObserver{})
+	suite.False(ok)
+
+	observer := &recordingObserver{}
+	a := NewObservedAllocator(child, observer)
+	tag := RegisterTag("observed")
+
+	block, err := AllocTagged(a, 32, tag)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	addr := block.Addr()
+
+	_, err = a.Alloc(1)
+	suite.ErrorIs(err, ErrOutOfMemory)
+
+	other := Default()
+	foreign, err := other.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.ErrorIs(a.Free(foreign), ErrForeignBlock)
+	suite.NoError(other.Free(foreign))
+
+	suite.NoError(a.Free(block))
+	suite.ErrorIs(a.Free(block), ErrAllocatedBlockAlreadyFreed)
+
+	// only the block the parent freed is notified, once it is freed
+	suite.Equal([]uintptr{addr}, observer.allocs)
+	suite.Equal([]uintptr{0}, observer.frees)
+	suite.Equal([]Tag{tag}, observer.freeTag)
+	suite.Len(observer.errors, 3)
+	suite.Empty(observer.maps)
+}
+
+func TestObserverTestSuite(t *testing.T) {
+	suite.Run(t, new(ObserverTestSuite))
+}
@@ -0,0 +1,115 @@
+package allocator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+)
+
+// Scavenger is implemented by allocators that can hand idle free memory back to the system.
+type Scavenger interface {
+	// Scavenge decommits the page-aligned free spans of the allocator
+	// and returns the amount of bytes it handed back to the system.
+	// The spans stay mapped and are reused by later allocations.
+	Scavenge() uintptr
+}
+
+// StartScavenger calls [Scavenger.Scavenge] on s every interval,
+// until the returned stop function is called.
+// The interval must be positive.
+func StartScavenger(s Scavenger, interval time.Duration) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("goumem: scavenger interval must be positive, got %v", interval)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.Scavenge()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+		<-stopped
+	}, nil
+}
+
+func (a *defaultMemoryAllocator) Scavenge() uintptr {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if !a.chunks.sys.Capabilities().Has(memsyscall.CapAdvise) {
+		return 0
+	}
+
+	scavenged := a.chunks.scavenge()
+	a.counters.scavenged.Add(uint64(scavenged))
+	if scavenged != 0 {
+		a.log.debug("goumem: scavenged free memory", "bytes", scavenged)
+	}
+
+	return scavenged
+}
+
+// scavenge advises the system to reclaim the page-aligned part
+// of every free block that has not been scavenged yet.
+func (cl *chunkList) scavenge() uintptr {
+	var scavenged uintptr
+	for c := cl.chunks; c != nil; c = c.next {
+		for _, block := range c.blocks {
+			if !block.isFree.Load() {
+				continue
+			}
+
+			span := cl.pageSpan(block)
+			if span == 0 || block.scavenged >= span {
+				continue
+			}
+			start := alignUp(block.addr.Load(), cl.pageSize)
+
+			err := cl.sys.Advise(start, span, memsyscall.AdviceFree)
+			if err != nil {
+				// MADV_FREE is not available on every kernel
+				err = cl.sys.Advise(start, span, memsyscall.AdviceDontNeed)
+			}
+			if err != nil {
+				continue
+			}
+
+			scavenged += span - block.scavenged
+			c.scavengedBytes += span - block.scavenged
+			block.scavenged = span
+		}
+	}
+
+	return scavenged
+}
+
+// pageSpan returns the bytes of the pages that lie entirely in the block.
+func (cl *chunkList) pageSpan(block *chunkBlock) uintptr {
+	start := alignUp(block.addr.Load(), cl.pageSize)
+	end := alignDown(block.addr.Load()+block.size.Load(), cl.pageSize)
+	if end <= start {
+		return 0
+	}
+
+	return end - start
+}
@@ -0,0 +1,123 @@
+package allocator
+
+import (
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type ScavengerTestSuite struct {
+	suite.Suite
+	allocator MemoryAllocator
+	large     *AllocatedBlock
+	small     *AllocatedBlock
+}
+
+// SetupTest leaves a chunk with a large free span
+// that is kept mapped by a small live block at its end.
+func (suite *ScavengerTestSuite) SetupTest() {
+	if !memsyscall.New().Capabilities().Has(memsyscall.CapAdvise) {
+		suite.T().Skip("advise is not supported")
+	}
+
+	suite.allocator = Default()
+
+	// fill the first chunk, so that the small block lands in the chunk of the large one
+	for i := 0; i < 2; i++ {
+		_, err := suite.allocator.Alloc(PageSize / 2)
+		if err != nil {
+			suite.FailNow("Failed to allocate block", err)
+		}
+	}
+
+	var err error
+	suite.large, err = suite.allocator.Alloc(4*PageSize - 64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.small, err = suite.allocator.Alloc(32)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Equal(suite.large.chunk, suite.small.chunk)
+
+	suite.NoError(suite.allocator.Free(suite.large))
+}
+
+func (suite *ScavengerTestSuite) TestScavenge() {
+	stats, _ := ReadStats(suite.allocator)
+	suite.Equal(5*PageSize, stats.MappedBytes)
+	suite.Equal(stats.MappedBytes, stats.CommittedBytes)
+
+	scavenger := suite.allocator.(Scavenger)
+	suite.Equal(3*PageSize, scavenger.Scavenge())
+
+	stats, _ = ReadStats(suite.allocator)
+	suite.Equal(5*PageSize, stats.MappedBytes)
+	suite.Equal(2*PageSize, stats.CommittedBytes)
+	suite.Equal(uint64(3*PageSize), stats.ScavengedBytes)
+
+	// scavenged spans are not scavenged twice
+	suite.Equal(uintptr(0), scavenger.Scavenge())
+
+	// the scavenged span is reused and committed again
+	block, err := suite.allocator.Alloc(64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Equal(suite.small.chunk, block.chunk)
+
+	Set(block, [4]int{1, 2, 3, 4})
+	suite.Equal([4]int{1, 2, 3, 4}, Get[[4]int](block))
+
+	// only the page of the block is committed again, what is left of the span stays scavenged
+	stats, _ = ReadStats(suite.allocator)
+	suite.Equal(5*PageSize, stats.MappedBytes)
+	suite.Equal(3*PageSize, stats.CommittedBytes)
+	suite.Equal(uintptr(0), scavenger.Scavenge())
+
+	stats, _ = ReadStats(suite.allocator)
+	suite.Equal(uint64(3*PageSize), stats.ScavengedBytes)
+
+	// the page is scavenged again once the block is freed
+	suite.NoError(suite.allocator.Free(block))
+	suite.Equal(PageSize, scavenger.Scavenge())
+}
+
+func (suite *ScavengerTestSuite) TestStartScavenger() {
+	_, err := StartScavenger(suite.allocator.(Scavenger), 0)
+	suite.Error(err)
+
+	stop, err := StartScavenger(suite.allocator.(Scavenger), time.Millisecond)
+	if err != nil {
+		suite.FailNow("Failed to start scavenger", err)
+	}
+	defer stop()
+
+	suite.Eventually(func() bool {
+		stats, _ := ReadStats(suite.allocator)
+		return stats.ScavengedBytes == uint64(3*PageSize)
+	}, time.Second, time.Millisecond)
+}
+
+func (suite *ScavengerTestSuite) TestUnsupported() {
+	a := NewDefaultMemoryAllocator(WithSyscall(memsyscall.NewHeap()))
+	block, err := a.Alloc(4 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.NoError(a.Free(block))
+
+	// the heap can not hand memory back, nothing is counted as scavenged
+	suite.Equal(uintptr(0), a.(Scavenger).Scavenge())
+
+	stats, _ := ReadStats(a)
+	suite.Equal(stats.MappedBytes, stats.CommittedBytes)
+	suite.Zero(stats.ScavengedBytes)
+}
+
+func TestScavengerTestSuite(t *testing.T) {
+	suite.Run(t, new(ScavengerTestSuite))
+}
@@ -0,0 +1,136 @@
+package allocator
+
+import (
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// StatsReporter is implemented by allocators that keep statistics.
+	StatsReporter interface {
+		Stats() Stats
+	}
+
+	// Stats is a snapshot of the statistics of an allocator.
+	Stats struct {
+		// MappedBytes is the memory mapped from the system, free or not.
+		// It is the virtual memory reserved by the allocator.
+		MappedBytes uintptr
+		// CommittedBytes is the part of MappedBytes backed by physical memory,
+		// that is everything but the free memory handed back by the scavenger.
+		CommittedBytes uintptr
+		// LiveBytes is the memory handed out in blocks that have not been freed yet.
+		LiveBytes  uintptr
+		LiveBlocks uint64
+		Chunks     int
+		// Allocs and Frees are the cumulative count of successful allocations and frees.
+		Allocs uint64
+		Frees  uint64
+		// AllocatedBytes is the cumulative count of bytes allocated.
+		AllocatedBytes uint64
+
+		HardLimit  uintptr
+		SoftLimits []uintptr
+		// LimitFailures is the count of allocations rejected by the hard limit.
+		LimitFailures uint64
+
+		// ScavengedBytes is the cumulative count of bytes handed back to the system by the scavenger.
+		ScavengedBytes uint64
+
+		// HugePageChunks and HugePageBytes are the chunks that got huge pages.
+		HugePageChunks int
+		HugePageBytes  uintptr
+		// ChunkDetails describes every chunk currently mapped, in list order.
+		ChunkDetails []ChunkStats
+
+		// Histograms are nil unless the allocator was created with [WithHistograms].
+		Histograms *Histograms
+
+		// GCLimit are the decisions of the controller the allocator reports to, nil unless created [WithGCLimit].
+		GCLimit *GCLimitStats
+
+		// Tags are the statistics of the blocks allocated with a [Tag], nil if there are none.
+		Tags map[Tag]TagStats
+	}
+
+	// ChunkStats describes a chunk mapped by an allocator.
+	ChunkStats struct {
+		Addr      uintptr
+		Size      uintptr
+		FreeBytes uintptr
+		// MapFlags are the flags the chunk was actually mapped with.
+		MapFlags memsyscall.MapFlags
+	}
+
+	allocatorCounters struct {
+		liveBytes      atomic.Uintptr
+		liveBlocks     atomic.Uint64
+		allocs         atomic.Uint64
+		frees          atomic.Uint64
+		allocatedBytes atomic.Uint64
+		scavenged      atomic.Uint64
+		// tags maps the tags of the blocks allocated with one to their *tagCounters.
+		tags sync.Map
+	}
+)
+
+func (c *allocatorCounters) alloc(size uintptr, tag Tag) {
+	c.liveBytes.Add(size)
+	c.liveBlocks.Add(1)
+	c.allocs.Add(1)
+	c.allocatedBytes.Add(uint64(size))
+
+	if tag != NoTag {
+		counters := c.tagCounters(tag)
+		counters.liveBytes.Add(size)
+		counters.liveBlocks.Add(1)
+		counters.allocs.Add(1)
+		counters.allocatedBytes.Add(uint64(size))
+	}
+}
+
+func (c *allocatorCounters) free(size uintptr, tag Tag) {
+	c.liveBytes.Add(-size)
+	c.liveBlocks.Add(^uint64(0))
+	c.frees.Add(1)
+
+	if tag != NoTag {
+		counters := c.tagCounters(tag)
+		counters.liveBytes.Add(-size)
+		counters.liveBlocks.Add(^uint64(0))
+	}
+}
+
+// ReadStats returns the statistics of a, if it keeps any.
+func ReadStats(a MemoryAllocator) (Stats, bool) {
+	reporter, ok := a.(StatsReporter)
+	if !ok {
+		return Stats{}, false
+	}
+
+	return reporter.Stats(), true
+}
+
+// HugePages reports whether the chunk got explicit or transparent huge pages.
+func (c ChunkStats) HugePages() bool {
+	return c.MapFlags&hugePageFlags != 0
+}
+
+func (cl *chunkList) stats() []ChunkStats {
+	stats := make([]ChunkStats, 0, cl.len)
+	for c := cl.chunks; c != nil; c = c.next {
+		stats = append(stats, c.stats())
+	}
+
+	return stats
+}
+
+func (c *chunk) stats() ChunkStats {
+	return ChunkStats{
+		Addr:      c.addr,
+		Size:      c.size.Load(),
+		FreeBytes: c.freeBytes.Load(),
+		MapFlags:  c.mapFlags,
+	}
+}
@@ -0,0 +1,56 @@
+package allocator
+
+import (
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type SyscallTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SyscallTestSuite) TestReserved() {
+	reserved, err := memsyscall.NewReserved(64 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to reserve memory", err)
+	}
+	defer reserved.Close()
+
+	a := NewDefaultMemoryAllocator(WithSyscall(reserved))
+
+	first, err := a.Alloc(2 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	second, err := a.Alloc(2 * PageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	// the first page of the reservation is the initial chunk of the allocator
+	suite.Equal(reserved.Base()+PageSize, first.Addr())
+	suite.Equal(first.Addr()+2*PageSize, second.Addr())
+	suite.True(reserved.Contains(second.Addr()))
+	suite.Equal(5*PageSize, reserved.Committed())
+
+	// the chunks are found by their offset in the reservation
+	chunks := a.(*defaultMemoryAllocator).chunks
+	suite.Equal(first.chunk, chunks.chunkAt(first.Addr()+PageSize))
+	suite.Equal(second.chunk, chunks.chunkAt(second.Addr()))
+	suite.Equal(chunks.chunks, chunks.chunkAt(reserved.Base()))
+	suite.Nil(chunks.chunkAt(second.Addr() + 2*PageSize))
+	suite.Nil(chunks.chunkAt(reserved.Base() + reserved.Size()))
+	suite.NoError(a.(Verifier).Verify())
+
+	suite.NoError(a.Free(first))
+	suite.NoError(a.Free(second))
+	suite.Equal(PageSize, reserved.Committed())
+	suite.Nil(chunks.chunkAt(second.Addr()))
+	suite.NoError(a.(Verifier).Verify())
+}
+
+func TestSyscallTestSuite(t *testing.T) {
+	suite.Run(t, new(SyscallTestSuite))
+}
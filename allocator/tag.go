@@ -0,0 +1,146 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// Tag identifies the component a block was allocated for, see [RegisterTag].
+	// The zero Tag is [NoTag].
+	Tag uint32
+
+	// TaggedAllocator is implemented by allocators that account their blocks per [Tag].
+	TaggedAllocator interface {
+		// AllocTagged allocates a block of size bytes like Alloc, tagged with tag.
+		AllocTagged(size uintptr, tag Tag) (*AllocatedBlock, error)
+	}
+
+	// TagStats are the statistics of the blocks of a [Tag].
+	TagStats struct {
+		LiveBytes  uintptr
+		LiveBlocks uint64
+		// Allocs and AllocatedBytes are the cumulative count of allocations and bytes allocated.
+		Allocs         uint64
+		AllocatedBytes uint64
+	}
+
+	tagCounters struct {
+		liveBytes      atomic.Uintptr
+		liveBlocks     atomic.Uint64
+		allocs         atomic.Uint64
+		allocatedBytes atomic.Uint64
+	}
+
+	tagContextKey struct{}
+)
+
+// NoTag is the tag of the blocks allocated without one.
+const NoTag Tag = 0
+
+var tags = struct {
+	sync.RWMutex
+	names  []string
+	byName map[string]Tag
+}{names: []string{"untagged"}, byName: map[string]Tag{}}
+
+// RegisterTag returns the tag named name, typically once per component in a package variable:
+//
+//	var cacheTag = allocator.RegisterTag("cache")
+//
+// Registering a name again returns the same tag, so components sharing a name share its statistics.
+func RegisterTag(name string) Tag {
+	tags.Lock()
+	defer tags.Unlock()
+
+	if tag, ok := tags.byName[name]; ok {
+		return tag
+	}
+
+	tags.names = append(tags.names, name)
+	tag := Tag(len(tags.names) - 1)
+	tags.byName[name] = tag
+
+	return tag
+}
+
+// String returns the name the tag was registered with.
+func (t Tag) String() string {
+	tags.RLock()
+	defer tags.RUnlock()
+
+	if int(t) < len(tags.names) {
+		return tags.names[t]
+	}
+
+	return fmt.Sprintf("tag(%d)", uint32(t))
+}
+
+// AllocTagged allocates a block of size bytes from a, tagged with tag.
+// If a does not account its blocks per tag, the block is only tagged.
+func AllocTagged(a MemoryAllocator, size uintptr, tag Tag) (*AllocatedBlock, error) {
+	if tagged, ok := a.(TaggedAllocator); ok {
+		return tagged.AllocTagged(size, tag)
+	}
+
+	block, err := a.Alloc(size)
+	if err != nil {
+		return nil, err
+	}
+
+	block.tag = tag
+
+	return block, nil
+}
+
+// ContextWithTag returns a copy of ctx carrying tag, for [AllocContext].
+func ContextWithTag(ctx context.Context, tag Tag) context.Context {
+	return context.WithValue(ctx, tagContextKey{}, tag)
+}
+
+// TagFromContext returns the tag carried by ctx, or [NoTag].
+func TagFromContext(ctx context.Context) Tag {
+	tag, _ := ctx.Value(tagContextKey{}).(Tag)
+	return tag
+}
+
+// AllocContext allocates a block of size bytes from a, tagged with the tag carried by ctx.
+func AllocContext(ctx context.Context, a MemoryAllocator, size uintptr) (*AllocatedBlock, error) {
+	return AllocTagged(a, size, TagFromContext(ctx))
+}
+
+// Tag returns the tag the block was allocated with.
+func (b *AllocatedBlock) Tag() Tag {
+	return b.tag
+}
+
+func (c *allocatorCounters) tagCounters(tag Tag) *tagCounters {
+	counters, ok := c.tags.Load(tag)
+	if !ok {
+		counters, _ = c.tags.LoadOrStore(tag, &tagCounters{})
+	}
+
+	return counters.(*tagCounters)
+}
+
+func (c *allocatorCounters) tagStats() map[Tag]TagStats {
+	var stats map[Tag]TagStats
+	c.tags.Range(func(key, value any) bool {
+		if stats == nil {
+			stats = map[Tag]TagStats{}
+		}
+
+		counters := value.(*tagCounters)
+		stats[key.(Tag)] = TagStats{
+			LiveBytes:      counters.liveBytes.Load(),
+			LiveBlocks:     counters.liveBlocks.Load(),
+			Allocs:         counters.allocs.Load(),
+			AllocatedBytes: counters.allocatedBytes.Load(),
+		}
+		return true
+	})
+
+	return stats
+}
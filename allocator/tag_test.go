@@ -0,0 +1,134 @@
+package allocator
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type TagTestSuite struct {
+	suite.Suite
+}
+
+// untaggedAllocator hides the tag support of the allocator it wraps.
+type untaggedAllocator struct {
+	MemoryAllocator
+}
+
+func (suite *TagTestSuite) TestRegister() {
+	cache := RegisterTag("cache")
+	index := RegisterTag("index")
+
+	suite.NotEqual(NoTag, cache)
+	suite.NotEqual(cache, index)
+	suite.Equal(cache, RegisterTag("cache"))
+	suite.Equal("cache", cache.String())
+	suite.Equal("untagged", NoTag.String())
+	suite.Equal("tag(4294967295)", Tag(1<<32-1).String())
+}
+
+func (suite *TagTestSuite) TestStats() {
+	tag := RegisterTag("stats")
+	a := NewDefaultMemoryAllocator()
+
+	tagged, err := a.(TaggedAllocator).AllocTagged(100, tag)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	untagged, err := a.Alloc(50)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Equal(tag, tagged.Tag())
+	suite.Equal(NoTag, untagged.Tag())
+
+	stats, _ := ReadStats(a)
+	suite.Equal(map[Tag]TagStats{tag: {LiveBytes: 100, LiveBlocks: 1, Allocs: 1, AllocatedBytes: 100}}, stats.Tags)
+	suite.Equal(uintptr(150), stats.LiveBytes)
+
+	suite.NoError(a.Free(tagged))
+	suite.NoError(a.Free(untagged))
+
+	stats, _ = ReadStats(a)
+	suite.Equal(map[Tag]TagStats{tag: {Allocs: 1, AllocatedBytes: 100}}, stats.Tags)
+}
+
+func (suite *TagTestSuite) TestContext() {
+	tag := RegisterTag("context")
+	ctx := ContextWithTag(context.Background(), tag)
+	suite.Equal(tag, TagFromContext(ctx))
+	suite.Equal(NoTag, TagFromContext(context.Background()))
+
+	a := NewDefaultMemoryAllocator()
+	block, err := AllocContext(ctx, a, 8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.Equal(tag, block.Tag())
+	suite.NoError(a.Free(block))
+}
+
+func (suite *TagTestSuite) TestWrappers() {
+	tag := RegisterTag("wrappers")
+	parent := NewDefaultMemoryAllocator()
+	child := NewChildAllocator(NewCanaryAllocator(parent), 0)
+
+	block, err := AllocTagged(child, 64, tag)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	suite.Equal(tag, block.Tag())
+
+	childStats := child.Stats()
+	suite.Equal(uint64(1), childStats.Tags[tag].LiveBlocks)
+
+	// the parent accounts the canaries too
+	parentStats, _ := ReadStats(parent)
+	suite.Equal(uintptr(64+2*len(canary)), parentStats.Tags[tag].LiveBytes)
+
+	suite.NoError(child.Free(block))
+	parentStats, _ = ReadStats(parent)
+	suite.Zero(parentStats.Tags[tag].LiveBlocks)
+}
+
+func (suite *TagTestSuite) TestUntaggedAllocator() {
+	tag := RegisterTag("untagged allocator")
+	a := untaggedAllocator{NewDefaultMemoryAllocator()}
+
+	block, err := AllocTagged(a, 8, tag)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	suite.Equal(tag, block.Tag())
+	suite.NoError(a.Free(block))
+}
+
+func (suite *TagTestSuite) TestLeaksByTag() {
+	tag := RegisterTag("leaky")
+	leaks := NewLeakChecker(Default())
+
+	tagged, err := leaks.AllocTagged(24, tag)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+	untagged, err := leaks.Alloc(8)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	var report bytes.Buffer
+	suite.NoError(leaks.WriteLeaks(&report))
+	suite.Contains(report.String(), "2 blocks leaked, 32 bytes\n\tuntagged: 1 blocks, 8 bytes\n\tleaky: 1 blocks, 24 bytes\n")
+	suite.Contains(report.String(), "24 bytes at")
+	suite.Contains(report.String(), "tagged leaky, allocated at:\n\tgithub.com/exapsy/goumem/allocator.(*TagTestSuite).TestLeaksByTag")
+
+	suite.NoError(leaks.Free(tagged))
+	suite.NoError(leaks.Free(untagged))
+}
+
+func TestTagTestSuite(t *testing.T) {
+	suite.Run(t, new(TagTestSuite))
+}
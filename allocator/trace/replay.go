@@ -0,0 +1,114 @@
+package trace
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/exapsy/goumem/allocator"
+)
+
+// Result is the outcome of replaying a trace.
+type Result struct {
+	// Duration is the time spent in the allocator, excluding reading the trace and collecting statistics.
+	Duration time.Duration
+	Allocs   uint64
+	Frees    uint64
+	Copies   uint64
+	// Failures is the count of allocations that failed.
+	// The events of the blocks they would have allocated are skipped.
+	Failures uint64
+
+	// PeakMappedBytes is the most memory the allocator had mapped at once,
+	// if it reports statistics.
+	PeakMappedBytes uintptr
+	// PeakLiveBytes is the most memory handed out in live blocks at once.
+	PeakLiveBytes uintptr
+	// Fragmentation is the share of the mapped memory that was not live
+	// when the mapped memory peaked, from 0 to 1.
+	Fragmentation float64
+}
+
+// Replay runs the trace in r against a and reports how it performed.
+//
+// Events are replayed one after the other on the calling goroutine,
+// as fast as possible, the recorded timestamps and goroutines are ignored.
+// The blocks still live at the end of the trace are freed.
+func Replay(r io.Reader, a allocator.MemoryAllocator) (Result, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	var live uintptr
+	blocks := map[uint64]*allocator.AllocatedBlock{}
+
+	defer func() {
+		for _, block := range blocks {
+			a.Free(block)
+		}
+	}()
+
+	for {
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+
+		switch event.Op {
+		case OpAlloc:
+			start := time.Now()
+			block, err := a.Alloc(event.Size)
+			result.Duration += time.Since(start)
+			if err != nil {
+				result.Failures++
+				continue
+			}
+
+			result.Allocs++
+			blocks[event.ID] = block
+			live += event.Size
+			result.PeakLiveBytes = max(result.PeakLiveBytes, live)
+
+			if stats, ok := allocator.ReadStats(a); ok && stats.MappedBytes > result.PeakMappedBytes {
+				result.PeakMappedBytes = stats.MappedBytes
+				result.Fragmentation = 1 - float64(stats.LiveBytes)/float64(stats.MappedBytes)
+			}
+		case OpFree:
+			block, ok := blocks[event.ID]
+			if !ok {
+				continue
+			}
+
+			start := time.Now()
+			err := a.Free(block)
+			result.Duration += time.Since(start)
+			if err != nil {
+				return result, err
+			}
+
+			result.Frees++
+			delete(blocks, event.ID)
+			live -= block.Size()
+		case OpCopy:
+			dst, dstOk := blocks[event.ID]
+			src, srcOk := blocks[event.Src]
+			if !dstOk || !srcOk {
+				continue
+			}
+
+			start := time.Now()
+			err := a.Copy(dst, src)
+			result.Duration += time.Since(start)
+			if err != nil {
+				return result, err
+			}
+
+			result.Copies++
+		}
+	}
+}
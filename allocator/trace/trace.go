@@ -0,0 +1,304 @@
+// Package trace records the allocations of a [allocator.MemoryAllocator]
+// and replays them against other allocators.
+//
+// A trace starts with a header, followed by one event per successful Alloc, Free or Copy.
+// Every event is an op byte followed by uvarints:
+// the nanoseconds since the previous event, the goroutine that made the call,
+// zero unless recorded [WithGoroutines], and the operands of the op, the block id and size for Alloc,
+// the block id for Free, and the destination and source block ids for Copy.
+// Block ids are logical, they are numbered from 1 in allocation order.
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/exapsy/goumem/allocator"
+)
+
+const (
+	magic   = "goumemtr"
+	version = 1
+)
+
+const (
+	OpAlloc Op = iota + 1
+	OpFree
+	OpCopy
+)
+
+var (
+	ErrInvalidTrace = fmt.Errorf("goumem: invalid trace")
+)
+
+type (
+	Op byte
+
+	// Event is an operation read from a trace.
+	Event struct {
+		Op Op
+		// Time is the time of the event since the start of the recording.
+		Time time.Duration
+		// Goroutine is the id of the goroutine that made the call, zero unless recorded [WithGoroutines].
+		Goroutine uint64
+		// ID is the block allocated or freed, or the destination of a copy.
+		ID uint64
+		// Src is the source block of a copy.
+		Src  uint64
+		Size uintptr
+	}
+
+	// Recorder is a [allocator.MemoryAllocator] that records
+	// every successful operation on the allocator it wraps.
+	// Operations are serialized, so that the trace keeps the order they happened in.
+	Recorder struct {
+		allocator.MemoryAllocator
+
+		mutex      sync.Mutex
+		w          *bufio.Writer
+		ids        map[*allocator.AllocatedBlock]uint64
+		nextID     uint64
+		last       time.Time
+		buf        []byte
+		err        error
+		goroutines bool
+	}
+
+	// RecorderOption configures the recorder returned by [NewRecorder].
+	RecorderOption func(r *Recorder)
+
+	// Reader reads the events of a trace.
+	Reader struct {
+		r    *bufio.Reader
+		time time.Duration
+	}
+)
+
+var (
+	_ allocator.MemoryAllocator = (*Recorder)(nil)
+	_ allocator.StatsReporter   = (*Recorder)(nil)
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpAlloc:
+		return "alloc"
+	case OpFree:
+		return "free"
+	case OpCopy:
+		return "copy"
+	default:
+		return "Op(" + strconv.Itoa(int(op)) + ")"
+	}
+}
+
+// NewRecorder wraps a, writing a trace of its operations to w.
+// Blocks allocated before the recording started are not traced.
+func NewRecorder(a allocator.MemoryAllocator, w io.Writer, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		MemoryAllocator: a,
+		w:               bufio.NewWriter(w),
+		ids:             map[*allocator.AllocatedBlock]uint64{},
+		last:            time.Now(),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.w.WriteString(magic)
+	r.w.WriteByte(version)
+
+	return r, r.Flush()
+}
+
+// WithGoroutines records the goroutine of every event.
+// Getting the id of a goroutine means formatting its stack, which makes every event several times slower.
+func WithGoroutines() RecorderOption {
+	return func(r *Recorder) {
+		r.goroutines = true
+	}
+}
+
+func (r *Recorder) Alloc(size uintptr) (*allocator.AllocatedBlock, error) {
+	return r.AllocTagged(size, allocator.NoTag)
+}
+
+// AllocTagged allocates a tagged block, traced like any other, as traces do not keep tags.
+func (r *Recorder) AllocTagged(size uintptr, tag allocator.Tag) (*allocator.AllocatedBlock, error) {
+	// the lock is held while allocating, so that the block is traced before another goroutine can free it
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	block, err := allocator.AllocTagged(r.MemoryAllocator, size, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	r.nextID++
+	r.ids[block] = r.nextID
+	r.write(OpAlloc, r.nextID, uint64(size))
+
+	return block, nil
+}
+
+func (r *Recorder) Free(block *allocator.AllocatedBlock) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id, ok := r.ids[block]
+	err := r.MemoryAllocator.Free(block)
+	if err != nil || !ok {
+		return err
+	}
+
+	delete(r.ids, block)
+	r.write(OpFree, id)
+
+	return nil
+}
+
+func (r *Recorder) Copy(dst, src *allocator.AllocatedBlock) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	err := r.MemoryAllocator.Copy(dst, src)
+	if err != nil {
+		return err
+	}
+
+	dstID, dstOk := r.ids[dst]
+	srcID, srcOk := r.ids[src]
+	if dstOk && srcOk {
+		r.write(OpCopy, dstID, srcID)
+	}
+
+	return nil
+}
+
+// Stats returns the statistics of the recorded allocator, if it keeps any.
+func (r *Recorder) Stats() allocator.Stats {
+	stats, _ := allocator.ReadStats(r.MemoryAllocator)
+	return stats
+}
+
+// Flush writes the buffered events and returns the first error writing the trace, if any.
+func (r *Recorder) Flush() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+
+	return r.err
+}
+
+// write buffers an event. Once writing fails, the rest of the events are dropped.
+func (r *Recorder) write(op Op, operands ...uint64) {
+	if r.err != nil {
+		return
+	}
+
+	now := time.Now()
+	r.buf = append(r.buf[:0], byte(op))
+	r.buf = binary.AppendUvarint(r.buf, uint64(now.Sub(r.last)))
+	var goroutine uint64
+	if r.goroutines {
+		goroutine = goroutineID()
+	}
+	r.buf = binary.AppendUvarint(r.buf, goroutine)
+	for _, operand := range operands {
+		r.buf = binary.AppendUvarint(r.buf, operand)
+	}
+	r.last = now
+
+	_, r.err = r.w.Write(r.buf)
+}
+
+// NewReader reads the header of the trace in r.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r)}
+
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(reader.r, header); err != nil {
+		return nil, fmt.Errorf("%w: could not read header: %w", ErrInvalidTrace, err)
+	}
+
+	if string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("%w: bad magic %q", ErrInvalidTrace, header[:len(magic)])
+	}
+
+	if header[len(magic)] != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidTrace, header[len(magic)])
+	}
+
+	return reader, nil
+}
+
+// Next returns the next event of the trace, or [io.EOF] at the end of the trace.
+func (r *Reader) Next() (Event, error) {
+	op, err := r.r.ReadByte()
+	if err != nil {
+		return Event{}, err
+	}
+
+	event := Event{Op: Op(op)}
+
+	operands := 0
+	switch event.Op {
+	case OpAlloc, OpCopy:
+		operands = 2
+	case OpFree:
+		operands = 1
+	default:
+		return Event{}, fmt.Errorf("%w: unknown op %d", ErrInvalidTrace, op)
+	}
+
+	values := make([]uint64, 2+operands)
+	for i := range values {
+		values[i], err = binary.ReadUvarint(r.r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return Event{}, fmt.Errorf("%w: truncated %s event: %w", ErrInvalidTrace, event.Op, err)
+		}
+	}
+
+	r.time += time.Duration(values[0])
+	event.Time = r.time
+	event.Goroutine = values[1]
+	event.ID = values[2]
+
+	switch event.Op {
+	case OpAlloc:
+		event.Size = uintptr(values[3])
+	case OpCopy:
+		event.Src = values[3]
+	}
+
+	return event, nil
+}
+
+// goroutineID parses the id of the calling goroutine out of its stack trace.
+func goroutineID() uint64 {
+	var buf [64]byte
+	stack := buf[:runtime.Stack(buf[:], false)]
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i >= 0 {
+		stack = stack[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(stack), 10, 64)
+	return id
+}
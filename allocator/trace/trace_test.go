@@ -0,0 +1,159 @@
+package trace
+
+import (
+	"bytes"
+	"github.com/exapsy/goumem/allocator"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"testing"
+	"time"
+)
+
+type TraceTestSuite struct {
+	suite.Suite
+}
+
+func (suite *TraceTestSuite) record(opts ...RecorderOption) *bytes.Buffer {
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(allocator.NewDefaultMemoryAllocator(), &buf, opts...)
+	if err != nil {
+		suite.FailNow("Failed to start recording", err)
+	}
+
+	first, err := recorder.Alloc(64)
+	suite.NoError(err)
+	second, err := recorder.Alloc(64)
+	suite.NoError(err)
+	suite.NoError(recorder.Copy(second, first))
+	suite.NoError(recorder.Free(first))
+	large, err := recorder.Alloc(3 * allocator.PageSize)
+	suite.NoError(err)
+	suite.NoError(recorder.Free(large))
+	suite.NoError(recorder.Flush())
+
+	return &buf
+}
+
+func (suite *TraceTestSuite) TestRecord() {
+	reader, err := NewReader(suite.record())
+	if err != nil {
+		suite.FailNow("Failed to read trace", err)
+	}
+
+	var events []Event
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			suite.FailNow("Failed to read event", err)
+		}
+
+		suite.Zero(event.Goroutine)
+		event.Time = 0
+		events = append(events, event)
+	}
+
+	suite.Equal([]Event{
+		{Op: OpAlloc, ID: 1, Size: 64},
+		{Op: OpAlloc, ID: 2, Size: 64},
+		{Op: OpCopy, ID: 2, Src: 1},
+		{Op: OpFree, ID: 1},
+		{Op: OpAlloc, ID: 3, Size: 3 * allocator.PageSize},
+		{Op: OpFree, ID: 3},
+	}, events)
+}
+
+func (suite *TraceTestSuite) TestRecordGoroutines() {
+	reader, err := NewReader(suite.record(WithGoroutines()))
+	if err != nil {
+		suite.FailNow("Failed to read trace", err)
+	}
+
+	event, err := reader.Next()
+	suite.NoError(err)
+	suite.NotZero(event.Goroutine)
+}
+
+func (suite *TraceTestSuite) TestReplay() {
+	a := allocator.NewDefaultMemoryAllocator()
+	result, err := Replay(suite.record(), a)
+	if err != nil {
+		suite.FailNow("Failed to replay trace", err)
+	}
+
+	suite.Equal(uint64(3), result.Allocs)
+	suite.Equal(uint64(2), result.Frees)
+	suite.Equal(uint64(1), result.Copies)
+	suite.Zero(result.Failures)
+	suite.Equal(3*allocator.PageSize+64, result.PeakLiveBytes)
+	suite.True(result.PeakMappedBytes >= 4*allocator.PageSize)
+	suite.Greater(result.Fragmentation, 0.0)
+	suite.Less(result.Fragmentation, 1.0)
+
+	// the blocks left live by the trace are freed
+	stats, _ := allocator.ReadStats(a)
+	suite.Zero(stats.LiveBlocks)
+}
+
+func (suite *TraceTestSuite) TestInvalid() {
+	_, err := NewReader(bytes.NewReader([]byte("nottrace")))
+	suite.ErrorIs(err, ErrInvalidTrace)
+
+	trace := suite.record().Bytes()
+	reader, err := NewReader(bytes.NewReader(trace[:len(trace)-1]))
+	suite.NoError(err)
+	for err == nil {
+		_, err = reader.Next()
+	}
+	suite.ErrorIs(err, ErrInvalidTrace)
+	suite.ErrorIs(err, io.ErrUnexpectedEOF)
+}
+
+func (suite *TraceTestSuite) TestFreeWhileAllocating() {
+	var buf bytes.Buffer
+	parent := &freeingAllocator{MemoryAllocator: allocator.NewDefaultMemoryAllocator(), freed: make(chan error, 1)}
+	recorder, err := NewRecorder(parent, &buf)
+	if err != nil {
+		suite.FailNow("Failed to start recording", err)
+	}
+	parent.recorder = recorder
+
+	_, err = recorder.Alloc(64)
+	suite.NoError(err)
+	suite.NoError(<-parent.freed)
+	suite.NoError(recorder.Flush())
+
+	// the free is traced, even if it started before the allocation returned
+	result, err := Replay(&buf, allocator.NewDefaultMemoryAllocator())
+	suite.NoError(err)
+	suite.Equal(uint64(1), result.Allocs)
+	suite.Equal(uint64(1), result.Frees)
+}
+
+// freeingAllocator frees every block through the recorder on another goroutine,
+// before returning it.
+type freeingAllocator struct {
+	allocator.MemoryAllocator
+	recorder *Recorder
+	freed    chan error
+}
+
+func (a *freeingAllocator) Alloc(size uintptr) (*allocator.AllocatedBlock, error) {
+	block, err := a.MemoryAllocator.Alloc(size)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		a.freed <- a.recorder.Free(block)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	return block, nil
+}
+
+func TestTraceTestSuite(t *testing.T) {
+	suite.Run(t, new(TraceTestSuite))
+}
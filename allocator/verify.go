@@ -0,0 +1,170 @@
+package allocator
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrHeapCorrupted = fmt.Errorf("goumem: heap corrupted")
+)
+
+type (
+	// Verifier is implemented by allocators that can check their own bookkeeping.
+	Verifier interface {
+		// Verify returns an error wrapping [ErrHeapCorrupted]
+		// for every inconsistency it finds, or nil.
+		Verify() error
+	}
+
+	// HeapWalker is implemented by allocators that can list every block of their heap.
+	HeapWalker interface {
+		// WalkHeap calls fn for every block, free or not, in address order within each chunk,
+		// until fn returns false.
+		// fn is called with the allocator locked, it must not call back into the allocator.
+		WalkHeap(fn func(block HeapBlock) bool)
+	}
+
+	// HeapBlock describes a block of the heap of an allocator.
+	HeapBlock struct {
+		// Chunk is the address of the chunk the block belongs to.
+		Chunk uintptr
+		Addr  uintptr
+		Size  uintptr
+		Free  bool
+		// Block is the block allocated in the heap block, nil if it is free.
+		Block *AllocatedBlock
+		// Scavenged is the part of a free block handed back to the system.
+		Scavenged uintptr
+	}
+)
+
+func (a *defaultMemoryAllocator) WalkHeap(fn func(block HeapBlock) bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for c := a.chunks.chunks; c != nil; c = c.next {
+		for _, block := range c.blocks {
+			ok := fn(HeapBlock{
+				Chunk:     c.addr,
+				Addr:      block.addr.Load(),
+				Size:      block.size.Load(),
+				Free:      block.isFree.Load(),
+				Block:     block.allocated,
+				Scavenged: block.scavenged,
+			})
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+func (a *defaultMemoryAllocator) Verify() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.chunks.verify()
+}
+
+// verify checks that the chunks are linked both ways and found at their address,
+// that their blocks tile them without gaps or overlaps,
+// and that the free memory accounting matches the blocks.
+func (cl *chunkList) verify() error {
+	var errs []error
+	corrupted := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrHeapCorrupted}, args...)...))
+	}
+
+	var mapped uintptr
+	var prev *chunk
+	count := 0
+	for c := cl.chunks; c != nil; c = c.next {
+		count++
+		mapped += c.size.Load()
+
+		if c.prev != prev {
+			corrupted("chunk %#x is not linked to its previous chunk", c.addr)
+		}
+		prev = c
+
+		if cl.chunkAt(c.addr) != c || cl.chunkAt(c.addr+c.size.Load()-1) != c {
+			corrupted("chunk %#x is not found at its address", c.addr)
+		}
+
+		errs = append(errs, c.verify()...)
+	}
+
+	if count != cl.len {
+		corrupted("chunk list has %d chunks but counts %d", count, cl.len)
+	}
+
+	if budgeted := cl.budget.mappedBytes.Load(); budgeted != mapped {
+		corrupted("chunks map %d bytes but %d bytes are accounted", mapped, budgeted)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *chunk) verify() []error {
+	var errs []error
+	corrupted := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: chunk %#x: "+format, append([]any{ErrHeapCorrupted, c.addr}, args...)...))
+	}
+
+	if len(c.blocks) == 0 {
+		corrupted("no blocks")
+		return errs
+	}
+
+	var free, scavenged uintptr
+	addr := c.addr
+	for i, block := range c.blocks {
+		if block.addr.Load() != addr {
+			corrupted("block %d starts at %#x instead of %#x", i, block.addr.Load(), addr)
+		}
+		if block.size.Load() == 0 {
+			corrupted("block %d at %#x is empty", i, block.addr.Load())
+		}
+
+		var prev, next *chunkBlock
+		if i > 0 {
+			prev = c.blocks[i-1]
+		}
+		if i+1 < len(c.blocks) {
+			next = c.blocks[i+1]
+		}
+		if block.prev != prev || block.next != next {
+			corrupted("block %d at %#x is not linked to its neighbours", i, block.addr.Load())
+		}
+
+		if block.isFree.Load() {
+			free += block.size.Load()
+			scavenged += block.scavenged
+			if prev != nil && prev.isFree.Load() {
+				corrupted("free blocks %d and %d were not merged", i-1, i)
+			}
+			if block.scavenged > block.size.Load() {
+				corrupted("block %d at %#x scavenged %d bytes out of %d", i, block.addr.Load(), block.scavenged, block.size.Load())
+			}
+		} else if block.scavenged != 0 {
+			corrupted("live block %d at %#x is scavenged", i, block.addr.Load())
+		}
+
+		addr = block.addr.Load() + block.size.Load()
+	}
+
+	if end := c.addr + c.size.Load(); addr != end {
+		corrupted("blocks end at %#x instead of %#x", addr, end)
+	}
+
+	if free != c.freeBytes.Load() {
+		corrupted("blocks have %d free bytes but %d are accounted", free, c.freeBytes.Load())
+	}
+
+	if scavenged != c.scavengedBytes {
+		corrupted("blocks have %d scavenged bytes but %d are accounted", scavenged, c.scavengedBytes)
+	}
+
+	return errs
+}
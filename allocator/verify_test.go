@@ -0,0 +1,80 @@
+package allocator
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type VerifyTestSuite struct {
+	suite.Suite
+	a *defaultMemoryAllocator
+}
+
+func (suite *VerifyTestSuite) SetupTest() {
+	suite.a = NewDefaultMemoryAllocator().(*defaultMemoryAllocator)
+}
+
+func (suite *VerifyTestSuite) TestVerify() {
+	var blocks []*AllocatedBlock
+	for _, size := range []uintptr{8, 100, 3 * PageSize, 33, 64} {
+		block, err := suite.a.Alloc(size)
+		if err != nil {
+			suite.FailNow("Failed to allocate block", err)
+		}
+
+		blocks = append(blocks, block)
+	}
+	suite.NoError(suite.a.Verify())
+
+	suite.NoError(suite.a.Free(blocks[1]))
+	suite.NoError(suite.a.Free(blocks[3]))
+	suite.a.Scavenge()
+	suite.NoError(suite.a.Verify())
+
+	for _, block := range []*AllocatedBlock{blocks[0], blocks[2], blocks[4]} {
+		suite.NoError(suite.a.Free(block))
+	}
+	suite.NoError(suite.a.Verify())
+}
+
+func (suite *VerifyTestSuite) TestCorrupted() {
+	block, err := suite.a.Alloc(64)
+	if err != nil {
+		suite.FailNow("Failed to allocate block", err)
+	}
+
+	block.chunkBlockMem.size.Add(16)
+	suite.ErrorIs(suite.a.Verify(), ErrHeapCorrupted)
+}
+
+func (suite *VerifyTestSuite) TestWalkHeap() {
+	first, err := suite.a.Alloc(64)
+	suite.NoError(err)
+	second, err := suite.a.Alloc(32)
+	suite.NoError(err)
+	suite.NoError(suite.a.Free(first))
+
+	var blocks []HeapBlock
+	suite.a.WalkHeap(func(block HeapBlock) bool {
+		blocks = append(blocks, block)
+		return true
+	})
+
+	chunk := second.chunk.addr
+	suite.Equal([]HeapBlock{
+		{Chunk: chunk, Addr: chunk, Size: 64, Free: true},
+		{Chunk: chunk, Addr: second.Addr(), Size: 32, Block: second},
+		{Chunk: chunk, Addr: second.Addr() + 32, Size: PageSize - 96, Free: true},
+	}, blocks)
+
+	count := 0
+	suite.a.WalkHeap(func(block HeapBlock) bool {
+		count++
+		return false
+	})
+	suite.Equal(1, count)
+}
+
+func TestVerifyTestSuite(t *testing.T) {
+	suite.Run(t, new(VerifyTestSuite))
+}
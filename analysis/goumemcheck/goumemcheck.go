@@ -0,0 +1,462 @@
+// Package goumemcheck defines an analyzer that reports misuse of the goumem APIs.
+//
+// It reports:
+//   - blocks, and Pointer wrappers around them, used after they were freed,
+//   - blocks allocated in a function and never freed, returned or handed to anything else,
+//   - allocator.Set with types containing Go pointers, which the garbage collector can not see off-heap,
+//   - errors of goumem.Free ignored.
+//
+// The use after free check only follows straight-line code in a single block,
+// so it misses uses after a Free in a branch or in an earlier loop iteration.
+package goumemcheck
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/exapsy/goumem/internal/gctypes"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const (
+	goumemPath    = "github.com/exapsy/goumem"
+	allocatorPath = goumemPath + "/allocator"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "goumemcheck",
+	Doc:      "report misuse of goumem blocks: use after free, leaks, Go pointers stored off-heap and ignored Free errors",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	// parents of every node, to classify the uses of blocks
+	parents := map[ast.Node]ast.Node{}
+	ins.WithStack(nil, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if push && len(stack) > 1 {
+			parents[n] = stack[len(stack)-2]
+		}
+
+		return true
+	})
+
+	nodes := []ast.Node{
+		(*ast.BlockStmt)(nil),
+		(*ast.CaseClause)(nil),
+		(*ast.CommClause)(nil),
+		(*ast.FuncDecl)(nil),
+		(*ast.FuncLit)(nil),
+		(*ast.CallExpr)(nil),
+		(*ast.ExprStmt)(nil),
+		(*ast.DeferStmt)(nil),
+		(*ast.GoStmt)(nil),
+	}
+	ins.Preorder(nodes, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			checkUseAfterFree(pass, parents, n.List)
+		case *ast.CaseClause:
+			checkUseAfterFree(pass, parents, n.Body)
+		case *ast.CommClause:
+			checkUseAfterFree(pass, parents, n.Body)
+		case *ast.FuncDecl:
+			if n.Body != nil {
+				checkLeaks(pass, parents, n.Type, n.Body)
+			}
+		case *ast.FuncLit:
+			checkLeaks(pass, parents, n.Type, n.Body)
+		case *ast.CallExpr:
+			checkSet(pass, n)
+		case *ast.ExprStmt:
+			checkIgnoredFree(pass, n.X)
+		case *ast.DeferStmt:
+			checkIgnoredFree(pass, n.Call)
+		case *ast.GoStmt:
+			checkIgnoredFree(pass, n.Call)
+		}
+	})
+
+	return nil, nil
+}
+
+// checkUseAfterFree reports the uses of a variable in the statements following its Free.
+func checkUseAfterFree(pass *analysis.Pass, parents map[ast.Node]ast.Node, stmts []ast.Stmt) {
+	freed := map[types.Object]bool{}
+	for _, stmt := range stmts {
+		if len(freed) > 0 {
+			assigned := assignedObjects(stmt)
+
+			ast.Inspect(stmt, func(n ast.Node) bool {
+				ident, ok := n.(*ast.Ident)
+				if !ok {
+					return true
+				}
+
+				obj := pass.TypesInfo.Uses[ident]
+				if freed[obj] && !assigned[ident] && usesMemory(pass, parents, ident) {
+					pass.Reportf(ident.Pos(), "%s used after being freed", ident.Name)
+					delete(freed, obj)
+				}
+
+				return true
+			})
+
+			for ident := range assigned {
+				delete(freed, pass.TypesInfo.ObjectOf(ident))
+			}
+		}
+
+		inspectCalls(stmt, func(call *ast.CallExpr) {
+			if target := freeTarget(pass, call); target != nil {
+				if obj, ok := pass.TypesInfo.Uses[target].(*types.Var); ok {
+					freed[obj] = true
+				}
+			}
+		})
+	}
+}
+
+// usesMemory reports whether ident is used in a way that may touch the memory of the block,
+// rather than just its identity: calling its methods, besides IsFreed and Size,
+// dereferencing it or passing it to a function.
+func usesMemory(pass *analysis.Pass, parents map[ast.Node]ast.Node, ident *ast.Ident) bool {
+	switch parent := parents[ident].(type) {
+	case *ast.SelectorExpr:
+		return parent.Sel.Name != "IsFreed" && parent.Sel.Name != "Size"
+	case *ast.StarExpr:
+		return true
+	case *ast.CallExpr:
+		_, builtin := calledFunc(pass, parent).(*types.Builtin)
+		return !builtin
+	}
+
+	return false
+}
+
+// checkLeaks reports the blocks allocated into a local variable
+// that are neither freed nor used in a way that can hand them over.
+func checkLeaks(pass *analysis.Pass, parents map[ast.Node]ast.Node, funcType *ast.FuncType, body *ast.BlockStmt) {
+	results := map[types.Object]bool{}
+	if funcType.Results != nil {
+		for _, field := range funcType.Results.List {
+			for _, name := range field.Names {
+				results[pass.TypesInfo.Defs[name]] = true
+			}
+		}
+	}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			// checked on its own
+			return false
+		}
+
+		var lhs []ast.Expr
+		var rhs []ast.Expr
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			lhs, rhs = n.Lhs, n.Rhs
+		case *ast.ValueSpec:
+			for _, name := range n.Names {
+				lhs = append(lhs, name)
+			}
+			rhs = n.Values
+		default:
+			return true
+		}
+
+		if len(rhs) != 1 {
+			return true
+		}
+
+		call, ok := ast.Unparen(rhs[0]).(*ast.CallExpr)
+		if !ok || !isAllocation(call) {
+			return true
+		}
+
+		for i, result := range resultTypes(pass, call) {
+			if i >= len(lhs) || !isOffHeap(result) {
+				continue
+			}
+
+			ident, ok := lhs[i].(*ast.Ident)
+			if !ok {
+				continue
+			}
+
+			if ident.Name == "_" {
+				pass.Reportf(ident.Pos(), "block allocated by %s is discarded and never freed", callName(call))
+				continue
+			}
+
+			obj, ok := pass.TypesInfo.ObjectOf(ident).(*types.Var)
+			if !ok || results[obj] || obj.Parent() == nil || obj.Parent() == obj.Pkg().Scope() {
+				continue
+			}
+
+			if !handedOver(pass, parents, body, obj) {
+				pass.Reportf(ident.Pos(), "%s is allocated but never freed or returned", ident.Name)
+			}
+		}
+
+		return true
+	})
+}
+
+// handedOver reports whether obj is freed, or used in a way that may hand the block over,
+// anywhere in body.
+func handedOver(pass *analysis.Pass, parents map[ast.Node]ast.Node, body *ast.BlockStmt, obj types.Object) bool {
+	handed := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if handed || !ok || pass.TypesInfo.Uses[ident] != obj {
+			return !handed
+		}
+
+		switch parent := parents[ident].(type) {
+		case *ast.SelectorExpr:
+			// method calls on the block itself keep it, besides Free
+			call, ok := parents[parent].(*ast.CallExpr)
+			if !ok || call.Fun != parent || parent.Sel.Name == "Free" {
+				handed = true
+			}
+		case *ast.CallExpr:
+			if parent.Fun == ident || freeTarget(pass, parent) == ident {
+				handed = true
+				break
+			}
+
+			fn, ok := calledFunc(pass, parent).(*types.Func)
+			if !ok || fn.Pkg() == nil || fn.Pkg().Path() != allocatorPath || (fn.Name() != "Get" && fn.Name() != "Set") {
+				handed = true
+			}
+		case *ast.BinaryExpr:
+			if parent.Op != token.EQL && parent.Op != token.NEQ {
+				handed = true
+			}
+		case *ast.AssignStmt:
+			for _, lhs := range parent.Lhs {
+				if lhs == ident {
+					return true
+				}
+			}
+			handed = true
+		default:
+			handed = true
+		}
+
+		return !handed
+	})
+
+	return handed
+}
+
+// checkSet reports allocator.Set instantiated with a type containing Go pointers.
+func checkSet(pass *analysis.Pass, call *ast.CallExpr) {
+	fn, ok := calledFunc(pass, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != allocatorPath || fn.Name() != "Set" {
+		return
+	}
+
+	instance, ok := pass.TypesInfo.Instances[calledIdent(call)]
+	if !ok || instance.TypeArgs.Len() != 1 {
+		return
+	}
+
+	if t := instance.TypeArgs.At(0); gctypes.HasPointers(t) {
+		pass.Reportf(call.Pos(), "allocator.Set with %s, which contains Go pointers the garbage collector can not see off-heap",
+			types.TypeString(t, types.RelativeTo(pass.Pkg)))
+	}
+}
+
+// checkIgnoredFree reports calls of the Free functions of goumem whose error is dropped,
+// made by an expression statement, a defer or a go statement.
+func checkIgnoredFree(pass *analysis.Pass, expr ast.Expr) {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return
+	}
+
+	fn, ok := calledFunc(pass, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != goumemPath || fn.Name() != "Free" {
+		return
+	}
+
+	if results := fn.Type().(*types.Signature).Results(); results.Len() == 1 && isError(results.At(0).Type()) {
+		pass.Reportf(call.Pos(), "error returned by %s is ignored", callName(call))
+	}
+}
+
+// freeTarget returns the block freed by call, if it frees one held by a plain identifier:
+// the argument of goumem.Free and of the Free method of allocators,
+// or the receiver of the Free method of Pointer wrappers.
+func freeTarget(pass *analysis.Pass, call *ast.CallExpr) *ast.Ident {
+	fn, ok := calledFunc(pass, call).(*types.Func)
+	if !ok || fn.Name() != "Free" {
+		return nil
+	}
+
+	signature := fn.Type().(*types.Signature)
+	var target ast.Expr
+	switch {
+	case len(call.Args) == 1 && isAllocatedBlock(signature.Params().At(0).Type()):
+		target = call.Args[0]
+	case len(call.Args) == 0 && signature.Recv() != nil && isOffHeap(signature.Recv().Type()):
+		if selector, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok {
+			target = selector.X
+		}
+	}
+
+	ident, _ := ast.Unparen(target).(*ast.Ident)
+	return ident
+}
+
+// inspectCalls calls fn for the calls made by stmt right away,
+// leaving out deferred calls, goroutines and function literals.
+func inspectCalls(stmt ast.Stmt, fn func(call *ast.CallExpr)) {
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.DeferStmt, *ast.GoStmt, *ast.FuncLit:
+			return false
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+			// nested blocks are checked on their own
+			return n == stmt
+		case *ast.CallExpr:
+			fn(n)
+		}
+
+		return true
+	})
+}
+
+// assignedObjects returns the identifiers stmt assigns to.
+func assignedObjects(stmt ast.Stmt) map[*ast.Ident]bool {
+	assigned := map[*ast.Ident]bool{}
+	if assign, ok := stmt.(*ast.AssignStmt); ok {
+		for _, lhs := range assign.Lhs {
+			if ident, ok := lhs.(*ast.Ident); ok {
+				assigned[ident] = true
+			}
+		}
+	}
+
+	return assigned
+}
+
+func calledFunc(pass *analysis.Pass, call *ast.CallExpr) types.Object {
+	return pass.TypesInfo.Uses[calledIdent(call)]
+}
+
+// calledIdent returns the name of the function or method called, if it is named.
+func calledIdent(call *ast.CallExpr) *ast.Ident {
+	fun := ast.Unparen(call.Fun)
+	switch index := fun.(type) {
+	case *ast.IndexExpr:
+		fun = index.X
+	case *ast.IndexListExpr:
+		fun = index.X
+	}
+
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		return fun
+	case *ast.SelectorExpr:
+		return fun.Sel
+	}
+
+	return nil
+}
+
+// isAllocation reports whether call looks like it allocates, rather than looks a block up,
+// going by its name: Alloc, New and their variants.
+func isAllocation(call *ast.CallExpr) bool {
+	ident := calledIdent(call)
+	return ident != nil && (strings.HasPrefix(ident.Name, "Alloc") || strings.HasPrefix(ident.Name, "New"))
+}
+
+func callName(call *ast.CallExpr) string {
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		return fun.Name
+	case *ast.SelectorExpr:
+		if x, ok := fun.X.(*ast.Ident); ok {
+			return x.Name + "." + fun.Sel.Name
+		}
+
+		return fun.Sel.Name
+	}
+
+	return "call"
+}
+
+func resultTypes(pass *analysis.Pass, call *ast.CallExpr) []types.Type {
+	switch t := pass.TypesInfo.TypeOf(call).(type) {
+	case nil:
+		return nil
+	case *types.Tuple:
+		results := make([]types.Type, t.Len())
+		for i := range results {
+			results[i] = t.At(i).Type()
+		}
+
+		return results
+	default:
+		return []types.Type{t}
+	}
+}
+
+func isAllocatedBlock(t types.Type) bool {
+	pointer, ok := t.(*types.Pointer)
+	if !ok {
+		return false
+	}
+
+	named, ok := pointer.Elem().(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == allocatorPath && named.Obj().Name() == "AllocatedBlock"
+}
+
+// isOffHeap reports whether t is a block, or a pointer to a struct wrapping a block
+// with a Free method, like the Pointer types of goumem.
+func isOffHeap(t types.Type) bool {
+	if isAllocatedBlock(t) {
+		return true
+	}
+
+	pointer, ok := t.(*types.Pointer)
+	if !ok {
+		return false
+	}
+
+	named, ok := pointer.Elem().(*types.Named)
+	if !ok {
+		return false
+	}
+
+	structure, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+
+	wrapsBlock := false
+	for i := 0; i < structure.NumFields(); i++ {
+		if isAllocatedBlock(structure.Field(i).Type()) {
+			wrapsBlock = true
+		}
+	}
+
+	free, _, _ := types.LookupFieldOrMethod(t, true, named.Obj().Pkg(), "Free")
+	_, hasFree := free.(*types.Func)
+
+	return wrapsBlock && hasFree
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
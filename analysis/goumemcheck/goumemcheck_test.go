@@ -0,0 +1,23 @@
+package goumemcheck
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestUseAfterFree(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "useafterfree")
+}
+
+func TestLeak(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "leak")
+}
+
+func TestSet(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "set")
+}
+
+func TestIgnoredFree(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "ignoredfree")
+}
@@ -0,0 +1,29 @@
+// Package allocator is a stub of the goumem allocator for the analyzer tests.
+package allocator
+
+type (
+	MemoryAllocator interface {
+		Alloc(size uintptr) (*AllocatedBlock, error)
+		Free(block *AllocatedBlock) error
+		Copy(dst, src *AllocatedBlock) error
+	}
+	AllocatedBlock struct {
+		addr uintptr
+		size uintptr
+	}
+)
+
+func Default() MemoryAllocator { return nil }
+
+func (b *AllocatedBlock) Addr() uintptr { return b.addr }
+
+func (b *AllocatedBlock) Size() uintptr { return b.size }
+
+func (b *AllocatedBlock) IsFreed() bool { return b.addr == 0 }
+
+func Get[T any](block *AllocatedBlock) T {
+	var t T
+	return t
+}
+
+func Set[T any](block *AllocatedBlock, value T) {}
@@ -0,0 +1,20 @@
+// Package goumem is a stub of goumem for the analyzer tests.
+package goumem
+
+import "github.com/exapsy/goumem/allocator"
+
+type PointerInt struct {
+	allocatedBlock *allocator.AllocatedBlock
+}
+
+func Alloc(t interface{}) (*allocator.AllocatedBlock, error) { return nil, nil }
+
+func Free(block *allocator.AllocatedBlock) error { return nil }
+
+func NewInt(i int) (*PointerInt, error) { return nil, nil }
+
+func (ptr *PointerInt) Value() int { return 0 }
+
+func (ptr *PointerInt) Set(i int) {}
+
+func (ptr *PointerInt) Free() error { return nil }
@@ -0,0 +1,47 @@
+package ignoredfree
+
+import "github.com/exapsy/goumem"
+
+func ignored() {
+	b, _ := goumem.Alloc(1)
+	goumem.Free(b) // want `error returned by goumem.Free is ignored`
+
+	p, _ := goumem.NewInt(1)
+	p.Free() // want `error returned by p.Free is ignored`
+}
+
+func deferred() {
+	b, _ := goumem.Alloc(1)
+	defer goumem.Free(b) // want `error returned by goumem.Free is ignored`
+
+	p, _ := goumem.NewInt(1)
+	defer p.Free() // want `error returned by p.Free is ignored`
+}
+
+func goroutine() {
+	b, _ := goumem.Alloc(1)
+	go goumem.Free(b) // want `error returned by goumem.Free is ignored`
+}
+
+func handled() error {
+	b, _ := goumem.Alloc(1)
+	if err := goumem.Free(b); err != nil {
+		return err
+	}
+
+	p, _ := goumem.NewInt(1)
+	_ = p.Free()
+
+	return nil
+}
+
+func handledDeferred() (err error) {
+	b, _ := goumem.Alloc(1)
+	defer func() {
+		if freeErr := goumem.Free(b); freeErr != nil {
+			err = freeErr
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package leak
+
+import (
+	"github.com/exapsy/goumem"
+	"github.com/exapsy/goumem/allocator"
+)
+
+type holder struct {
+	block *allocator.AllocatedBlock
+}
+
+func leaked(a allocator.MemoryAllocator) uintptr {
+	b, err := a.Alloc(8) // want `b is allocated but never freed or returned`
+	if err != nil || b == nil {
+		return 0
+	}
+
+	allocator.Set(b, 42)
+
+	return b.Addr() + uintptr(allocator.Get[int](b))
+}
+
+func discarded(a allocator.MemoryAllocator) {
+	_, _ = a.Alloc(8) // want `block allocated by a.Alloc is discarded and never freed`
+}
+
+func leakedPointer() int {
+	p, _ := goumem.NewInt(1) // want `p is allocated but never freed or returned`
+	return p.Value()
+}
+
+func freed(a allocator.MemoryAllocator) {
+	b, _ := a.Alloc(8)
+	defer a.Free(b)
+}
+
+func freedPointer() {
+	p, _ := goumem.NewInt(1)
+	_ = p.Free()
+}
+
+func returned(a allocator.MemoryAllocator) (*allocator.AllocatedBlock, error) {
+	b, err := a.Alloc(8)
+	return b, err
+}
+
+func named(a allocator.MemoryAllocator) (b *allocator.AllocatedBlock, err error) {
+	b, err = a.Alloc(8)
+	return
+}
+
+func stored(a allocator.MemoryAllocator, h *holder) {
+	b, _ := a.Alloc(8)
+	h.block = b
+}
+
+func passed(a allocator.MemoryAllocator, blocks chan<- *allocator.AllocatedBlock) {
+	b, _ := a.Alloc(8)
+	blocks <- b
+}
+
+func captured(a allocator.MemoryAllocator) func() error {
+	b, _ := a.Alloc(8)
+	return func() error {
+		return a.Free(b)
+	}
+}
+
+func lookedUp(blocks map[int]*allocator.AllocatedBlock) {
+	_, _ = lookup(blocks, 1)
+}
+
+func lookup(blocks map[int]*allocator.AllocatedBlock, i int) (*allocator.AllocatedBlock, bool) {
+	b, ok := blocks[i]
+	return b, ok
+}
@@ -0,0 +1,31 @@
+package set
+
+import "github.com/exapsy/goumem/allocator"
+
+type point struct {
+	x, y int
+}
+
+type named struct {
+	name string
+}
+
+type list struct {
+	next *list
+}
+
+func set(b *allocator.AllocatedBlock) {
+	allocator.Set(b, 42)
+	allocator.Set(b, point{1, 2})
+	allocator.Set(b, [4]float64{})
+	allocator.Set(b, uintptr(0))
+
+	allocator.Set(b, "text")               // want `allocator.Set with string, which contains Go pointers`
+	allocator.Set(b, named{})              // want `allocator.Set with named, which contains Go pointers`
+	allocator.Set[*int](b, nil)            // want `allocator.Set with \*int, which contains Go pointers`
+	allocator.Set(b, []byte{})             // want `allocator.Set with \[\]byte, which contains Go pointers`
+	allocator.Set(b, [2]map[int]int{})     // want `allocator.Set with \[2\]map\[int\]int, which contains Go pointers`
+	allocator.Set[any](b, 1)               // want `allocator.Set with any, which contains Go pointers`
+	allocator.Set(b, list{})               // want `allocator.Set with list, which contains Go pointers`
+	allocator.Set(b, struct{ f func() }{}) // want `which contains Go pointers`
+}
@@ -0,0 +1,75 @@
+package useafterfree
+
+import (
+	"github.com/exapsy/goumem"
+	"github.com/exapsy/goumem/allocator"
+)
+
+func block(a allocator.MemoryAllocator) error {
+	b, err := a.Alloc(8)
+	if err != nil {
+		return err
+	}
+
+	if err := a.Free(b); err != nil {
+		return err
+	}
+
+	println(b.IsFreed())
+	println(b.Addr()) // want `b used after being freed`
+
+	return nil
+}
+
+func pointer() {
+	p, _ := goumem.NewInt(1)
+	_ = p.Free()
+	p.Set(2) // want `p used after being freed`
+}
+
+func doubleFree() {
+	b, _ := goumem.Alloc(1)
+	_ = goumem.Free(b)
+	_ = goumem.Free(b) // want `b used after being freed`
+}
+
+func reassigned(a allocator.MemoryAllocator) {
+	b, _ := a.Alloc(8)
+	_ = a.Free(b)
+	b, _ = a.Alloc(16)
+	println(b.Size())
+	_ = a.Free(b)
+}
+
+func deferred(a allocator.MemoryAllocator) {
+	b, _ := a.Alloc(8)
+	defer a.Free(b)
+	println(b.Size())
+}
+
+func branch(a allocator.MemoryAllocator, early bool) {
+	b, _ := a.Alloc(8)
+	if early {
+		_ = a.Free(b)
+		return
+	}
+	println(b.Size())
+	_ = a.Free(b)
+}
+
+func identity(a allocator.MemoryAllocator, blocks map[*allocator.AllocatedBlock]int) uintptr {
+	b, _ := a.Alloc(8)
+	_ = a.Free(b)
+	delete(blocks, b)
+	if b.IsFreed() {
+		return b.Size()
+	}
+
+	return 0
+}
+
+func dereferenced(a allocator.MemoryAllocator) allocator.AllocatedBlock {
+	b, _ := a.Alloc(8)
+	_ = a.Free(b)
+	return *b // want `b used after being freed`
+}
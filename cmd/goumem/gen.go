@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/exapsy/goumem/internal/gctypes"
+)
+
+var (
+	errGoPointers = errors.New("contains Go pointers")
+)
+
+type (
+	genConfig struct {
+		dir    string
+		types  []string
+		lock   bool
+		output string
+	}
+
+	// genStruct is the data of the template of a wrapper.
+	genStruct struct {
+		Package string
+		Name    string
+		Lock    bool
+		Fields  []genField
+	}
+	genField struct {
+		// Name is the name of the field in the struct, and of its getter.
+		Name string
+		// Setter is the name of its setter, unexported like the field.
+		Setter string
+		// Offset is the name of the constant of its offset.
+		Offset string
+		// Type is the type of the field as written in the generated file,
+		// set by [render] from typ once the imports of the file are known.
+		Type string
+		typ  types.Type
+	}
+
+	// genImports are the packages imported by a generated file.
+	genImports struct {
+		pkg *types.Package
+		// names are the names the packages are referred to by, by import path.
+		names map[string]string
+		// taken are the names already declared in the file or its package.
+		taken map[string]bool
+		// std and modules are the import specs of the standard library and of the modules.
+		std, modules []string
+	}
+)
+
+const (
+	goumemPath    = "github.com/exapsy/goumem"
+	allocatorPath = "github.com/exapsy/goumem/allocator"
+)
+
+// runGen runs the gen subcommand:
+//
+//	goumem gen -type Name[,Name...] [-lock] [-output file] [dir]
+//
+// It writes a Pointer<Name> wrapper for every struct type,
+// to <name>_goumem.go in the directory of the package, or to -output.
+func runGen(args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("goumem gen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	typeNames := flags.String("type", "", "comma-separated list of the struct types to wrap; must be set")
+	lock := flags.Bool("lock", false, "guard the wrappers with a read-write mutex")
+	output := flags.String("output", "", "output file; default <dir>/<type>_goumem.go, all types go in the same file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *typeNames == "" || flags.NArg() > 1 {
+		flags.Usage()
+		return errors.New("usage: goumem gen -type Name[,Name...] [-lock] [-output file] [dir]")
+	}
+
+	config := genConfig{
+		dir:    ".",
+		types:  strings.Split(*typeNames, ","),
+		lock:   *lock,
+		output: *output,
+	}
+	if flags.NArg() == 1 {
+		config.dir = flags.Arg(0)
+	}
+
+	files, err := generate(config)
+	if err != nil {
+		return err
+	}
+
+	for path, src := range files {
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generate type checks the package in config.dir
+// and returns the source of the wrappers by output file.
+func generate(config genConfig) (map[string][]byte, error) {
+	pkg, err := loadPackage(config.dir, config.output)
+	if err != nil {
+		return nil, err
+	}
+
+	var structs []genStruct
+	// declared are the top-level names of the wrappers generated so far, by the type they were generated for
+	declared := map[string]string{}
+	for _, name := range config.types {
+		s, err := inspectStruct(pkg, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, generated := range s.declarations() {
+			if other, ok := declared[generated]; ok {
+				return nil, fmt.Errorf("%s is generated for both %s and %s", generated, other, name)
+			}
+			declared[generated] = name
+		}
+
+		s.Lock = config.lock
+		structs = append(structs, s)
+	}
+
+	files := map[string][]byte{}
+	if config.output != "" {
+		src, err := render(pkg, structs)
+		if err != nil {
+			return nil, err
+		}
+
+		files[config.output] = src
+		return files, nil
+	}
+
+	for _, s := range structs {
+		src, err := render(pkg, []genStruct{s})
+		if err != nil {
+			return nil, err
+		}
+
+		files[filepath.Join(config.dir, strings.ToLower(s.Name)+"_goumem.go")] = src
+	}
+
+	return files, nil
+}
+
+// loadPackage parses and type checks the package in dir for the current platform,
+// leaving out tests, generated wrappers and the output file, which is regenerated.
+func loadPackage(dir, output string) (*types.Package, error) {
+	fset := token.NewFileSet()
+	filter := func(info os.FileInfo) bool {
+		if strings.HasSuffix(info.Name(), "_test.go") || strings.HasSuffix(info.Name(), "_goumem.go") {
+			return false
+		}
+		if output != "" && sameFile(filepath.Join(dir, info.Name()), output) {
+			return false
+		}
+
+		match, err := build.Default.MatchFile(dir, info.Name())
+		return err == nil && match
+	}
+
+	pkgs, err := parser.ParseDir(fset, dir, filter, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+
+	var files []*ast.File
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			files = append(files, file)
+		}
+	}
+
+	config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	return config.Check(files[0].Name.Name, fset, files, nil)
+}
+
+func inspectStruct(pkg *types.Package, name string) (genStruct, error) {
+	obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return genStruct{}, fmt.Errorf("type %s not found in package %s", name, pkg.Name())
+	}
+
+	structure, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return genStruct{}, fmt.Errorf("type %s is not a struct", name)
+	}
+
+	if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+		return genStruct{}, fmt.Errorf("type %s is generic", name)
+	}
+
+	s := genStruct{Package: pkg.Name(), Name: name}
+	// members are the methods and fields of the wrapper, with what they are
+	members := map[string]string{}
+	for _, member := range []string{"Address", "Value", "Set", "Free", "allocatedBlock", "mem", "mutex"} {
+		members[member] = member + " of the wrapper"
+	}
+	qualifier := func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+
+		return other.Name()
+	}
+	for i := 0; i < structure.NumFields(); i++ {
+		field := structure.Field(i)
+		if gctypes.HasPointers(field.Type()) {
+			return genStruct{}, fmt.Errorf("field %s.%s of type %s %w, which the garbage collector can not see off-heap",
+				name, field.Name(), types.TypeString(field.Type(), qualifier), errGoPointers)
+		}
+
+		if field.Name() == "_" {
+			continue
+		}
+
+		setter := "Set" + exported(field.Name())
+		if !field.Exported() {
+			setter = "set" + exported(field.Name())
+		}
+
+		for _, method := range []struct{ name, what string }{
+			{field.Name(), "the getter of field " + name + "." + field.Name()},
+			{setter, "the setter of field " + name + "." + field.Name()},
+		} {
+			if other, ok := members[method.name]; ok {
+				return genStruct{}, fmt.Errorf("field %s.%s conflicts with %s", name, field.Name(), other)
+			}
+			members[method.name] = method.what
+		}
+
+		s.Fields = append(s.Fields, genField{
+			Name:   field.Name(),
+			Setter: setter,
+			Offset: "offset" + name + "_" + field.Name(),
+			typ:    field.Type(),
+		})
+	}
+
+	for _, generated := range s.declarations() {
+		if pkg.Scope().Lookup(generated) != nil {
+			return genStruct{}, fmt.Errorf("%s, generated for type %s, is already declared in package %s", generated, name, pkg.Name())
+		}
+	}
+
+	return s, nil
+}
+
+// declarations returns the top-level names declared by the wrapper of s.
+func (s genStruct) declarations() []string {
+	names := []string{"Pointer" + s.Name, "New" + s.Name, "size" + s.Name}
+	for _, field := range s.Fields {
+		names = append(names, field.Offset)
+	}
+
+	return names
+}
+
+// sameFile reports whether the paths a and b name the same file.
+func sameFile(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+
+	return errA == nil && errB == nil && a == b
+}
+
+// render returns the source of a file holding the wrappers of structs, declared in pkg.
+func render(pkg *types.Package, structs []genStruct) ([]byte, error) {
+	lock := anyLock(structs)
+	imports := newGenImports(pkg, lock)
+	for i := range structs {
+		for j := range structs[i].Fields {
+			field := &structs[i].Fields[j]
+			field.Type = types.TypeString(field.typ, imports.qualifier)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := genHeader.Execute(&buf, struct {
+		Package string
+		Std     []string
+		Modules []string
+	}{pkg.Name(), imports.std, imports.modules}); err != nil {
+		return nil, err
+	}
+
+	for _, s := range structs {
+		if err := genWrapper.Execute(&buf, s); err != nil {
+			return nil, err
+		}
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// newGenImports returns the imports of a file declared in pkg,
+// which always imports unsafe, goumem and allocator, and sync if lock is set.
+func newGenImports(pkg *types.Package, lock bool) *genImports {
+	imports := &genImports{
+		pkg: pkg,
+		names: map[string]string{
+			"unsafe":      "unsafe",
+			goumemPath:    "goumem",
+			allocatorPath: "allocator",
+		},
+		taken:   map[string]bool{},
+		std:     []string{`"unsafe"`},
+		modules: []string{strconv.Quote(goumemPath), strconv.Quote(allocatorPath)},
+	}
+	if lock {
+		imports.names["sync"] = "sync"
+		imports.std = append(imports.std, `"sync"`)
+	}
+
+	for _, name := range imports.names {
+		imports.taken[name] = true
+	}
+	// the variables of the generated code the types of the fields are written next to
+	for _, name := range []string{"ptr", "v"} {
+		imports.taken[name] = true
+	}
+	for _, name := range pkg.Scope().Names() {
+		imports.taken[name] = true
+	}
+
+	return imports
+}
+
+// qualifier returns the name other is referred to by in the file, importing it if needed.
+func (i *genImports) qualifier(other *types.Package) string {
+	if other == i.pkg {
+		return ""
+	}
+
+	if name, ok := i.names[other.Path()]; ok {
+		return name
+	}
+
+	name := other.Name()
+	for n := 2; i.taken[name]; n++ {
+		name = fmt.Sprintf("%s%d", other.Name(), n)
+	}
+
+	i.names[other.Path()] = name
+	i.taken[name] = true
+
+	spec := strconv.Quote(other.Path())
+	if name != other.Name() {
+		spec = name + " " + spec
+	}
+
+	// the standard library has no dot in the first element of its paths
+	if first, _, _ := strings.Cut(other.Path(), "/"); strings.Contains(first, ".") {
+		i.modules = append(i.modules, spec)
+	} else {
+		i.std = append(i.std, spec)
+	}
+
+	return name
+}
+
+func anyLock(structs []genStruct) bool {
+	for _, s := range structs {
+		if s.Lock {
+			return true
+		}
+	}
+
+	return false
+}
+
+func exported(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+
+	return string(runes)
+}
+
+var genHeader = template.Must(template.New("header").Parse(`// Code generated by goumem gen; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Std}}
+	{{.}}
+{{- end}}
+{{range .Modules}}
+	{{.}}
+{{- end}}
+)
+`))
+
+var genWrapper = template.Must(template.New("wrapper").Parse(`
+{{$name := .Name -}}
+// Pointer{{.Name}} is a {{.Name}} allocated off-heap by the memory allocator of goumem.
+type Pointer{{.Name}} struct {
+	allocatedBlock *allocator.AllocatedBlock
+	mem            allocator.MemoryAllocator
+{{- if .Lock}}
+	mutex          sync.RWMutex
+{{- end}}
+}
+
+const (
+	size{{.Name}} = unsafe.Sizeof({{.Name}}{})
+{{- range .Fields}}
+	{{.Offset}} = unsafe.Offsetof({{$name}}{}.{{.Name}})
+{{- end}}
+)
+
+// New{{.Name}} allocates a {{.Name}} off-heap and sets it to v.
+func New{{.Name}}(v {{.Name}}) (*Pointer{{.Name}}, error) {
+	mem := goumem.GetMemoryAllocator()
+	block, err := mem.Alloc(size{{.Name}})
+	if err != nil {
+		return nil, err
+	}
+
+	ptr := &Pointer{{.Name}}{allocatedBlock: block, mem: mem}
+	ptr.Set(v)
+
+	return ptr, nil
+}
+
+func (ptr *Pointer{{.Name}}) Address() uintptr {
+{{- if .Lock}}
+	ptr.mutex.RLock()
+	defer ptr.mutex.RUnlock()
+{{end}}
+	return ptr.allocatedBlock.Addr()
+}
+
+func (ptr *Pointer{{.Name}}) Value() {{.Name}} {
+{{- if .Lock}}
+	ptr.mutex.RLock()
+	defer ptr.mutex.RUnlock()
+{{end}}
+	return *(*{{.Name}})(unsafe.Pointer(ptr.allocatedBlock.Addr()))
+}
+
+func (ptr *Pointer{{.Name}}) Set(v {{.Name}}) {
+{{- if .Lock}}
+	ptr.mutex.Lock()
+	defer ptr.mutex.Unlock()
+{{end}}
+	*(*{{.Name}})(unsafe.Pointer(ptr.allocatedBlock.Addr())) = v
+}
+{{range .Fields}}
+func (ptr *Pointer{{$name}}) {{.Name}}() {{.Type}} {
+{{- if $.Lock}}
+	ptr.mutex.RLock()
+	defer ptr.mutex.RUnlock()
+{{end}}
+	return *(*{{.Type}})(unsafe.Pointer(ptr.allocatedBlock.Addr() + {{.Offset}}))
+}
+
+func (ptr *Pointer{{$name}}) {{.Setter}}(v {{.Type}}) {
+{{- if $.Lock}}
+	ptr.mutex.Lock()
+	defer ptr.mutex.Unlock()
+{{end}}
+	*(*{{.Type}})(unsafe.Pointer(ptr.allocatedBlock.Addr() + {{.Offset}})) = v
+}
+{{end}}
+func (ptr *Pointer{{.Name}}) Free() error {
+{{- if .Lock}}
+	ptr.mutex.Lock()
+	defer ptr.mutex.Unlock()
+{{end}}
+	return ptr.mem.Free(ptr.allocatedBlock)
+}
+`))
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+type GenTestSuite struct {
+	suite.Suite
+}
+
+func (suite *GenTestSuite) TestUpToDate() {
+	for _, config := range []genConfig{
+		{dir: "internal/gentest", types: []string{"Vec3"}},
+		{dir: "internal/gentest", types: []string{"Particle"}, lock: true},
+		{dir: "internal/gentest", types: []string{"Timer"}},
+	} {
+		files, err := generate(config)
+		if err != nil {
+			suite.FailNow("Failed to generate", err)
+		}
+
+		for path, src := range files {
+			committed, err := os.ReadFile(path)
+			suite.NoError(err)
+			suite.Equal(string(committed), string(src), "%s is out of date, run go generate", path)
+		}
+	}
+}
+
+func (suite *GenTestSuite) TestOutput() {
+	output := filepath.Join(suite.T().TempDir(), "wrappers.go")
+	files, err := generate(genConfig{dir: "internal/gentest", types: []string{"Vec3", "Particle"}, output: output})
+	suite.NoError(err)
+	suite.Len(files, 1)
+	suite.Contains(string(files[output]), "type PointerVec3 struct")
+	suite.Contains(string(files[output]), "type PointerParticle struct")
+}
+
+func (suite *GenTestSuite) TestImports() {
+	dir := suite.T().TempDir()
+	suite.NoError(os.WriteFile(filepath.Join(dir, "types.go"), []byte(`package types
+
+import (
+	stdtime "time"
+	"sync/atomic"
+)
+
+var time = 1
+
+type Clock struct {
+	Elapsed stdtime.Duration
+	Ticks   atomic.Int64
+}
+`), 0o600))
+
+	files, err := generate(genConfig{dir: dir, types: []string{"Clock"}})
+	suite.NoError(err)
+
+	src := string(files[filepath.Join(dir, "clock_goumem.go")])
+	suite.Contains(src, `"sync/atomic"`)
+	suite.Contains(src, `time2 "time"`)
+	suite.Contains(src, "func (ptr *PointerClock) Elapsed() time2.Duration {")
+	suite.Contains(src, "func (ptr *PointerClock) Ticks() atomic.Int64 {")
+}
+
+func (suite *GenTestSuite) TestRefused() {
+	dir := suite.T().TempDir()
+	suite.NoError(os.WriteFile(filepath.Join(dir, "types.go"), []byte(`package types
+
+type Named struct {
+	Name string
+	Age  int
+}
+
+type Node struct {
+	Next *Node
+}
+
+type Nested struct {
+	Inner [2]struct{ Values []int }
+}
+
+type Clash struct {
+	Value int
+}
+
+type Number int
+
+type Vec struct {
+	X, Y int
+}
+
+func NewVec(x, y int) Vec {
+	return Vec{X: x, Y: y}
+}
+
+type Toggle struct {
+	On    bool
+	SetOn bool
+}
+
+var offsetLimit_Max = 1
+
+type Limit struct {
+	Max int
+}
+`), 0o600))
+
+	for name, message := range map[string]string{
+		"Named":   "field Named.Name of type string contains Go pointers",
+		"Node":    "field Node.Next of type *Node contains Go pointers",
+		"Nested":  "field Nested.Inner of type [2]struct{Values []int} contains Go pointers",
+		"Clash":   "field Clash.Value conflicts with Value of the wrapper",
+		"Number":  "type Number is not a struct",
+		"Vec":     "NewVec, generated for type Vec, is already declared in package types",
+		"Toggle":  "field Toggle.SetOn conflicts with the setter of field Toggle.On",
+		"Limit":   "offsetLimit_Max, generated for type Limit, is already declared in package types",
+		"Missing": "type Missing not found in package types",
+	} {
+		_, err := generate(genConfig{dir: dir, types: []string{name}})
+		suite.ErrorContains(err, message, name)
+	}
+
+	_, err := generate(genConfig{dir: dir, types: []string{"Node"}})
+	suite.ErrorIs(err, errGoPointers)
+}
+
+func (suite *GenTestSuite) TestConflictingTypes() {
+	dir := suite.T().TempDir()
+	suite.NoError(os.WriteFile(filepath.Join(dir, "types.go"), []byte(`package types
+
+type A struct {
+	B_C int
+}
+
+type A_B struct {
+	C int
+}
+`), 0o600))
+
+	_, err := generate(genConfig{dir: dir, types: []string{"A", "A_B"}, output: filepath.Join(dir, "wrappers.go")})
+	suite.ErrorContains(err, "offsetA_B_C is generated for both A and A_B")
+}
+
+func (suite *GenTestSuite) TestCaseOnlyFields() {
+	dir := suite.T().TempDir()
+	suite.NoError(os.WriteFile(filepath.Join(dir, "types.go"), []byte(`package types
+
+type Point struct {
+	x int
+	X int
+}
+`), 0o600))
+
+	files, err := generate(genConfig{dir: dir, types: []string{"Point"}})
+	if err != nil {
+		suite.FailNow("Failed to generate", err)
+	}
+
+	src := string(files[filepath.Join(dir, "point_goumem.go")])
+	suite.Contains(src, "offsetPoint_x = unsafe.Offsetof(Point{}.x)")
+	suite.Contains(src, "offsetPoint_X = unsafe.Offsetof(Point{}.X)")
+}
+
+func (suite *GenTestSuite) TestBuildConstraints() {
+	dir := suite.T().TempDir()
+	for name, src := range map[string]string{
+		"types.go": `package types
+
+type Point struct {
+	X, Y int
+}
+`,
+		"types_other.go": `//go:build !` + runtime.GOOS + `
+
+package types
+
+type Point struct {
+	Z int
+}
+`,
+		"tool.go": `//go:build ignore
+
+package main
+
+func main() {}
+`,
+	} {
+		suite.NoError(os.WriteFile(filepath.Join(dir, name), []byte(src), 0o600))
+	}
+
+	files, err := generate(genConfig{dir: dir, types: []string{"Point"}})
+	suite.NoError(err)
+	suite.Contains(string(files[filepath.Join(dir, "point_goumem.go")]), "func (ptr *PointerPoint) Y() int {")
+}
+
+func (suite *GenTestSuite) TestRegenerateOutput() {
+	dir := suite.T().TempDir()
+	suite.NoError(os.WriteFile(filepath.Join(dir, "types.go"), []byte(`package types
+
+type Point struct {
+	X, Y int
+}
+`), 0o600))
+
+	output := filepath.Join(dir, "wrappers.go")
+	files, err := generate(genConfig{dir: dir, types: []string{"Point"}, output: output})
+	if err != nil {
+		suite.FailNow("Failed to generate", err)
+	}
+	suite.NoError(os.WriteFile(output, files[output], 0o600))
+
+	// the output file is left out of the package, so its declarations do not conflict
+	_, err = generate(genConfig{dir: dir, types: []string{"Point"}, output: output})
+	suite.NoError(err)
+}
+
+func TestGenTestSuite(t *testing.T) {
+	suite.Run(t, new(GenTestSuite))
+}
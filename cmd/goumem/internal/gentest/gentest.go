@@ -0,0 +1,30 @@
+// Package gentest holds structs wrapped by goumem gen, to test the generated code.
+package gentest
+
+import (
+	"time"
+
+	"github.com/exapsy/goumem/allocator"
+)
+
+//go:generate go run github.com/exapsy/goumem/cmd/goumem gen -type Vec3
+//go:generate go run github.com/exapsy/goumem/cmd/goumem gen -type Particle -lock
+//go:generate go run github.com/exapsy/goumem/cmd/goumem gen -type Timer
+
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+type Particle struct {
+	Position Vec3
+	Mass     float32
+	id       uint32
+	Alive    bool
+	_        [3]byte
+	Tags     [4]uint16
+}
+
+type Timer struct {
+	Timeout time.Duration
+	Tag     allocator.Tag
+}
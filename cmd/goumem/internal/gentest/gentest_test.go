@@ -0,0 +1,77 @@
+package gentest
+
+import (
+	"github.com/exapsy/goumem/allocator"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+type GenTestSuite struct {
+	suite.Suite
+}
+
+func (suite *GenTestSuite) TestVec3() {
+	v, err := NewVec3(Vec3{1, 2, 3})
+	if err != nil {
+		suite.FailNow("Failed to allocate Vec3", err)
+	}
+
+	suite.Equal(Vec3{1, 2, 3}, v.Value())
+	suite.Equal(2.0, v.Y())
+
+	v.SetZ(4)
+	suite.Equal(Vec3{1, 2, 4}, v.Value())
+	suite.Equal(4.0, *(*float64)(unsafe.Pointer(v.Address() + 16)))
+
+	suite.NoError(v.Free())
+	suite.ErrorIs(v.Free(), allocator.ErrAllocatedBlockAlreadyFreed)
+}
+
+func (suite *GenTestSuite) TestParticle() {
+	particle := Particle{
+		Position: Vec3{1, 1, 1},
+		Mass:     2.5,
+		id:       7,
+		Alive:    true,
+		Tags:     [4]uint16{1, 2, 3, 4},
+	}
+
+	p, err := NewParticle(particle)
+	if err != nil {
+		suite.FailNow("Failed to allocate Particle", err)
+	}
+	defer p.Free()
+
+	suite.Equal(particle, p.Value())
+	suite.Equal(uint32(7), p.id())
+
+	p.SetPosition(Vec3{X: 5})
+	p.setId(8)
+	p.SetAlive(false)
+	p.SetTags([4]uint16{9})
+
+	particle.Position = Vec3{X: 5}
+	particle.id = 8
+	particle.Alive = false
+	particle.Tags = [4]uint16{9}
+	suite.Equal(particle, p.Value())
+	suite.Equal(float32(2.5), p.Mass())
+}
+
+func (suite *GenTestSuite) TestTimer() {
+	timer, err := NewTimer(Timer{Timeout: time.Second, Tag: allocator.NoTag})
+	if err != nil {
+		suite.FailNow("Failed to allocate Timer", err)
+	}
+	defer timer.Free()
+
+	timer.SetTimeout(time.Minute)
+	suite.Equal(time.Minute, timer.Timeout())
+	suite.Equal(allocator.NoTag, timer.Tag())
+}
+
+func TestGenTestSuite(t *testing.T) {
+	suite.Run(t, new(GenTestSuite))
+}
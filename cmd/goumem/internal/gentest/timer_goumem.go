@@ -0,0 +1,69 @@
+// Code generated by goumem gen; DO NOT EDIT.
+
+package gentest
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/exapsy/goumem"
+	"github.com/exapsy/goumem/allocator"
+)
+
+// PointerTimer is a Timer allocated off-heap by the memory allocator of goumem.
+type PointerTimer struct {
+	allocatedBlock *allocator.AllocatedBlock
+	mem            allocator.MemoryAllocator
+}
+
+const (
+	sizeTimer           = unsafe.Sizeof(Timer{})
+	offsetTimer_Timeout = unsafe.Offsetof(Timer{}.Timeout)
+	offsetTimer_Tag     = unsafe.Offsetof(Timer{}.Tag)
+)
+
+// NewTimer allocates a Timer off-heap and sets it to v.
+func NewTimer(v Timer) (*PointerTimer, error) {
+	mem := goumem.GetMemoryAllocator()
+	block, err := mem.Alloc(sizeTimer)
+	if err != nil {
+		return nil, err
+	}
+
+	ptr := &PointerTimer{allocatedBlock: block, mem: mem}
+	ptr.Set(v)
+
+	return ptr, nil
+}
+
+func (ptr *PointerTimer) Address() uintptr {
+	return ptr.allocatedBlock.Addr()
+}
+
+func (ptr *PointerTimer) Value() Timer {
+	return *(*Timer)(unsafe.Pointer(ptr.allocatedBlock.Addr()))
+}
+
+func (ptr *PointerTimer) Set(v Timer) {
+	*(*Timer)(unsafe.Pointer(ptr.allocatedBlock.Addr())) = v
+}
+
+func (ptr *PointerTimer) Timeout() time.Duration {
+	return *(*time.Duration)(unsafe.Pointer(ptr.allocatedBlock.Addr() + offsetTimer_Timeout))
+}
+
+func (ptr *PointerTimer) SetTimeout(v time.Duration) {
+	*(*time.Duration)(unsafe.Pointer(ptr.allocatedBlock.Addr() + offsetTimer_Timeout)) = v
+}
+
+func (ptr *PointerTimer) Tag() allocator.Tag {
+	return *(*allocator.Tag)(unsafe.Pointer(ptr.allocatedBlock.Addr() + offsetTimer_Tag))
+}
+
+func (ptr *PointerTimer) SetTag(v allocator.Tag) {
+	*(*allocator.Tag)(unsafe.Pointer(ptr.allocatedBlock.Addr() + offsetTimer_Tag)) = v
+}
+
+func (ptr *PointerTimer) Free() error {
+	return ptr.mem.Free(ptr.allocatedBlock)
+}
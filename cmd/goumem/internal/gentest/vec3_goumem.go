@@ -0,0 +1,77 @@
+// Code generated by goumem gen; DO NOT EDIT.
+
+package gentest
+
+import (
+	"unsafe"
+
+	"github.com/exapsy/goumem"
+	"github.com/exapsy/goumem/allocator"
+)
+
+// PointerVec3 is a Vec3 allocated off-heap by the memory allocator of goumem.
+type PointerVec3 struct {
+	allocatedBlock *allocator.AllocatedBlock
+	mem            allocator.MemoryAllocator
+}
+
+const (
+	sizeVec3     = unsafe.Sizeof(Vec3{})
+	offsetVec3_X = unsafe.Offsetof(Vec3{}.X)
+	offsetVec3_Y = unsafe.Offsetof(Vec3{}.Y)
+	offsetVec3_Z = unsafe.Offsetof(Vec3{}.Z)
+)
+
+// NewVec3 allocates a Vec3 off-heap and sets it to v.
+func NewVec3(v Vec3) (*PointerVec3, error) {
+	mem := goumem.GetMemoryAllocator()
+	block, err := mem.Alloc(sizeVec3)
+	if err != nil {
+		return nil, err
+	}
+
+	ptr := &PointerVec3{allocatedBlock: block, mem: mem}
+	ptr.Set(v)
+
+	return ptr, nil
+}
+
+func (ptr *PointerVec3) Address() uintptr {
+	return ptr.allocatedBlock.Addr()
+}
+
+func (ptr *PointerVec3) Value() Vec3 {
+	return *(*Vec3)(unsafe.Pointer(ptr.allocatedBlock.Addr()))
+}
+
+func (ptr *PointerVec3) Set(v Vec3) {
+	*(*Vec3)(unsafe.Pointer(ptr.allocatedBlock.Addr())) = v
+}
+
+func (ptr *PointerVec3) X() float64 {
+	return *(*float64)(unsafe.Pointer(ptr.allocatedBlock.Addr() + offsetVec3_X))
+}
+
+func (ptr *PointerVec3) SetX(v float64) {
+	*(*float64)(unsafe.Pointer(ptr.allocatedBlock.Addr() + offsetVec3_X)) = v
+}
+
+func (ptr *PointerVec3) Y() float64 {
+	return *(*float64)(unsafe.Pointer(ptr.allocatedBlock.Addr() + offsetVec3_Y))
+}
+
+func (ptr *PointerVec3) SetY(v float64) {
+	*(*float64)(unsafe.Pointer(ptr.allocatedBlock.Addr() + offsetVec3_Y)) = v
+}
+
+func (ptr *PointerVec3) Z() float64 {
+	return *(*float64)(unsafe.Pointer(ptr.allocatedBlock.Addr() + offsetVec3_Z))
+}
+
+func (ptr *PointerVec3) SetZ(v float64) {
+	*(*float64)(unsafe.Pointer(ptr.allocatedBlock.Addr() + offsetVec3_Z)) = v
+}
+
+func (ptr *PointerVec3) Free() error {
+	return ptr.mem.Free(ptr.allocatedBlock)
+}
@@ -0,0 +1,57 @@
+// Command goumem is an interactive shell to inspect goumem allocators,
+// and to reproduce allocator bugs by hand.
+//
+// Usage:
+//
+//	goumem [script ...]
+//	goumem gen -type Name[,Name...] [-lock] [-output file] [dir]
+//
+// Without arguments, goumem reads commands from the standard input.
+// With arguments, it runs every script in order and exits,
+// stopping at the first command that fails.
+// Type "help" in the shell for the list of commands.
+//
+// The gen subcommand generates a Pointer<Name> wrapper for struct types of the package in dir,
+// that keeps a value of the struct off-heap, with a getter and a setter per field.
+// Fields that contain Go pointers are refused,
+// since the garbage collector does not see them off-heap.
+// It is meant for go:generate:
+//
+//	//go:generate goumem gen -type Vec3
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "gen" {
+		if err := runGen(os.Args[2:], os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, "goumem gen:", err)
+			os.Exit(2)
+		}
+
+		return
+	}
+
+	s := newShell(os.Stdout)
+	defer s.close()
+
+	if len(os.Args) > 1 {
+		for _, path := range os.Args[1:] {
+			if err := s.runFile(path); err != nil {
+				fmt.Fprintln(os.Stderr, "goumem:", err)
+				os.Exit(1)
+			}
+		}
+
+		return
+	}
+
+	s.interactive = true
+	if err := s.run(os.Stdin); err != nil {
+		fmt.Fprintln(os.Stderr, "goumem:", err)
+		os.Exit(1)
+	}
+}
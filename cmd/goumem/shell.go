@@ -0,0 +1,535 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/exapsy/goumem/allocator"
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/exapsy/goumem/mem_syscall/fake"
+)
+
+var (
+	errExit = errors.New("exit")
+)
+
+// maxScriptDepth is how deep scripts may run other scripts.
+const maxScriptDepth = 16
+
+type (
+	shell struct {
+		out         io.Writer
+		interactive bool
+
+		name   string
+		mem    allocator.MemoryAllocator
+		closer func() error
+		blocks map[int]*allocator.AllocatedBlock
+		nextID int
+		// depth is the count of scripts running.
+		depth int
+	}
+
+	command struct {
+		usage string
+		help  string
+		run   func(s *shell, args []string) error
+	}
+
+	// implementation creates an allocator and a function releasing all its memory, its chunks included.
+	implementation func() (allocator.MemoryAllocator, func() error, error)
+)
+
+var commands map[string]command
+
+var implementations = map[string]implementation{
+	"default": func() (allocator.MemoryAllocator, func() error, error) {
+		a := allocator.NewDefaultMemoryAllocator(allocator.WithHistograms())
+		return a, a.(io.Closer).Close, nil
+	},
+	"reserved": func() (allocator.MemoryAllocator, func() error, error) {
+		reserved, err := memsyscall.NewReserved(1 << 30)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		a := allocator.NewDefaultMemoryAllocator(allocator.WithSyscall(reserved))
+		return a, func() error {
+			return errors.Join(a.(io.Closer).Close(), reserved.Close())
+		}, nil
+	},
+	"fake": func() (allocator.MemoryAllocator, func() error, error) {
+		sys := fake.New(64<<20, fake.WithPageSize(allocator.PageSize))
+		a := allocator.NewDefaultMemoryAllocator(allocator.WithSyscall(sys))
+		return a, func() error {
+			return errors.Join(a.(io.Closer).Close(), sys.Close())
+		}, nil
+	},
+	"hugepages": func() (allocator.MemoryAllocator, func() error, error) {
+		a := allocator.NewDefaultMemoryAllocator(allocator.WithHugePages(memsyscall.MapHugeTLB | memsyscall.MapHugePageAdvise))
+		return a, a.(io.Closer).Close, nil
+	},
+	"child": func() (allocator.MemoryAllocator, func() error, error) {
+		parent := allocator.NewDefaultMemoryAllocator()
+		child := allocator.NewChildAllocator(parent, 0)
+		return child, func() error {
+			return errors.Join(child.Close(), parent.(io.Closer).Close())
+		}, nil
+	},
+}
+
+func init() {
+	commands = map[string]command{
+		"alloc":   {"alloc <size>", "allocate a block of size bytes", (*shell).alloc},
+		"free":    {"free <block>", "free a block", (*shell).free},
+		"blocks":  {"blocks", "list the live blocks", (*shell).list},
+		"write":   {"write <block> <offset> <text|0xhex>", "write text, or hex bytes, at offset of a block", (*shell).write},
+		"read":    {"read <block> <offset> <length>", "print length bytes at offset of a block", (*shell).read},
+		"hexdump": {"hexdump <block>", "hex dump the whole block", (*shell).hexdump},
+		"stats":   {"stats", "print the statistics of the allocator", (*shell).stats},
+		"heap":    {"heap", "walk every block of the heap", (*shell).heap},
+		"verify":  {"verify", "check the bookkeeping of the allocator", (*shell).verify},
+		"use":     {"use <allocator>", "free every block and switch allocator", (*shell).use},
+		"script":  {"script <file>", "run the commands of a file", (*shell).script},
+		"help":    {"help", "print this help", (*shell).help},
+		"exit":    {"exit", "exit the shell", (*shell).exit},
+	}
+}
+
+func newShell(out io.Writer) *shell {
+	s := &shell{out: out}
+	if err := s.switchTo("default"); err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// run executes the commands read from r.
+// Interactively, errors are printed and the shell goes on,
+// otherwise the first error stops it.
+func (s *shell) run(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for {
+		if s.interactive {
+			fmt.Fprintf(s.out, "%s> ", s.name)
+		}
+
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		err := s.exec(scanner.Text())
+		if errors.Is(err, errExit) {
+			return nil
+		}
+		if err != nil {
+			if !s.interactive {
+				return err
+			}
+
+			fmt.Fprintln(s.out, "error:", err)
+		}
+	}
+}
+
+func (s *shell) runFile(path string) error {
+	if s.depth >= maxScriptDepth {
+		return fmt.Errorf("%s: scripts nested more than %d deep", path, maxScriptDepth)
+	}
+	s.depth++
+	defer func() { s.depth-- }()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	interactive := s.interactive
+	s.interactive = false
+	defer func() { s.interactive = interactive }()
+
+	if err := s.run(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// exec executes a single line. Empty lines and lines starting with # are ignored.
+func (s *shell) exec(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return nil
+	}
+
+	cmd, ok := commands[fields[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, type help for the list of commands", fields[0])
+	}
+
+	// the text of write is the rest of the line, spaces included
+	if fields[0] == "write" && len(fields) > 4 {
+		fields = append(fields[:3], afterFields(line, 3))
+	}
+
+	if err := cmd.run(s, fields[1:]); err != nil {
+		if errors.Is(err, errExit) {
+			return err
+		}
+
+		return fmt.Errorf("%s: %w", fields[0], err)
+	}
+
+	return nil
+}
+
+// afterFields returns what follows the first n fields of line, without the spaces before it.
+func afterFields(line string, n int) string {
+	for i := 0; i < n; i++ {
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		if end := strings.IndexFunc(line, unicode.IsSpace); end >= 0 {
+			line = line[end:]
+		} else {
+			line = ""
+		}
+	}
+
+	return strings.TrimLeftFunc(line, unicode.IsSpace)
+}
+
+func (s *shell) close() {
+	s.freeAll()
+	if s.closer != nil {
+		if err := s.closer(); err != nil {
+			fmt.Fprintf(s.out, "error: could not release the %s allocator: %v\n", s.name, err)
+		}
+	}
+}
+
+func (s *shell) alloc(args []string) error {
+	if len(args) != 1 {
+		return errUsage("alloc")
+	}
+
+	size, err := parseSize(args[0])
+	if err != nil {
+		return err
+	}
+
+	block, err := s.mem.Alloc(size)
+	if err != nil {
+		return err
+	}
+
+	s.nextID++
+	s.blocks[s.nextID] = block
+	fmt.Fprintf(s.out, "block %d: addr %#x size %d\n", s.nextID, block.Addr(), block.Size())
+
+	return nil
+}
+
+func (s *shell) free(args []string) error {
+	if len(args) != 1 {
+		return errUsage("free")
+	}
+
+	id, block, err := s.block(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := s.mem.Free(block); err != nil {
+		return err
+	}
+
+	delete(s.blocks, id)
+	fmt.Fprintf(s.out, "block %d freed\n", id)
+
+	return nil
+}
+
+func (s *shell) list(args []string) error {
+	for _, id := range s.ids() {
+		block := s.blocks[id]
+		fmt.Fprintf(s.out, "block %d: addr %#x size %d\n", id, block.Addr(), block.Size())
+	}
+
+	return nil
+}
+
+func (s *shell) write(args []string) error {
+	if len(args) != 3 {
+		return errUsage("write")
+	}
+
+	_, block, err := s.block(args[0])
+	if err != nil {
+		return err
+	}
+
+	offset, err := parseSize(args[1])
+	if err != nil {
+		return err
+	}
+
+	data := []byte(args[2])
+	if strings.HasPrefix(args[2], "0x") {
+		data, err = hex.DecodeString(args[2][2:])
+		if err != nil {
+			return fmt.Errorf("invalid hex data: %w", err)
+		}
+	}
+
+	mem := block.Bytes()
+	if offset > uintptr(len(mem)) || uintptr(len(data)) > uintptr(len(mem))-offset {
+		return fmt.Errorf("%d bytes at offset %d overflow the block of %d bytes", len(data), offset, len(mem))
+	}
+
+	copy(mem[offset:], data)
+	fmt.Fprintf(s.out, "wrote %d bytes\n", len(data))
+
+	return nil
+}
+
+func (s *shell) read(args []string) error {
+	if len(args) != 3 {
+		return errUsage("read")
+	}
+
+	_, block, err := s.block(args[0])
+	if err != nil {
+		return err
+	}
+
+	offset, err := parseSize(args[1])
+	if err != nil {
+		return err
+	}
+
+	length, err := parseSize(args[2])
+	if err != nil {
+		return err
+	}
+
+	mem := block.Bytes()
+	if offset > uintptr(len(mem)) || length > uintptr(len(mem))-offset {
+		return fmt.Errorf("%d bytes at offset %d overflow the block of %d bytes", length, offset, len(mem))
+	}
+
+	fmt.Fprintf(s.out, "%q\n", mem[offset:offset+length])
+
+	return nil
+}
+
+func (s *shell) hexdump(args []string) error {
+	if len(args) != 1 {
+		return errUsage("hexdump")
+	}
+
+	_, block, err := s.block(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(s.out, hex.Dump(block.Bytes()))
+
+	return nil
+}
+
+func (s *shell) stats(args []string) error {
+	stats, ok := allocator.ReadStats(s.mem)
+	if !ok {
+		return fmt.Errorf("allocator %s keeps no statistics", s.name)
+	}
+
+	fmt.Fprintf(s.out, "mapped     %d bytes in %d chunks\n", stats.MappedBytes, stats.Chunks)
+	fmt.Fprintf(s.out, "committed  %d bytes\n", stats.CommittedBytes)
+	fmt.Fprintf(s.out, "live       %d bytes in %d blocks\n", stats.LiveBytes, stats.LiveBlocks)
+	fmt.Fprintf(s.out, "allocs     %d (%d bytes)\n", stats.Allocs, stats.AllocatedBytes)
+	fmt.Fprintf(s.out, "frees      %d\n", stats.Frees)
+	fmt.Fprintf(s.out, "hard limit %d (%d failures)\n", stats.HardLimit, stats.LimitFailures)
+	fmt.Fprintf(s.out, "scavenged  %d bytes\n", stats.ScavengedBytes)
+	fmt.Fprintf(s.out, "huge pages %d bytes in %d chunks\n", stats.HugePageBytes, stats.HugePageChunks)
+	if h := stats.Histograms; h != nil {
+		fmt.Fprintf(s.out, "size       p50 %d p99 %d bytes\n", h.Size.Quantile(0.5), h.Size.Quantile(0.99))
+		fmt.Fprintf(s.out, "alloc      p50 %v p99 %v\n", time.Duration(h.AllocLatency.Quantile(0.5)), time.Duration(h.AllocLatency.Quantile(0.99)))
+		fmt.Fprintf(s.out, "free       p50 %v p99 %v\n", time.Duration(h.FreeLatency.Quantile(0.5)), time.Duration(h.FreeLatency.Quantile(0.99)))
+		fmt.Fprintf(s.out, "lifetime   p50 %v p99 %v\n", time.Duration(h.Lifetime.Quantile(0.5)), time.Duration(h.Lifetime.Quantile(0.99)))
+	}
+	for _, c := range stats.ChunkDetails {
+		fmt.Fprintf(s.out, "chunk %#x size %d free %d\n", c.Addr, c.Size, c.FreeBytes)
+	}
+
+	return nil
+}
+
+func (s *shell) heap(args []string) error {
+	walker, ok := s.mem.(allocator.HeapWalker)
+	if !ok {
+		return fmt.Errorf("allocator %s can not walk its heap", s.name)
+	}
+
+	ids := map[uintptr]int{}
+	for id, block := range s.blocks {
+		ids[block.Addr()] = id
+	}
+
+	walker.WalkHeap(func(block allocator.HeapBlock) bool {
+		state := "live"
+		if block.Free {
+			state = "free"
+		}
+
+		fmt.Fprintf(s.out, "chunk %#x addr %#x size %d %s", block.Chunk, block.Addr, block.Size, state)
+		if id, ok := ids[block.Addr]; ok && !block.Free {
+			fmt.Fprintf(s.out, " block %d", id)
+		}
+		if block.Scavenged != 0 {
+			fmt.Fprintf(s.out, " scavenged %d", block.Scavenged)
+		}
+		fmt.Fprintln(s.out)
+
+		return true
+	})
+
+	return nil
+}
+
+func (s *shell) verify(args []string) error {
+	verifier, ok := s.mem.(allocator.Verifier)
+	if !ok {
+		return fmt.Errorf("allocator %s can not verify itself", s.name)
+	}
+
+	if err := verifier.Verify(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(s.out, "ok")
+
+	return nil
+}
+
+func (s *shell) use(args []string) error {
+	if len(args) != 1 {
+		names := make([]string, 0, len(implementations))
+		for name := range implementations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return fmt.Errorf("usage: use <allocator>, one of %s", strings.Join(names, ", "))
+	}
+
+	if _, ok := implementations[args[0]]; !ok {
+		return fmt.Errorf("unknown allocator %q", args[0])
+	}
+
+	if err := s.switchTo(args[0]); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(s.out, "using %s\n", s.name)
+
+	return nil
+}
+
+func (s *shell) script(args []string) error {
+	if len(args) != 1 {
+		return errUsage("script")
+	}
+
+	return s.runFile(args[0])
+}
+
+func (s *shell) help(args []string) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(s.out, "%-40s %s\n", commands[name].usage, commands[name].help)
+	}
+
+	return nil
+}
+
+func (s *shell) exit(args []string) error {
+	return errExit
+}
+
+// switchTo creates the allocator name and switches to it, closing the current one.
+// If the allocator can not be created, the shell keeps the current one.
+func (s *shell) switchTo(name string) error {
+	mem, closer, err := implementations[name]()
+	if err != nil {
+		return err
+	}
+
+	s.close()
+
+	s.name = name
+	s.mem = mem
+	s.closer = closer
+	s.blocks = map[int]*allocator.AllocatedBlock{}
+	s.nextID = 0
+
+	return nil
+}
+
+func (s *shell) freeAll() {
+	for _, id := range s.ids() {
+		s.mem.Free(s.blocks[id])
+		delete(s.blocks, id)
+	}
+}
+
+func (s *shell) ids() []int {
+	ids := make([]int, 0, len(s.blocks))
+	for id := range s.blocks {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	return ids
+}
+
+func (s *shell) block(arg string) (int, *allocator.AllocatedBlock, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid block %q", arg)
+	}
+
+	block, ok := s.blocks[id]
+	if !ok {
+		return 0, nil, fmt.Errorf("no live block %d", id)
+	}
+
+	return id, block, nil
+}
+
+func parseSize(arg string) (uintptr, error) {
+	size, err := strconv.ParseUint(arg, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", arg)
+	}
+
+	return uintptr(size), nil
+}
+
+func errUsage(name string) error {
+	return fmt.Errorf("usage: %s", commands[name].usage)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/exapsy/goumem/allocator"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type ShellTestSuite struct {
+	suite.Suite
+	out   bytes.Buffer
+	shell *shell
+}
+
+func (suite *ShellTestSuite) SetupTest() {
+	suite.out.Reset()
+	suite.shell = newShell(&suite.out)
+}
+
+func (suite *ShellTestSuite) TearDownTest() {
+	suite.shell.close()
+}
+
+func (suite *ShellTestSuite) TestScript() {
+	err := suite.shell.run(strings.NewReader(`
+# allocate and fill a block
+alloc 32
+write 1 4 hello world
+read 1 4 11
+alloc 64
+free 1
+verify
+exit
+alloc 1
+`))
+	suite.NoError(err)
+
+	out := suite.out.String()
+	suite.Contains(out, "block 1: addr")
+	suite.Contains(out, "size 32")
+	suite.Contains(out, "wrote 11 bytes")
+	suite.Contains(out, `"hello world"`)
+	suite.Contains(out, "block 1 freed")
+	suite.Contains(out, "ok\n")
+	suite.NotContains(out, "block 3")
+}
+
+func (suite *ShellTestSuite) TestWriteSpaces() {
+	suite.NoError(suite.shell.exec("alloc 16"))
+	suite.NoError(suite.shell.exec("write 1 0 a  b\tc"))
+	suite.NoError(suite.shell.exec("read 1 0 6"))
+	suite.Contains(suite.out.String(), `"a  b\tc"`)
+}
+
+func (suite *ShellTestSuite) TestHexdump() {
+	suite.NoError(suite.shell.exec("alloc 16"))
+	suite.NoError(suite.shell.exec("write 1 0 0xdeadbeef"))
+	suite.NoError(suite.shell.exec("hexdump 1"))
+	suite.Contains(suite.out.String(), "de ad be ef 00 00")
+}
+
+func (suite *ShellTestSuite) TestHeap() {
+	suite.NoError(suite.shell.exec("alloc 16"))
+	suite.NoError(suite.shell.exec("heap"))
+	suite.Contains(suite.out.String(), "size 16 live block 1")
+	suite.Contains(suite.out.String(), "free")
+
+	suite.NoError(suite.shell.exec("stats"))
+	suite.Contains(suite.out.String(), "live       16 bytes in 1 blocks")
+}
+
+func (suite *ShellTestSuite) TestErrors() {
+	suite.ErrorContains(suite.shell.exec("alloc"), "usage: alloc <size>")
+	suite.ErrorContains(suite.shell.exec("free 7"), "no live block 7")
+	suite.ErrorContains(suite.shell.exec("nope"), "unknown command")
+
+	suite.NoError(suite.shell.exec("alloc 8"))
+	suite.ErrorContains(suite.shell.exec("write 1 4 12345"), "overflow")
+
+	// a failing command stops a script
+	err := suite.shell.run(strings.NewReader("free 9\nalloc 8\n"))
+	suite.ErrorContains(err, "free: no live block 9")
+	suite.NotContains(suite.out.String(), "block 2")
+}
+
+func (suite *ShellTestSuite) TestUse() {
+	for _, name := range []string{"fake", "reserved", "child", "hugepages", "default"} {
+		suite.NoError(suite.shell.exec("alloc 64"))
+		previous := suite.shell.mem
+		suite.NoError(suite.shell.exec("use "+name), name)
+		suite.NoError(suite.shell.exec("alloc 64"), name)
+		suite.NoError(suite.shell.exec("free 1"), name)
+
+		// the previous allocator gave all its memory back
+		if stats, ok := allocator.ReadStats(previous); ok {
+			suite.Zero(stats.MappedBytes, name)
+		}
+	}
+
+	suite.ErrorContains(suite.shell.exec("use nope"), "unknown allocator")
+}
+
+func (suite *ShellTestSuite) TestUseFailure() {
+	implementations["broken"] = func() (allocator.MemoryAllocator, func() error, error) {
+		return nil, nil, errors.New("broken")
+	}
+	defer delete(implementations, "broken")
+
+	suite.NoError(suite.shell.exec("use fake"))
+	suite.NoError(suite.shell.exec("alloc 64"))
+	suite.ErrorContains(suite.shell.exec("use broken"), "broken")
+
+	// the shell keeps the allocator it was using, and its blocks
+	suite.Equal("fake", suite.shell.name)
+	suite.NoError(suite.shell.exec("write 1 0 hello"))
+	suite.NoError(suite.shell.exec("alloc 64"))
+	suite.NoError(suite.shell.exec("verify"))
+}
+
+func (suite *ShellTestSuite) TestCloseFailure() {
+	implementations["leaky"] = func() (allocator.MemoryAllocator, func() error, error) {
+		return allocator.Default(), func() error { return errors.New("still mapped") }, nil
+	}
+	defer delete(implementations, "leaky")
+
+	suite.NoError(suite.shell.exec("use leaky"))
+	suite.NoError(suite.shell.exec("use default"))
+	suite.Contains(suite.out.String(), "error: could not release the leaky allocator: still mapped")
+}
+
+func (suite *ShellTestSuite) TestScriptFile() {
+	path := filepath.Join(suite.T().TempDir(), "script")
+	suite.NoError(os.WriteFile(path, []byte("alloc 8\nfree 1\n"), 0o600))
+
+	suite.NoError(suite.shell.exec("script " + path))
+	suite.Contains(suite.out.String(), "block 1 freed")
+}
+
+func (suite *ShellTestSuite) TestScriptRecursion() {
+	path := filepath.Join(suite.T().TempDir(), "script")
+	suite.NoError(os.WriteFile(path, []byte("alloc 8\nscript "+path+"\n"), 0o600))
+
+	suite.ErrorContains(suite.shell.exec("script "+path), "scripts nested more than 16 deep")
+	suite.Len(suite.shell.blocks, 16)
+}
+
+func TestShellTestSuite(t *testing.T) {
+	suite.Run(t, new(ShellTestSuite))
+}
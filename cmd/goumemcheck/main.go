@@ -0,0 +1,18 @@
+// Command goumemcheck reports misuse of the goumem APIs.
+//
+// It runs on its own, or as a vet tool:
+//
+//	goumemcheck ./...
+//	go vet -vettool=$(which goumemcheck) ./...
+//
+// See package goumemcheck for the list of checks.
+package main
+
+import (
+	"github.com/exapsy/goumem/analysis/goumemcheck"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(goumemcheck.Analyzer)
+}
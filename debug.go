@@ -0,0 +1,269 @@
+package goumem
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exapsy/goumem/allocator"
+	"github.com/exapsy/goumem/allocator/trace"
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+)
+
+// debugEnv is the environment variable that enables the debugging allocators,
+// as a comma-separated list of key=value settings, in the style of GODEBUG:
+//
+//	guard=1      map every block on its own pages, followed by a guard page
+//	canary=1     surround every block with canaries, checked when it is freed
+//	leakcheck=1  record where every block is allocated, see [WriteLeaks]
+//	trace=path   record every operation to the file at path, see package trace
+//	verify=100ms verify the heap periodically, panicking when it is corrupted
+const debugEnv = "GOUMEMDEBUG"
+
+// traceFlushInterval is how often the recorded trace is flushed to its file.
+const traceFlushInterval = time.Second
+
+type (
+	debugConfig struct {
+		guard     bool
+		canary    bool
+		leakcheck bool
+		trace     string
+		verify    time.Duration
+	}
+
+	// debugState is what the debugging allocators enabled at startup expose.
+	debugState struct {
+		mutex sync.Mutex
+		// base is the allocator the checkers wrap.
+		base allocator.MemoryAllocator
+		// verifier is the outermost checker that can be verified.
+		verifier allocator.Verifier
+		leaks    *allocator.LeakChecker
+		recorder *trace.Recorder
+		// top is the outermost checker, the global allocator until [SetMemoryAllocator] replaces it.
+		top allocator.MemoryAllocator
+		// done stops the goroutines verifying the heap and flushing the trace.
+		done     chan struct{}
+		stopOnce sync.Once
+	}
+)
+
+var debug debugState
+
+// init sets up the global allocator, the default one wrapped by the debugging allocators enabled with [debugEnv].
+func init() {
+	value, ok := os.LookupEnv(debugEnv)
+	if !ok {
+		mem = allocator.Default()
+		return
+	}
+
+	mem = debug.wrap(nil, parseDebug(value, os.Stderr), os.Stderr)
+}
+
+// parseDebug parses the value of [debugEnv], warning on w about the settings it does not understand.
+func parseDebug(value string, w io.Writer) debugConfig {
+	var config debugConfig
+	for _, setting := range strings.Split(value, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(setting, "=")
+
+		var err error
+		switch key {
+		case "guard":
+			config.guard, err = parseDebugBool(value)
+		case "canary":
+			config.canary, err = parseDebugBool(value)
+		case "leakcheck":
+			config.leakcheck, err = parseDebugBool(value)
+		case "trace":
+			config.trace = value
+		case "verify":
+			var interval time.Duration
+			interval, err = time.ParseDuration(value)
+			if err == nil && interval <= 0 {
+				err = fmt.Errorf("interval must be positive")
+			}
+			if err == nil {
+				config.verify = interval
+			}
+		default:
+			fmt.Fprintf(w, "goumem: %s: unknown setting %q, ignored\n", debugEnv, key)
+			continue
+		}
+
+		if err != nil {
+			fmt.Fprintf(w, "goumem: %s: invalid value %q for %s, ignored: %v\n", debugEnv, value, key, err)
+		}
+	}
+
+	return config
+}
+
+func parseDebugBool(value string) (bool, error) {
+	switch value {
+	case "1":
+		return true, nil
+	case "0":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("expected 0 or 1")
+}
+
+// wrap wraps base, or the default allocator if base is nil, with the debugging allocators enabled by config,
+// warning on w about the ones that could not be enabled.
+// The guard allocator replaces the default allocator, it is not enabled over another base.
+func (d *debugState) wrap(base allocator.MemoryAllocator, config debugConfig, w io.Writer) allocator.MemoryAllocator {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.done = make(chan struct{})
+
+	m := base
+	if config.guard {
+		if base != nil {
+			fmt.Fprintf(w, "goumem: %s: guard disabled: the memory allocator is not the default one\n", debugEnv)
+		} else if guard, err := allocator.NewGuardAllocator(memsyscall.New()); err != nil {
+			fmt.Fprintf(w, "goumem: %s: guard disabled: %v\n", debugEnv, err)
+		} else {
+			m = guard
+		}
+	}
+
+	if m == nil {
+		m = allocator.Default()
+	}
+
+	d.base = m
+
+	if config.canary {
+		m = allocator.NewCanaryAllocator(m)
+	}
+
+	if config.leakcheck {
+		d.leaks = allocator.NewLeakChecker(m)
+		m = d.leaks
+	}
+
+	d.verifier, _ = m.(allocator.Verifier)
+
+	if config.verify > 0 {
+		if d.verifier == nil {
+			fmt.Fprintf(w, "goumem: %s: verify disabled: the allocator can not be verified\n", debugEnv)
+		} else {
+			go verifyEvery(d.verifier, config.verify, d.done)
+		}
+	}
+
+	if config.trace != "" {
+		file, err := os.Create(config.trace)
+		if err != nil {
+			fmt.Fprintf(w, "goumem: %s: trace disabled: %v\n", debugEnv, err)
+		} else {
+			recorder, err := trace.NewRecorder(m, file)
+			if err != nil {
+				file.Close()
+				fmt.Fprintf(w, "goumem: %s: trace disabled: %v\n", debugEnv, err)
+			} else {
+				d.recorder = recorder
+				m = recorder
+				go d.flushEvery(traceFlushInterval, d.done)
+			}
+		}
+	}
+
+	d.top = m
+
+	return m
+}
+
+// stop stops the goroutines started by wrap.
+func (d *debugState) stop() {
+	d.mutex.Lock()
+	done := d.done
+	d.mutex.Unlock()
+
+	if done == nil {
+		return
+	}
+
+	d.stopOnce.Do(func() {
+		close(done)
+	})
+}
+
+// verifyEvery verifies v every interval until done is closed, and panics as soon as it is corrupted.
+func verifyEvery(v allocator.Verifier, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := v.Verify(); err != nil {
+				panic(fmt.Sprintf("goumem: %s: %v", debugEnv, err))
+			}
+		}
+	}
+}
+
+// flushEvery flushes the trace recorded by d every interval until done is closed.
+func (d *debugState) flushEvery(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			d.mutex.Lock()
+			recorder := d.recorder
+			d.mutex.Unlock()
+
+			if err := recorder.Flush(); err != nil {
+				fmt.Fprintf(os.Stderr, "goumem: %s: could not flush trace: %v\n", debugEnv, err)
+				return
+			}
+		}
+	}
+}
+
+// WriteLeaks writes the blocks of the global allocator that are still live to w,
+// with their allocation stacks. It is a no-op unless GOUMEMDEBUG has leakcheck=1.
+func WriteLeaks(w io.Writer) error {
+	debug.mutex.Lock()
+	leaks := debug.leaks
+	debug.mutex.Unlock()
+
+	if leaks == nil {
+		return nil
+	}
+
+	return leaks.WriteLeaks(w)
+}
+
+// FlushTrace flushes the trace recorded by the global allocator to its file,
+// typically right before the program exits. It is a no-op unless GOUMEMDEBUG has trace set.
+func FlushTrace() error {
+	debug.mutex.Lock()
+	recorder := debug.recorder
+	debug.mutex.Unlock()
+
+	if recorder == nil {
+		return nil
+	}
+
+	return recorder.Flush()
+}
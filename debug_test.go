@@ -0,0 +1,166 @@
+package goumem
+
+import (
+	"bytes"
+	"github.com/exapsy/goumem/allocator"
+	"github.com/exapsy/goumem/allocator/trace"
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+type DebugTestSuite struct {
+	suite.Suite
+}
+
+func (s *DebugTestSuite) TestParse() {
+	var warnings bytes.Buffer
+	config := parseDebug("guard=1, canary=1,leakcheck=0,trace=/tmp/t.bin,verify=100ms", &warnings)
+
+	s.Equal(debugConfig{
+		guard:  true,
+		canary: true,
+		trace:  "/tmp/t.bin",
+		verify: 100 * time.Millisecond,
+	}, config)
+	s.Empty(warnings.String())
+}
+
+func (s *DebugTestSuite) TestParseWarnings() {
+	var warnings bytes.Buffer
+	config := parseDebug("canary=1,gaurd=1,leakcheck=yes,verify=-1s", &warnings)
+
+	s.Equal(debugConfig{canary: true}, config)
+	s.Contains(warnings.String(), `GOUMEMDEBUG: unknown setting "gaurd", ignored`)
+	s.Contains(warnings.String(), `invalid value "yes" for leakcheck`)
+	s.Contains(warnings.String(), `invalid value "-1s" for verify`)
+}
+
+func (s *DebugTestSuite) TestWrap() {
+	var d debugState
+	var warnings bytes.Buffer
+	path := filepath.Join(s.T().TempDir(), "trace.bin")
+
+	m := d.wrap(allocator.Default(), debugConfig{canary: true, leakcheck: true, trace: path}, &warnings)
+	defer d.stop()
+	s.Empty(warnings.String())
+	s.IsType(&trace.Recorder{}, m)
+
+	block, err := m.Alloc(16)
+	if err != nil {
+		s.FailNow("Failed to allocate block", err)
+	}
+
+	// canary
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(block.Addr())), 17), make([]byte, 17))
+	s.ErrorIs(m.Free(block), allocator.ErrCanaryCorrupted)
+
+	// leakcheck
+	leaked, err := m.Alloc(8)
+	if err != nil {
+		s.FailNow("Failed to allocate block", err)
+	}
+	var report bytes.Buffer
+	s.NoError(d.leaks.WriteLeaks(&report))
+	s.Contains(report.String(), "1 blocks leaked, 8 bytes")
+	s.NoError(m.Free(leaked))
+
+	// trace
+	s.NoError(d.recorder.Flush())
+	file, err := os.Open(path)
+	if err != nil {
+		s.FailNow("Failed to open trace", err)
+	}
+	defer file.Close()
+	reader, err := trace.NewReader(file)
+	if err != nil {
+		s.FailNow("Failed to read trace", err)
+	}
+	event, err := reader.Next()
+	s.NoError(err)
+	s.Equal(trace.OpAlloc, event.Op)
+}
+
+func (s *DebugTestSuite) TestWrapWarnings() {
+	var d debugState
+	var warnings bytes.Buffer
+
+	m := d.wrap(allocator.Default(), debugConfig{trace: filepath.Join(s.T().TempDir(), "missing", "trace.bin")}, &warnings)
+	defer d.stop()
+	s.Contains(warnings.String(), "trace disabled")
+	s.Nil(d.recorder)
+	s.NotNil(m)
+}
+
+func (s *DebugTestSuite) TestWrapGuard() {
+	var d debugState
+	var warnings bytes.Buffer
+
+	base := allocator.NewDefaultMemoryAllocator()
+	m := d.wrap(base, debugConfig{guard: true}, &warnings)
+	defer d.stop()
+	s.Contains(warnings.String(), "guard disabled: the memory allocator is not the default one")
+	s.Equal(base, m)
+
+	var guarded debugState
+	warnings.Reset()
+	m = guarded.wrap(nil, debugConfig{guard: true}, &warnings)
+	defer guarded.stop()
+	if warnings.Len() == 0 {
+		s.IsType(&allocator.GuardAllocator{}, m)
+	}
+}
+
+func (s *DebugTestSuite) TestFlushEvery() {
+	var d debugState
+	var warnings bytes.Buffer
+	path := filepath.Join(s.T().TempDir(), "trace.bin")
+
+	m := d.wrap(allocator.Default(), debugConfig{trace: path, verify: time.Millisecond}, &warnings)
+	defer d.stop()
+	s.Empty(warnings.String())
+
+	block, err := m.Alloc(16)
+	if err != nil {
+		s.FailNow("Failed to allocate block", err)
+	}
+	defer m.Free(block)
+
+	// the header is flushed when the recording starts, the event by flushEvery
+	header := int64(len("goumemtr") + 1)
+	s.Eventually(func() bool {
+		info, err := os.Stat(path)
+		return err == nil && info.Size() > header
+	}, 3*traceFlushInterval, 10*time.Millisecond)
+
+	d.stop()
+	d.stop()
+}
+
+func (s *DebugTestSuite) TestInit() {
+	if os.Getenv("GOUMEM_TEST_INIT") != "" {
+		// in the test binary started below, with GOUMEMDEBUG set before the package was initialised
+		s.IsType(&allocator.GuardAllocator{}, GetMemoryAllocator())
+		return
+	}
+
+	if !memsyscall.New().Capabilities().Has(memsyscall.CapProtect) {
+		s.T().Skip("guard pages need memory protection")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDebugSuite$/^TestInit$", "-test.v")
+	cmd.Env = append(os.Environ(), "GOUMEM_TEST_INIT=1", debugEnv+"=guard=1")
+	out, err := cmd.CombinedOutput()
+	s.NoError(err, string(out))
+	s.NotContains(string(out), "guard disabled")
+	s.Contains(string(out), "--- PASS: TestDebugSuite/TestInit")
+}
+
+func TestDebugSuite(t *testing.T) {
+	suite.Run(t, new(DebugTestSuite))
+}
@@ -0,0 +1,137 @@
+// Package goumemtest provides helpers to test and benchmark code built on goumem.
+package goumemtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/exapsy/goumem/allocator"
+)
+
+// NoLeaks fails the test at cleanup if alloc holds blocks
+// that were not live when NoLeaks was called, and lists them.
+//
+// The blocks are listed if alloc is a [allocator.HeapWalker],
+// otherwise leaks are detected from its [allocator.Stats].
+func NoLeaks(t testing.TB, alloc allocator.MemoryAllocator) {
+	t.Helper()
+
+	before := liveBlocks(alloc)
+	stats, hasStats := allocator.ReadStats(alloc)
+	if before == nil && !hasStats {
+		t.Fatalf("goumemtest: %T can not report its live blocks", alloc)
+	}
+
+	t.Cleanup(func() {
+		t.Helper()
+
+		if before == nil {
+			after, _ := allocator.ReadStats(alloc)
+			if after.LiveBlocks > stats.LiveBlocks {
+				t.Errorf("goumemtest: %d blocks leaked, %d bytes live instead of %d",
+					after.LiveBlocks-stats.LiveBlocks, after.LiveBytes, stats.LiveBytes)
+			}
+
+			return
+		}
+
+		var leaked []allocator.HeapBlock
+		var leakedBytes uintptr
+		for key, block := range liveBlocks(alloc) {
+			if _, ok := before[key]; !ok {
+				if block.Block != nil {
+					block.Size = block.Block.Size()
+				}
+				leaked = append(leaked, block)
+				leakedBytes += block.Size
+			}
+		}
+
+		if len(leaked) == 0 {
+			return
+		}
+
+		var report strings.Builder
+		fmt.Fprintf(&report, "goumemtest: %d blocks leaked, %d bytes:", len(leaked), leakedBytes)
+		sort.Slice(leaked, func(i, j int) bool {
+			return leaked[i].Addr < leaked[j].Addr
+		})
+		for _, block := range leaked {
+			fmt.Fprintf(&report, "\n\t%#x: %d bytes", block.Addr, block.Size)
+		}
+		t.Error(report.String())
+	})
+}
+
+// AllocsPerRun is the off-heap equivalent of [testing.AllocsPerRun].
+// It returns the average count of blocks f allocates from alloc per run,
+// after a warm-up run.
+// It panics if alloc keeps no [allocator.Stats].
+func AllocsPerRun(alloc allocator.MemoryAllocator, runs int, f func()) float64 {
+	f()
+
+	before := mustReadStats(alloc)
+	for i := 0; i < runs; i++ {
+		f()
+	}
+	after := mustReadStats(alloc)
+
+	return float64(after.Allocs-before.Allocs) / float64(runs)
+}
+
+// ReportMetric reports the off-heap bytes and allocations per op
+// made from alloc by the benchmark, from the call until the returned function is called:
+//
+//	defer goumemtest.ReportMetric(b, alloc)()
+//
+// It panics if alloc keeps no [allocator.Stats].
+func ReportMetric(b *testing.B, alloc allocator.MemoryAllocator) func() {
+	before := mustReadStats(alloc)
+
+	return func() {
+		after := mustReadStats(alloc)
+		n := float64(b.N)
+
+		b.ReportMetric(float64(after.AllocatedBytes-before.AllocatedBytes)/n, "offheap-B/op")
+		b.ReportMetric(float64(after.Allocs-before.Allocs)/n, "offheap-allocs/op")
+	}
+}
+
+// blockKey identifies a live block by its [allocator.AllocatedBlock],
+// so that a block allocated at the address of a freed one is told apart from it,
+// and by address if the heap walker does not report it.
+type blockKey struct {
+	block *allocator.AllocatedBlock
+	addr  uintptr
+}
+
+// liveBlocks returns the live blocks of alloc,
+// or nil if it can not walk its heap.
+func liveBlocks(alloc allocator.MemoryAllocator) map[blockKey]allocator.HeapBlock {
+	walker, ok := alloc.(allocator.HeapWalker)
+	if !ok {
+		return nil
+	}
+
+	blocks := map[blockKey]allocator.HeapBlock{}
+	walker.WalkHeap(func(block allocator.HeapBlock) bool {
+		if !block.Free {
+			blocks[blockKey{block: block.Block, addr: block.Addr}] = block
+		}
+
+		return true
+	})
+
+	return blocks
+}
+
+func mustReadStats(alloc allocator.MemoryAllocator) allocator.Stats {
+	stats, ok := allocator.ReadStats(alloc)
+	if !ok {
+		panic(fmt.Sprintf("goumemtest: %T keeps no statistics", alloc))
+	}
+
+	return stats
+}
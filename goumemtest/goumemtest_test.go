@@ -0,0 +1,159 @@
+package goumemtest
+
+import (
+	"fmt"
+	"github.com/exapsy/goumem/allocator"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type GoumemTestTestSuite struct {
+	suite.Suite
+}
+
+// recordingTB records the errors and cleanups of a test, instead of failing it.
+type recordingTB struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (tb *recordingTB) Helper() {}
+
+func (tb *recordingTB) Cleanup(fn func()) {
+	tb.cleanups = append(tb.cleanups, fn)
+}
+
+func (tb *recordingTB) Error(args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprint(args...))
+}
+
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+// statsOnlyAllocator hides every interface of the allocator it wraps but its stats.
+type statsOnlyAllocator struct {
+	allocator.MemoryAllocator
+}
+
+func (a statsOnlyAllocator) Stats() allocator.Stats {
+	stats, _ := allocator.ReadStats(a.MemoryAllocator)
+	return stats
+}
+
+func (tb *recordingTB) cleanup() {
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+func (suite *GoumemTestTestSuite) TestNoLeaks() {
+	alloc := allocator.Default()
+	before, err := alloc.Alloc(8)
+	suite.NoError(err)
+
+	tb := &recordingTB{}
+	NoLeaks(tb, alloc)
+
+	freed, err := alloc.Alloc(16)
+	suite.NoError(err)
+	suite.NoError(alloc.Free(freed))
+	leaked, err := alloc.Alloc(24)
+	suite.NoError(err)
+
+	tb.cleanup()
+	suite.Len(tb.errors, 1)
+	suite.Contains(tb.errors[0], "1 blocks leaked, 24 bytes")
+	suite.Contains(tb.errors[0], fmt.Sprintf("%#x: 24 bytes", leaked.Addr()))
+	suite.NotContains(tb.errors[0], fmt.Sprintf("%#x", before.Addr()))
+}
+
+func (suite *GoumemTestTestSuite) TestNoLeaksSameAddress() {
+	alloc := allocator.NewDefaultMemoryAllocator()
+	freed, err := alloc.Alloc(16)
+	suite.NoError(err)
+
+	tb := &recordingTB{}
+	NoLeaks(tb, alloc)
+
+	addr := freed.Addr()
+	suite.NoError(alloc.Free(freed))
+	leaked, err := alloc.Alloc(16)
+	suite.NoError(err)
+	suite.Equal(addr, leaked.Addr())
+
+	tb.cleanup()
+	suite.Len(tb.errors, 1)
+	suite.Contains(tb.errors[0], fmt.Sprintf("%#x: 16 bytes", leaked.Addr()))
+}
+
+func (suite *GoumemTestTestSuite) TestNoLeaksChild() {
+	alloc := allocator.NewChildAllocator(allocator.Default(), 0)
+
+	tb := &recordingTB{}
+	NoLeaks(tb, alloc)
+	block, err := alloc.Alloc(24)
+	suite.NoError(err)
+	suite.NoError(alloc.Free(block))
+
+	tb.cleanup()
+	suite.Empty(tb.errors)
+}
+
+func (suite *GoumemTestTestSuite) TestNoLeaksStats() {
+	alloc := statsOnlyAllocator{allocator.Default()}
+	large, err := alloc.Alloc(100)
+	suite.NoError(err)
+
+	tb := &recordingTB{}
+	NoLeaks(tb, alloc)
+
+	// more blocks than before, but fewer bytes
+	suite.NoError(alloc.Free(large))
+	for i := 0; i < 2; i++ {
+		_, err := alloc.Alloc(8)
+		suite.NoError(err)
+	}
+
+	tb.cleanup()
+	suite.Equal([]string{"goumemtest: 1 blocks leaked, 16 bytes live instead of 100"}, tb.errors)
+}
+
+func (suite *GoumemTestTestSuite) TestAllocsPerRun() {
+	alloc := allocator.Default()
+
+	allocs := AllocsPerRun(alloc, 10, func() {
+		first, _ := alloc.Alloc(8)
+		second, _ := alloc.Alloc(8)
+		alloc.Free(first)
+		alloc.Free(second)
+	})
+	suite.Equal(2.0, allocs)
+}
+
+func TestGoumemTestTestSuite(t *testing.T) {
+	suite.Run(t, new(GoumemTestTestSuite))
+}
+
+func TestNoLeaks(t *testing.T) {
+	alloc := allocator.Default()
+	NoLeaks(t, alloc)
+
+	block, err := alloc.Alloc(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alloc.Free(block)
+}
+
+func BenchmarkReportMetric(b *testing.B) {
+	alloc := allocator.Default()
+	defer ReportMetric(b, alloc)()
+
+	for i := 0; i < b.N; i++ {
+		block, _ := alloc.Alloc(64)
+		alloc.Free(block)
+	}
+}
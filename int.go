@@ -55,9 +55,3 @@ func (ptr *PointerInt) Free() error {
 
 	return mem.Free(ptr.allocatedBlock)
 }
-
-func init() {
-	if mem == nil {
-		mem = allocator.Default()
-	}
-}
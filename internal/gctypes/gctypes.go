@@ -0,0 +1,43 @@
+// Package gctypes answers questions about types that matter to the garbage collector,
+// for the tools that check or generate off-heap code.
+package gctypes
+
+import (
+	"go/types"
+)
+
+// HasPointers reports whether values of t contain pointers the garbage collector follows.
+// Type parameters are reported as pointer-free, their type arguments are checked where they are instantiated.
+func HasPointers(t types.Type) bool {
+	return hasPointers(t, map[types.Type]bool{})
+}
+
+func hasPointers(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	// the underlying type of a type parameter is its constraint
+	if _, ok := t.(*types.TypeParam); ok {
+		return false
+	}
+
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		return t.Kind() == types.String || t.Kind() == types.UnsafePointer
+	case *types.Array:
+		return t.Len() > 0 && hasPointers(t.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasPointers(t.Field(i).Type(), seen) {
+				return true
+			}
+		}
+
+		return false
+	default:
+		// pointers, slices, maps, channels, functions and interfaces
+		return true
+	}
+}
@@ -0,0 +1,39 @@
+package gctypes
+
+import (
+	"github.com/stretchr/testify/suite"
+	"go/types"
+	"testing"
+)
+
+type GCTypesTestSuite struct {
+	suite.Suite
+}
+
+func (suite *GCTypesTestSuite) TestHasPointers() {
+	field := func(name string, t types.Type) *types.Var {
+		return types.NewField(0, nil, name, t, false)
+	}
+	plain := types.NewStruct([]*types.Var{
+		field("a", types.Typ[types.Int64]),
+		field("b", types.NewArray(types.Typ[types.Float64], 4)),
+	}, nil)
+	withString := types.NewStruct([]*types.Var{
+		field("a", types.Typ[types.Int64]),
+		field("b", types.Typ[types.String]),
+	}, nil)
+	param := types.NewTypeParam(types.NewTypeName(0, nil, "T", nil), types.NewInterfaceType(nil, nil))
+
+	suite.False(HasPointers(types.Typ[types.Int]))
+	suite.False(HasPointers(plain))
+	suite.False(HasPointers(types.NewArray(types.NewPointer(types.Typ[types.Int]), 0)))
+	suite.False(HasPointers(param))
+	suite.True(HasPointers(withString))
+	suite.True(HasPointers(types.Typ[types.UnsafePointer]))
+	suite.True(HasPointers(types.NewSlice(types.Typ[types.Byte])))
+	suite.True(HasPointers(types.NewArray(types.NewPointer(types.Typ[types.Int]), 1)))
+}
+
+func TestGCTypesTestSuite(t *testing.T) {
+	suite.Run(t, new(GCTypesTestSuite))
+}
@@ -7,7 +7,7 @@ import (
 )
 
 var (
-	ErrMatrixZeroSize = fmt.Errorf("goumem: matrix size cannot be zero")
+	ErrMatrixZeroSize = fmt.Errorf("goumem: matrix size cannot be zero: %w", allocator.ErrInvalidSize)
 )
 
 type PointerMatrixFloat64 struct {
@@ -0,0 +1,13 @@
+//go:build !plan9
+
+package memsyscall
+
+import "syscall"
+
+// The errors the syscalls wrap when memory runs out and for invalid arguments,
+// so that they can be compared with [errors.Is] on every platform.
+// They are [syscall.ENOMEM] and [syscall.EINVAL], except on plan9 which has no errno.
+var (
+	ErrNoMemory error = syscall.ENOMEM
+	ErrInvalid  error = syscall.EINVAL
+)
@@ -0,0 +1,9 @@
+package memsyscall
+
+import "syscall"
+
+// plan9 reports errors as strings, these match the ones of its kernel.
+var (
+	ErrNoMemory error = syscall.ErrorString("no memory")
+	ErrInvalid  error = syscall.ErrorString("bad arg in system call")
+)
@@ -0,0 +1,348 @@
+// Package fake is an in-process [memsyscall.Syscall] for deterministic tests.
+//
+// It hands out memory from a region mapped from the system up front,
+// records every call it receives and enforces an address space limit,
+// so that tests can assert the exact sequence of syscalls an allocator makes
+// and simulate the system running out of memory.
+package fake
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/exapsy/goumem/mem_syscall/internal/spans"
+)
+
+const DefaultPageSize uintptr = 4096
+
+type (
+	Op string
+
+	// Call is a call received by the fake syscall.
+	Call struct {
+		Op   Op
+		Addr uintptr
+		Size uintptr
+		// NewSize is the requested size of a remap.
+		NewSize uintptr
+		Prot    memsyscall.Prot
+		Advice  memsyscall.Advice
+		// Err is the error the call returned.
+		Err error
+	}
+
+	Option func(s *Syscall)
+
+	// Syscall is a fake [memsyscall.Syscall].
+	Syscall struct {
+		system       memsyscall.Syscall
+		region       uintptr
+		regionSize   uintptr
+		base         uintptr
+		limit        uintptr
+		pageSize     uintptr
+		capabilities memsyscall.Capabilities
+
+		mutex    sync.Mutex
+		spans    spans.List
+		mappings map[uintptr]uintptr
+		mapped   uintptr
+		calls    []Call
+	}
+)
+
+const (
+	OpAlloc   Op = "alloc"
+	OpFree    Op = "free"
+	OpProtect Op = "protect"
+	OpAdvise  Op = "advise"
+	OpRemap   Op = "remap"
+	OpLock    Op = "lock"
+	OpUnlock  Op = "unlock"
+)
+
+var (
+	_ memsyscall.Syscall = (*Syscall)(nil)
+)
+
+// New creates a fake syscall that can map at most limit bytes, rounded up to the page size.
+// It supports every operation, unless configured otherwise with [WithCapabilities].
+//
+// The whole region is mapped from the system right away,
+// and it panics if that fails, like a test fixture would.
+func New(limit uintptr, opts ...Option) *Syscall {
+	s := &Syscall{
+		system:       memsyscall.New(),
+		pageSize:     DefaultPageSize,
+		capabilities: memsyscall.CapProtect | memsyscall.CapAdvise | memsyscall.CapRemap | memsyscall.CapLock,
+		mappings:     map[uintptr]uintptr{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.limit = s.align(limit)
+	s.regionSize = s.limit + s.pageSize
+	region, err := s.system.Alloc(s.regionSize)
+	if err != nil {
+		panic(fmt.Errorf("fake: could not map a region of %d bytes: %w", s.regionSize, err))
+	}
+
+	s.region = region
+	s.base = s.align(region)
+	s.spans = spans.New(s.base, s.limit)
+
+	return s
+}
+
+// WithPageSize sets the page size reported by the fake, which must be a power of two.
+func WithPageSize(pageSize uintptr) Option {
+	return func(s *Syscall) {
+		s.pageSize = pageSize
+	}
+}
+
+// WithCapabilities sets the operations the fake supports.
+// The other ones fail with [memsyscall.ErrUnsupported].
+func WithCapabilities(capabilities memsyscall.Capabilities) Option {
+	return func(s *Syscall) {
+		s.capabilities = capabilities
+	}
+}
+
+func (s *Syscall) Alloc(size uintptr) (uintptr, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	addr, err := s.alloc(s.align(size))
+	s.record(Call{Op: OpAlloc, Addr: addr, Size: size, Err: err})
+
+	return addr, err
+}
+
+func (s *Syscall) Free(addr uintptr, size uintptr) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.unmap(addr, s.align(size))
+	s.record(Call{Op: OpFree, Addr: addr, Size: size, Err: err})
+
+	return err
+}
+
+func (s *Syscall) PageSize() uintptr {
+	return s.pageSize
+}
+
+// Protect only records the call, the fake can not enforce protections.
+func (s *Syscall) Protect(addr, size uintptr, prot memsyscall.Prot) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.supported(memsyscall.CapProtect, addr, size)
+	s.record(Call{Op: OpProtect, Addr: addr, Size: size, Prot: prot, Err: err})
+
+	return err
+}
+
+// Advise zeroes the range for [memsyscall.AdviceDontNeed], like the system would.
+func (s *Syscall) Advise(addr, size uintptr, advice memsyscall.Advice) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.supported(memsyscall.CapAdvise, addr, size)
+	if err == nil && advice == memsyscall.AdviceDontNeed {
+		clear(s.bytes(addr, size))
+	}
+	s.record(Call{Op: OpAdvise, Addr: addr, Size: size, Advice: advice, Err: err})
+
+	return err
+}
+
+func (s *Syscall) Remap(addr, oldSize, newSize uintptr) (uintptr, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	newAddr, err := s.remap(addr, s.align(oldSize), s.align(newSize))
+	s.record(Call{Op: OpRemap, Addr: addr, Size: oldSize, NewSize: newSize, Err: err})
+
+	return newAddr, err
+}
+
+func (s *Syscall) Lock(addr, size uintptr) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.supported(memsyscall.CapLock, addr, size)
+	s.record(Call{Op: OpLock, Addr: addr, Size: size, Err: err})
+
+	return err
+}
+
+func (s *Syscall) Unlock(addr, size uintptr) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.supported(memsyscall.CapLock, addr, size)
+	s.record(Call{Op: OpUnlock, Addr: addr, Size: size, Err: err})
+
+	return err
+}
+
+func (s *Syscall) Capabilities() memsyscall.Capabilities {
+	return s.capabilities
+}
+
+// Calls returns every call recorded since the fake was created or last reset.
+func (s *Syscall) Calls() []Call {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Call(nil), s.calls...)
+}
+
+// Reset forgets the recorded calls. The mappings are kept.
+func (s *Syscall) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = nil
+}
+
+// Mapped returns the amount of bytes currently mapped.
+func (s *Syscall) Mapped() uintptr {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.mapped
+}
+
+// Mappings returns the count of live mappings.
+func (s *Syscall) Mappings() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.mappings)
+}
+
+// Limit returns the address space limit of the fake.
+func (s *Syscall) Limit() uintptr {
+	return s.limit
+}
+
+// Close unmaps the region of the fake.
+// Every mapping made from it becomes invalid.
+func (s *Syscall) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.regionSize == 0 {
+		return nil
+	}
+
+	err := s.system.Free(s.region, s.regionSize)
+	if err != nil {
+		return err
+	}
+
+	s.regionSize = 0
+	s.spans = spans.List{}
+	s.mappings = map[uintptr]uintptr{}
+	s.mapped = 0
+
+	return nil
+}
+
+// Contains reports whether addr belongs to the region of the fake.
+func (s *Syscall) Contains(addr uintptr) bool {
+	return addr >= s.base && addr-s.base < s.limit
+}
+
+func (s *Syscall) record(call Call) {
+	s.calls = append(s.calls, call)
+}
+
+func (s *Syscall) align(size uintptr) uintptr {
+	return (size + s.pageSize - 1) &^ (s.pageSize - 1)
+}
+
+func (s *Syscall) bytes(addr, size uintptr) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(addr)), size)
+}
+
+// supported checks that the capability is supported and that the range is mapped.
+func (s *Syscall) supported(capability memsyscall.Capabilities, addr, size uintptr) error {
+	if !s.capabilities.Has(capability) {
+		return memsyscall.ErrUnsupported
+	}
+
+	if !s.isMapped(addr, s.align(size)) {
+		return fmt.Errorf("fake: range %#x+%d is not mapped: %w", addr, size, memsyscall.ErrInvalid)
+	}
+
+	return nil
+}
+
+func (s *Syscall) isMapped(addr, size uintptr) bool {
+	for mappingAddr, mappingSize := range s.mappings {
+		if addr >= mappingAddr && addr+size <= mappingAddr+mappingSize {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Syscall) alloc(size uintptr) (uintptr, error) {
+	if size == 0 {
+		return 0, fmt.Errorf("fake: can not map zero bytes: %w", memsyscall.ErrInvalid)
+	}
+
+	addr, ok := s.spans.Take(size)
+	if !ok {
+		return 0, fmt.Errorf("fake: address space limit of %d bytes reached: %w", s.limit, memsyscall.ErrNoMemory)
+	}
+
+	s.mappings[addr] = size
+	s.mapped += size
+
+	return addr, nil
+}
+
+func (s *Syscall) unmap(addr, size uintptr) error {
+	mappingSize, ok := s.mappings[addr]
+	if !ok || mappingSize != size {
+		return fmt.Errorf("fake: range %#x+%d is not a mapping: %w", addr, size, memsyscall.ErrInvalid)
+	}
+
+	// mappings always start zeroed
+	clear(s.bytes(addr, size))
+
+	delete(s.mappings, addr)
+	s.mapped -= size
+	s.spans.Put(addr, size)
+
+	return nil
+}
+
+func (s *Syscall) remap(addr, oldSize, newSize uintptr) (uintptr, error) {
+	if !s.capabilities.Has(memsyscall.CapRemap) {
+		return 0, memsyscall.ErrUnsupported
+	}
+
+	if size, ok := s.mappings[addr]; !ok || size != oldSize {
+		return 0, fmt.Errorf("fake: range %#x+%d is not a mapping: %w", addr, oldSize, memsyscall.ErrInvalid)
+	}
+
+	newAddr, err := s.alloc(newSize)
+	if err != nil {
+		return 0, err
+	}
+
+	copy(s.bytes(newAddr, newSize), s.bytes(addr, min(oldSize, newSize)))
+
+	return newAddr, s.unmap(addr, oldSize)
+}
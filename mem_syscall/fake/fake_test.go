@@ -0,0 +1,136 @@
+package fake
+
+import (
+	"errors"
+	memsyscall "github.com/exapsy/goumem/mem_syscall"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"unsafe"
+)
+
+type FakeTestSuite struct {
+	suite.Suite
+}
+
+func (suite *FakeTestSuite) TestCalls() {
+	sys := New(16 * DefaultPageSize)
+
+	addr, err := sys.Alloc(100)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+	suite.True(sys.Contains(addr))
+	suite.Zero(addr % DefaultPageSize)
+
+	suite.NoError(sys.Advise(addr, DefaultPageSize, memsyscall.AdviceFree))
+	suite.NoError(sys.Free(addr, 100))
+
+	suite.Equal([]Call{
+		{Op: OpAlloc, Addr: addr, Size: 100},
+		{Op: OpAdvise, Addr: addr, Size: DefaultPageSize, Advice: memsyscall.AdviceFree},
+		{Op: OpFree, Addr: addr, Size: 100},
+	}, sys.Calls())
+	suite.Zero(sys.Mapped())
+	suite.Zero(sys.Mappings())
+
+	sys.Reset()
+	suite.Empty(sys.Calls())
+}
+
+func (suite *FakeTestSuite) TestLimit() {
+	sys := New(4 * DefaultPageSize)
+
+	first, err := sys.Alloc(3 * DefaultPageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	_, err = sys.Alloc(2 * DefaultPageSize)
+	suite.ErrorIs(err, memsyscall.ErrNoMemory)
+	suite.Equal(3*DefaultPageSize, sys.Mapped())
+
+	// freed address space is reused
+	suite.NoError(sys.Free(first, 3*DefaultPageSize))
+	second, err := sys.Alloc(4 * DefaultPageSize)
+	suite.NoError(err)
+	suite.Equal(first, second)
+}
+
+func (suite *FakeTestSuite) TestMemory() {
+	sys := New(4 * DefaultPageSize)
+
+	addr, err := sys.Alloc(DefaultPageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	*(*int)(unsafe.Pointer(addr)) = 42
+	suite.Equal(42, *(*int)(unsafe.Pointer(addr)))
+
+	suite.NoError(sys.Advise(addr, DefaultPageSize, memsyscall.AdviceDontNeed))
+	suite.Zero(*(*int)(unsafe.Pointer(addr)))
+
+	*(*int)(unsafe.Pointer(addr)) = 42
+	newAddr, err := sys.Remap(addr, DefaultPageSize, 2*DefaultPageSize)
+	suite.NoError(err)
+	suite.Equal(42, *(*int)(unsafe.Pointer(newAddr)))
+	suite.Equal(2*DefaultPageSize, sys.Mapped())
+}
+
+func (suite *FakeTestSuite) TestInvalid() {
+	sys := New(4 * DefaultPageSize)
+
+	addr, err := sys.Alloc(2 * DefaultPageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	suite.ErrorIs(sys.Free(addr, DefaultPageSize), memsyscall.ErrInvalid)
+	suite.ErrorIs(sys.Lock(addr+4*DefaultPageSize, DefaultPageSize), memsyscall.ErrInvalid)
+
+	calls := sys.Calls()
+	suite.ErrorIs(calls[len(calls)-1].Err, memsyscall.ErrInvalid)
+}
+
+func (suite *FakeTestSuite) TestCapabilities() {
+	sys := New(4*DefaultPageSize, WithCapabilities(memsyscall.CapAdvise))
+
+	addr, err := sys.Alloc(DefaultPageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	suite.NoError(sys.Advise(addr, DefaultPageSize, memsyscall.AdviceDontNeed))
+	suite.True(errors.Is(sys.Protect(addr, DefaultPageSize, memsyscall.ProtRead), memsyscall.ErrUnsupported))
+	_, err = sys.Remap(addr, DefaultPageSize, 2*DefaultPageSize)
+	suite.ErrorIs(err, memsyscall.ErrUnsupported)
+	suite.ErrorIs(sys.Lock(addr, DefaultPageSize), memsyscall.ErrUnsupported)
+}
+
+func (suite *FakeTestSuite) TestPageSize() {
+	sys := New(100, WithPageSize(1<<16))
+
+	suite.Equal(uintptr(1<<16), sys.PageSize())
+	suite.Equal(uintptr(1<<16), sys.Limit())
+
+	addr, err := sys.Alloc(1)
+	suite.NoError(err)
+	suite.Zero(addr % (1 << 16))
+}
+
+func (suite *FakeTestSuite) TestClose() {
+	sys := New(4 * DefaultPageSize)
+
+	_, err := sys.Alloc(DefaultPageSize)
+	suite.NoError(err)
+	suite.NoError(sys.Close())
+	suite.Zero(sys.Mapped())
+
+	_, err = sys.Alloc(DefaultPageSize)
+	suite.ErrorIs(err, memsyscall.ErrNoMemory)
+	suite.NoError(sys.Close())
+}
+
+func TestFakeTestSuite(t *testing.T) {
+	suite.Run(t, new(FakeTestSuite))
+}
@@ -0,0 +1,143 @@
+package memsyscall
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// heapPageSize is the page size of [Heap], the usual page size of the systems.
+const heapPageSize = 4096
+
+// Heap is a [Syscall] that allocates from the Go heap instead of mapping memory from the system,
+// for the platforms goumem can not map memory on, like wasip1, js, plan9 or solaris.
+// [New] returns it on those platforms, and on the others when built with the goumem_heap build tag.
+//
+// Every allocation is a page-aligned slab of its own byte array, pinned until it is freed,
+// so that its address stays valid while it is only held as an uintptr.
+// Like mapped memory, slabs are never scanned by the GC,
+// they must not hold the only reference to Go memory.
+//
+// checkptr, which the race detector turns on, rejects any pointer made from an uintptr into the Go heap,
+// so with the race detector the slabs are mapped from the system instead,
+// the race detector only runs on systems that can map memory.
+//
+// Protecting, advising and locking memory is not supported,
+// the memory of the Go heap can not be handed back to the system.
+// Running out of memory is fatal, like any allocation of the Go heap.
+type Heap struct {
+	mutex sync.Mutex
+	// slabs maps the start address of every slab to it.
+	slabs map[uintptr]*heapSlab
+}
+
+// NewHeap creates a syscall allocating from the Go heap.
+func NewHeap() *Heap {
+	h := &Heap{
+		slabs: map[uintptr]*heapSlab{},
+	}
+	// the pinner of a slab collected while still pinned panics,
+	// so the slabs left when the heap is collected are released with it.
+	runtime.SetFinalizer(h, (*Heap).releaseAll)
+
+	return h
+}
+
+func (h *Heap) Alloc(size uintptr) (uintptr, error) {
+	slab, err := h.alloc(size)
+	if err != nil {
+		return 0, err
+	}
+
+	return slab.addr(), nil
+}
+
+func (h *Heap) Free(addr uintptr, size uintptr) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	slab, ok := h.slabs[addr]
+	if !ok {
+		return fmt.Errorf("failed to free memory at %#x: %w", addr, ErrInvalid)
+	}
+
+	delete(h.slabs, addr)
+
+	return slab.release()
+}
+
+func (h *Heap) PageSize() uintptr {
+	return heapPageSize
+}
+
+func (h *Heap) Protect(addr, size uintptr, prot Prot) error {
+	return ErrUnsupported
+}
+
+func (h *Heap) Advise(addr, size uintptr, advice Advice) error {
+	return ErrUnsupported
+}
+
+// Remap allocates a new slab and copies the content of the old one to it.
+func (h *Heap) Remap(addr, oldSize, newSize uintptr) (uintptr, error) {
+	h.mutex.Lock()
+	old, ok := h.slabs[addr]
+	h.mutex.Unlock()
+
+	if !ok || oldSize > uintptr(len(old.bytes)) {
+		return 0, fmt.Errorf("memory at %#x is not a slab of %d bytes: %w", addr, oldSize, ErrInvalid)
+	}
+
+	slab, err := h.alloc(newSize)
+	if err != nil {
+		return 0, err
+	}
+
+	copy(slab.bytes, old.bytes[:oldSize])
+
+	return slab.addr(), h.Free(addr, oldSize)
+}
+
+func (h *Heap) Lock(addr, size uintptr) error {
+	return ErrUnsupported
+}
+
+func (h *Heap) Unlock(addr, size uintptr) error {
+	return ErrUnsupported
+}
+
+func (h *Heap) Capabilities() Capabilities {
+	return CapRemap
+}
+
+// alloc allocates a slab of at least size bytes, and indexes it by its start address.
+func (h *Heap) alloc(size uintptr) (*heapSlab, error) {
+	if size == 0 {
+		return nil, fmt.Errorf("failed to allocate memory from the heap: %w", ErrInvalid)
+	}
+	if size > math.MaxInt-heapPageSize {
+		return nil, fmt.Errorf("failed to allocate %d bytes from the heap: %w", size, ErrNoMemory)
+	}
+
+	slab, err := newHeapSlab(alignToPage(size, heapPageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	addr := slab.addr()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.slabs[addr] = slab
+
+	return slab, nil
+}
+
+// releaseAll releases every slab, it is only called once nothing references the heap anymore.
+func (h *Heap) releaseAll() {
+	for _, slab := range h.slabs {
+		_ = slab.release()
+	}
+}
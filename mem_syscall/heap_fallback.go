@@ -0,0 +1,32 @@
+//go:build goumem_heap || !(darwin || dragonfly || freebsd || linux || nacl || netbsd || openbsd || windows)
+
+package memsyscall
+
+import "unsafe"
+
+// heap serves every allocation of the platform, reservations included.
+var heap = NewHeap()
+
+// New returns the syscall of the platform, that can not map memory: the Go heap.
+func New() *Heap {
+	return heap
+}
+
+// reserveMemory allocates the whole range up front, the Go heap can not reserve address space.
+func reserveMemory(size uintptr) (uintptr, error) {
+	return heap.Alloc(size)
+}
+
+func commitMemory(addr, size uintptr) error {
+	return nil
+}
+
+// decommitMemory zeroes the range, so that it reads as zeroes when it is committed again.
+func decommitMemory(addr, size uintptr) error {
+	clear(unsafe.Slice((*byte)(unsafe.Pointer(addr)), size))
+	return nil
+}
+
+func releaseMemory(addr, size uintptr) error {
+	return heap.Free(addr, size)
+}
@@ -0,0 +1,36 @@
+//go:build !race
+
+package memsyscall
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// heapSlab is the memory of an allocation of [Heap], a page-aligned part of its own byte array.
+type heapSlab struct {
+	bytes  []byte
+	pinner runtime.Pinner
+}
+
+// newHeapSlab allocates a slab of size bytes, a multiple of the page size, and pins it.
+func newHeapSlab(size uintptr) (*heapSlab, error) {
+	backing := make([]byte, size+heapPageSize)
+	start := uintptr(unsafe.Pointer(&backing[0]))
+	offset := alignToPage(start, heapPageSize) - start
+
+	slab := &heapSlab{bytes: backing[offset : offset+size]}
+	slab.pinner.Pin(&backing[0])
+
+	return slab, nil
+}
+
+func (s *heapSlab) addr() uintptr {
+	return uintptr(unsafe.Pointer(&s.bytes[0]))
+}
+
+// release unpins the slab, the GC collects it once nothing references it anymore.
+func (s *heapSlab) release() error {
+	s.pinner.Unpin()
+	return nil
+}
@@ -0,0 +1,34 @@
+//go:build race && unix
+
+package memsyscall
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// heapSlab is the memory of an allocation of [Heap].
+// With the race detector it is mapped from the system, as checkptr rejects the memory of the Go heap.
+type heapSlab struct {
+	bytes []byte
+}
+
+// newHeapSlab maps a slab of size bytes, a multiple of the page size.
+func newHeapSlab(size uintptr) (*heapSlab, error) {
+	bytes, err := unix.Mmap(-1, 0, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %d bytes for the heap: %w", size, err)
+	}
+
+	return &heapSlab{bytes: bytes}, nil
+}
+
+func (s *heapSlab) addr() uintptr {
+	return uintptr(unsafe.Pointer(&s.bytes[0]))
+}
+
+func (s *heapSlab) release() error {
+	return unix.Munmap(s.bytes)
+}
@@ -0,0 +1,34 @@
+//go:build race && windows
+
+package memsyscall
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// heapSlab is the memory of an allocation of [Heap].
+// With the race detector it is mapped from the system, as checkptr rejects the memory of the Go heap.
+type heapSlab struct {
+	bytes []byte
+}
+
+// newHeapSlab maps a slab of size bytes, a multiple of the page size.
+func newHeapSlab(size uintptr) (*heapSlab, error) {
+	addr, err := windows.VirtualAlloc(0, size, windows.MEM_RESERVE|windows.MEM_COMMIT, windows.PAGE_READWRITE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %d bytes for the heap: %w", size, err)
+	}
+
+	return &heapSlab{bytes: unsafe.Slice((*byte)(unsafe.Pointer(addr)), size)}, nil
+}
+
+func (s *heapSlab) addr() uintptr {
+	return uintptr(unsafe.Pointer(&s.bytes[0]))
+}
+
+func (s *heapSlab) release() error {
+	return windows.VirtualFree(s.addr(), 0, windows.MEM_RELEASE)
+}
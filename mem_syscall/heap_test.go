@@ -0,0 +1,95 @@
+package memsyscall
+
+import (
+	"github.com/stretchr/testify/suite"
+	"runtime"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+type HeapTestSuite struct {
+	suite.Suite
+	heap *Heap
+}
+
+func (suite *HeapTestSuite) SetupTest() {
+	suite.heap = NewHeap()
+}
+
+func (suite *HeapTestSuite) TestAlloc() {
+	addr, err := suite.heap.Alloc(100)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	suite.Zero(addr % suite.heap.PageSize())
+
+	bytes := unsafe.Slice((*byte)(unsafe.Pointer(addr)), suite.heap.PageSize())
+	suite.Equal(make([]byte, suite.heap.PageSize()), bytes)
+	bytes[suite.heap.PageSize()-1] = 1
+
+	suite.NoError(suite.heap.Free(addr, 100))
+	suite.ErrorIs(suite.heap.Free(addr, 100), ErrInvalid)
+}
+
+func (suite *HeapTestSuite) TestInvalidSize() {
+	_, err := suite.heap.Alloc(0)
+	suite.ErrorIs(err, ErrInvalid)
+
+	_, err = suite.heap.Alloc(^uintptr(0))
+	suite.ErrorIs(err, ErrNoMemory)
+}
+
+func (suite *HeapTestSuite) TestRemap() {
+	pageSize := suite.heap.PageSize()
+	addr, err := suite.heap.Alloc(pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+	*(*byte)(unsafe.Pointer(addr + pageSize - 1)) = 1
+
+	newAddr, err := suite.heap.Remap(addr, pageSize, 4*pageSize)
+	if err != nil {
+		suite.FailNow("Failed to remap memory", err)
+	}
+
+	suite.Equal(byte(1), *(*byte)(unsafe.Pointer(newAddr + pageSize - 1)))
+	suite.Equal(byte(0), *(*byte)(unsafe.Pointer(newAddr + 4*pageSize - 1)))
+	suite.ErrorIs(suite.heap.Free(addr, pageSize), ErrInvalid)
+	suite.NoError(suite.heap.Free(newAddr, 4*pageSize))
+}
+
+func (suite *HeapTestSuite) TestUnsupported() {
+	addr, err := suite.heap.Alloc(suite.heap.PageSize())
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	suite.False(suite.heap.Capabilities().Has(CapProtect))
+	suite.False(suite.heap.Capabilities().Has(CapAdvise))
+	suite.ErrorIs(suite.heap.Protect(addr, suite.heap.PageSize(), ProtRead), ErrUnsupported)
+	suite.ErrorIs(suite.heap.Advise(addr, suite.heap.PageSize(), AdviceFree), ErrUnsupported)
+	suite.ErrorIs(suite.heap.Lock(addr, suite.heap.PageSize()), ErrUnsupported)
+	suite.ErrorIs(suite.heap.Unlock(addr, suite.heap.PageSize()), ErrUnsupported)
+
+	suite.NoError(suite.heap.Free(addr, suite.heap.PageSize()))
+}
+
+func (suite *HeapTestSuite) TestCollected() {
+	func() {
+		heap := NewHeap()
+		_, err := heap.Alloc(heap.PageSize())
+		suite.NoError(err)
+	}()
+
+	// collecting a heap that still holds slabs does not panic
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHeapTestSuite(t *testing.T) {
+	suite.Run(t, new(HeapTestSuite))
+}
@@ -0,0 +1,89 @@
+// Package spans hands out the spans of an address range for the backends that carve their memory out of one,
+// in address order, reusing the freed spans before growing into the rest of the range.
+package spans
+
+import "sort"
+
+type (
+	// List is the bookkeeping of the spans of a range. The zero List has nothing to hand out.
+	List struct {
+		base uintptr
+		size uintptr
+		// top is the offset of the first byte of the range that was never handed out.
+		top uintptr
+		// free are the spans below top that were freed, sorted by address and coalesced.
+		free []span
+	}
+	span struct {
+		addr uintptr
+		size uintptr
+	}
+)
+
+// New returns the spans of the range from base to base+size, none of them handed out.
+func New(base, size uintptr) List {
+	return List{base: base, size: size}
+}
+
+// Take hands out size bytes, from the first freed span large enough, or else from the rest of the range.
+// It reports false if no span is large enough.
+func (l *List) Take(size uintptr) (uintptr, bool) {
+	for i, free := range l.free {
+		if free.size < size {
+			continue
+		}
+
+		if free.size == size {
+			l.free = append(l.free[:i], l.free[i+1:]...)
+		} else {
+			l.free[i] = span{addr: free.addr + size, size: free.size - size}
+		}
+
+		return free.addr, true
+	}
+
+	if l.size-l.top < size {
+		return 0, false
+	}
+
+	addr := l.base + l.top
+	l.top += size
+
+	return addr, true
+}
+
+// Put gives a span back, merging it with its neighbours
+// and with the rest of the range.
+func (l *List) Put(addr, size uintptr) {
+	i := sort.Search(len(l.free), func(i int) bool {
+		return l.free[i].addr > addr
+	})
+	l.free = append(l.free, span{})
+	copy(l.free[i+1:], l.free[i:])
+	l.free[i] = span{addr: addr, size: size}
+
+	if i+1 < len(l.free) && l.free[i].addr+l.free[i].size == l.free[i+1].addr {
+		l.free[i].size += l.free[i+1].size
+		l.free = append(l.free[:i+1], l.free[i+2:]...)
+	}
+	if i > 0 && l.free[i-1].addr+l.free[i-1].size == l.free[i].addr {
+		l.free[i-1].size += l.free[i].size
+		l.free = append(l.free[:i], l.free[i+1:]...)
+	}
+
+	if last := len(l.free) - 1; last >= 0 && l.free[last].addr+l.free[last].size == l.base+l.top {
+		l.top = l.free[last].addr - l.base
+		l.free = l.free[:last]
+	}
+}
+
+// Top returns the offset of the first byte of the range that was never handed out,
+// every span handed out lies below it.
+func (l *List) Top() uintptr {
+	return l.top
+}
+
+// Left returns the bytes of the range that were never handed out.
+func (l *List) Left() uintptr {
+	return l.size - l.top
+}
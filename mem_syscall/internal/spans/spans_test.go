@@ -0,0 +1,79 @@
+package spans
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type SpansTestSuite struct {
+	suite.Suite
+	list List
+}
+
+func (suite *SpansTestSuite) SetupTest() {
+	suite.list = New(0x1000, 0x10000)
+}
+
+func (suite *SpansTestSuite) take(size uintptr) uintptr {
+	addr, ok := suite.list.Take(size)
+	if !ok {
+		suite.FailNow("Failed to take span", "size %#x", size)
+	}
+
+	return addr
+}
+
+func (suite *SpansTestSuite) TestTake() {
+	suite.Equal(uintptr(0x1000), suite.take(0x1000))
+	suite.Equal(uintptr(0x2000), suite.take(0x2000))
+	suite.Equal(uintptr(0x3000), suite.list.Top())
+	suite.Equal(uintptr(0xd000), suite.list.Left())
+
+	_, ok := suite.list.Take(0xe000)
+	suite.False(ok)
+
+	suite.Equal(uintptr(0x4000), suite.take(0xd000))
+	_, ok = suite.list.Take(1)
+	suite.False(ok)
+}
+
+func (suite *SpansTestSuite) TestReuse() {
+	first := suite.take(0x1000)
+	second := suite.take(0x1000)
+	third := suite.take(0x1000)
+	suite.take(0x1000)
+
+	// the freed spans are coalesced and reused first
+	suite.list.Put(first, 0x1000)
+	suite.list.Put(third, 0x1000)
+	suite.list.Put(second, 0x1000)
+	suite.Equal(first, suite.take(0x3000))
+
+	// a span split by a smaller take keeps its rest
+	suite.list.Put(first, 0x3000)
+	suite.Equal(first, suite.take(0x1000))
+	suite.Equal(second, suite.take(0x2000))
+	suite.Equal(uintptr(0x4000), suite.list.Top())
+}
+
+func (suite *SpansTestSuite) TestTop() {
+	first := suite.take(0x1000)
+	second := suite.take(0x1000)
+
+	// spans freed at the top lower it
+	suite.list.Put(second, 0x1000)
+	suite.Equal(uintptr(0x1000), suite.list.Top())
+	suite.list.Put(first, 0x1000)
+	suite.Equal(uintptr(0), suite.list.Top())
+	suite.Equal(uintptr(0x10000), suite.list.Left())
+}
+
+func (suite *SpansTestSuite) TestZero() {
+	var list List
+	_, ok := list.Take(1)
+	suite.False(ok)
+}
+
+func TestSpansTestSuite(t *testing.T) {
+	suite.Run(t, new(SpansTestSuite))
+}
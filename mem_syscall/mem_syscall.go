@@ -4,8 +4,103 @@
 // It makes sure the correct calls are made for the correct system/OS.
 package memsyscall
 
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnsupported is returned by the operations the platform does not support.
+	// It wraps [errors.ErrUnsupported].
+	ErrUnsupported = fmt.Errorf("memsyscall: operation not supported on this platform: %w", errors.ErrUnsupported)
+)
+
 type Syscall interface {
 	Alloc(size uintptr) (addr uintptr, err error)
 	Free(addr uintptr, size uintptr) (err error)
 	PageSize() (size uintptr)
+	// Protect changes the access protection of the pages of a range.
+	Protect(addr, size uintptr, prot Prot) (err error)
+	// Advise advises the system about the usage of a range.
+	Advise(addr, size uintptr, advice Advice) (err error)
+	// Remap grows or shrinks a mapping, moving it if needed,
+	// and returns its new address. The content of the mapping is kept.
+	Remap(addr, oldSize, newSize uintptr) (newAddr uintptr, err error)
+	// Lock locks the pages of a range in memory, so that they are never swapped out.
+	Lock(addr, size uintptr) (err error)
+	Unlock(addr, size uintptr) (err error)
+	// Capabilities reports which of the operations above the platform supports.
+	// The unsupported ones return [ErrUnsupported].
+	Capabilities() Capabilities
+}
+
+// Capabilities is a set of the optional operations a [Syscall] supports.
+type Capabilities uint32
+
+const (
+	CapProtect Capabilities = 1 << iota
+	CapAdvise
+	CapRemap
+	CapLock
+	// CapHugePages is set when the syscall is a [Mapper] that supports huge pages.
+	CapHugePages
+)
+
+// Has reports whether every capability of other is in c.
+func (c Capabilities) Has(other Capabilities) bool {
+	return c&other == other
+}
+
+// Prot is the access protection of memory.
+type Prot int
+
+const (
+	ProtNone Prot = 0
+	ProtRead Prot = 1 << (iota - 1)
+	ProtWrite
+	ProtExec
+)
+
+// Advice is a hint to the system about how a range of memory is going to be used.
+type Advice int
+
+const (
+	// AdviceDontNeed releases the pages of the range right away.
+	// The range stays mapped and reads as zeroes the next time it is touched.
+	AdviceDontNeed Advice = iota
+	// AdviceFree lets the system reclaim the pages of the range lazily,
+	// whenever it is under memory pressure.
+	// The content of the range is undefined until it is written again.
+	AdviceFree
+)
+
+// MapFlags are options of a mapping that not every system supports.
+type MapFlags uint32
+
+const (
+	// MapHugeTLB backs the mapping with explicit huge pages (MAP_HUGETLB on linux).
+	MapHugeTLB MapFlags = 1 << iota
+	// MapHugePageAdvise asks the system to back the mapping
+	// with transparent huge pages (MADV_HUGEPAGE on linux).
+	MapHugePageAdvise
+	// MapPopulate prefaults the pages of the mapping (MAP_POPULATE on linux),
+	// so that touching them later does not page fault.
+	MapPopulate
+	// MapNoReserve does not reserve swap space for the mapping (MAP_NORESERVE on linux),
+	// for systems that rely on overcommit.
+	MapNoReserve
+	// MapLockOnFault locks the pages of the mapping in memory
+	// as soon as they are faulted in (mlock2 with MLOCK_ONFAULT on linux).
+	MapLockOnFault
+)
+
+// Mapper is implemented by the syscalls that can map memory with [MapFlags].
+type Mapper interface {
+	// Map maps size bytes applying flags, when the system supports them,
+	// and returns the flags that were actually applied.
+	// Flags that can not be applied are silently dropped.
+	Map(size uintptr, flags MapFlags) (addr uintptr, applied MapFlags, err error)
+	// HugePageSize returns the size of the huge pages of the system,
+	// or zero if huge pages are not supported.
+	HugePageSize() uintptr
 }
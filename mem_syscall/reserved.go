@@ -0,0 +1,267 @@
+package memsyscall
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/exapsy/goumem/mem_syscall/internal/spans"
+)
+
+var (
+	ErrReservationExhausted = fmt.Errorf("memsyscall: reserved address space exhausted: %w", ErrNoMemory)
+	ErrNotReserved          = fmt.Errorf("memsyscall: memory does not belong to the reserved range")
+)
+
+type (
+	// Reserved is a [Syscall] that reserves a single range of virtual memory up front,
+	// and commits its pages incrementally as they are allocated.
+	//
+	// Allocations are carved out of the range in address order,
+	// so consecutive allocations are contiguous,
+	// and whether an address belongs to the range, and to which allocation, is an arithmetic check.
+	Reserved struct {
+		system   Syscall
+		base     uintptr
+		size     uintptr
+		pageSize uintptr
+
+		mutex     sync.Mutex
+		spans     spans.List
+		committed uintptr
+		// starts holds, for every page below the top of spans, the address of the allocated range it belongs to,
+		// zero if it is free. It is indexed by the offset of the page divided by the page size.
+		starts []uintptr
+	}
+)
+
+// NewReserved reserves size bytes of address space, rounded up to the page size.
+// None of it is backed by memory until it is allocated.
+func NewReserved(size uintptr) (*Reserved, error) {
+	system := New()
+	pageSize := system.PageSize()
+	size = alignToPage(size, pageSize)
+
+	base, err := reserveMemory(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reserved{
+		system:   system,
+		base:     base,
+		size:     size,
+		pageSize: pageSize,
+		spans:    spans.New(base, size),
+	}, nil
+}
+
+// Alloc commits size bytes of the reserved range, rounded up to the page size.
+// It reuses freed ranges before growing into the rest of the reservation.
+func (r *Reserved) Alloc(size uintptr) (uintptr, error) {
+	if size == 0 {
+		return 0, fmt.Errorf("memsyscall: can not allocate zero bytes from the reservation: %w", ErrInvalid)
+	}
+	size = alignToPage(size, r.pageSize)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	addr, ok := r.spans.Take(size)
+	if !ok {
+		return 0, fmt.Errorf("%w: %d bytes requested, %d bytes left", ErrReservationExhausted, size, r.spans.Left())
+	}
+	if pages := r.spans.Top() / r.pageSize; pages > uintptr(len(r.starts)) {
+		r.starts = append(r.starts, make([]uintptr, pages-uintptr(len(r.starts)))...)
+	}
+
+	err := commitMemory(addr, size)
+	if err != nil {
+		r.putFree(addr, size)
+		return 0, err
+	}
+
+	r.committed += size
+	r.setStart(addr, size, addr)
+
+	return addr, nil
+}
+
+// Free decommits the range, which stays reserved for later allocations.
+// The range must be one returned by Alloc, with the size it was allocated with.
+func (r *Reserved) Free(addr uintptr, size uintptr) error {
+	size = alignToPage(size, r.pageSize)
+	if !r.Contains(addr) || !r.Contains(addr+size-1) {
+		return ErrNotReserved
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.isAllocated(addr, size) {
+		return fmt.Errorf("memsyscall: range %#x+%d was not allocated from the reservation: %w", addr, size, ErrInvalid)
+	}
+
+	err := decommitMemory(addr, size)
+	if err != nil {
+		return err
+	}
+
+	r.committed -= size
+	r.setStart(addr, size, 0)
+	r.putFree(addr, size)
+
+	return nil
+}
+
+func (r *Reserved) PageSize() uintptr {
+	return r.pageSize
+}
+
+func (r *Reserved) Protect(addr, size uintptr, prot Prot) error {
+	if err := r.checkCommitted(addr, size); err != nil {
+		return err
+	}
+
+	return r.system.Protect(addr, size, prot)
+}
+
+func (r *Reserved) Advise(addr, size uintptr, advice Advice) error {
+	if err := r.checkCommitted(addr, size); err != nil {
+		return err
+	}
+
+	return r.system.Advise(addr, size, advice)
+}
+
+// Remap is not supported, allocations can not move out of their place in the reserved range.
+func (r *Reserved) Remap(addr, oldSize, newSize uintptr) (uintptr, error) {
+	return 0, ErrUnsupported
+}
+
+func (r *Reserved) Lock(addr, size uintptr) error {
+	if err := r.checkCommitted(addr, size); err != nil {
+		return err
+	}
+
+	return r.system.Lock(addr, size)
+}
+
+func (r *Reserved) Unlock(addr, size uintptr) error {
+	if err := r.checkCommitted(addr, size); err != nil {
+		return err
+	}
+
+	return r.system.Unlock(addr, size)
+}
+
+func (r *Reserved) Capabilities() Capabilities {
+	return r.system.Capabilities() &^ (CapRemap | CapHugePages)
+}
+
+// Contains reports whether addr belongs to the reserved range.
+func (r *Reserved) Contains(addr uintptr) bool {
+	return addr >= r.base && addr-r.base < r.size
+}
+
+// checkCommitted returns an error if the range does not lie in a range allocated from the reservation.
+func (r *Reserved) checkCommitted(addr, size uintptr) error {
+	if !r.Contains(addr) || (size != 0 && !r.Contains(addr+size-1)) {
+		return ErrNotReserved
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	end := addr
+	if size != 0 {
+		end = addr + size - 1
+	}
+	if start := r.start(addr); start == 0 || r.start(end) != start {
+		return fmt.Errorf("memsyscall: range %#x+%d is not committed: %w", addr, size, ErrInvalid)
+	}
+
+	return nil
+}
+
+// start returns the address of the allocated range addr belongs to, zero if it is not allocated.
+func (r *Reserved) start(addr uintptr) uintptr {
+	page := (addr - r.base) / r.pageSize
+	if page >= uintptr(len(r.starts)) {
+		return 0
+	}
+
+	return r.starts[page]
+}
+
+// isAllocated reports whether addr+size is exactly a range allocated from the reservation.
+func (r *Reserved) isAllocated(addr, size uintptr) bool {
+	return r.start(addr) == addr && r.start(addr+size-1) == addr &&
+		(!r.Contains(addr+size) || r.start(addr+size) != addr)
+}
+
+// setStart records start as the allocated range of the pages from addr to addr+size.
+func (r *Reserved) setStart(addr, size, start uintptr) {
+	first := (addr - r.base) / r.pageSize
+	for page := first; page < first+size/r.pageSize; page++ {
+		r.starts[page] = start
+	}
+}
+
+// Offset returns the offset of addr from the start of the reserved range.
+func (r *Reserved) Offset(addr uintptr) (uintptr, bool) {
+	if !r.Contains(addr) {
+		return 0, false
+	}
+
+	return addr - r.base, true
+}
+
+// Base returns the first address of the reserved range.
+func (r *Reserved) Base() uintptr {
+	return r.base
+}
+
+// Size returns the size of the reserved range.
+func (r *Reserved) Size() uintptr {
+	return r.size
+}
+
+// Committed returns the amount of bytes of the range currently committed.
+func (r *Reserved) Committed() uintptr {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.committed
+}
+
+// Close releases the whole reserved range.
+// Every allocation made from it becomes invalid.
+func (r *Reserved) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	err := releaseMemory(r.base, r.size)
+	if err != nil {
+		return err
+	}
+
+	r.spans = spans.List{}
+	r.committed = 0
+	r.starts = nil
+
+	return nil
+}
+
+// putFree gives a range back to the spans, forgetting the pages that are not below their top anymore.
+func (r *Reserved) putFree(addr, size uintptr) {
+	r.spans.Put(addr, size)
+	r.starts = r.starts[:r.spans.Top()/r.pageSize]
+}
+
+func alignToPage(size, pageSize uintptr) uintptr {
+	if size%pageSize != 0 {
+		size += pageSize - size%pageSize // align to next page
+	}
+
+	return size
+}
@@ -0,0 +1,148 @@
+package memsyscall
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"unsafe"
+)
+
+type ReservedTestSuite struct {
+	suite.Suite
+	reserved *Reserved
+	pageSize uintptr
+}
+
+func (suite *ReservedTestSuite) SetupTest() {
+	var err error
+	suite.reserved, err = NewReserved(1 << 20)
+	if err != nil {
+		suite.FailNow("Failed to reserve memory", err)
+	}
+
+	suite.pageSize = suite.reserved.PageSize()
+}
+
+func (suite *ReservedTestSuite) TearDownTest() {
+	suite.NoError(suite.reserved.Close())
+}
+
+func (suite *ReservedTestSuite) TestContiguous() {
+	first, err := suite.reserved.Alloc(suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	second, err := suite.reserved.Alloc(2 * suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	suite.Equal(suite.reserved.Base(), first)
+	suite.Equal(first+suite.pageSize, second)
+	suite.Equal(3*suite.pageSize, suite.reserved.Committed())
+
+	// committed memory is writable
+	*(*int)(unsafe.Pointer(second)) = 42
+	suite.Equal(42, *(*int)(unsafe.Pointer(second)))
+
+	offset, ok := suite.reserved.Offset(second)
+	suite.True(ok)
+	suite.Equal(suite.pageSize, offset)
+	suite.False(suite.reserved.Contains(suite.reserved.Base() + suite.reserved.Size()))
+}
+
+func (suite *ReservedTestSuite) TestReuse() {
+	first, err := suite.reserved.Alloc(suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	second, err := suite.reserved.Alloc(suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	suite.NoError(suite.reserved.Free(first, suite.pageSize))
+	suite.Equal(suite.pageSize, suite.reserved.Committed())
+
+	// the freed range is reused
+	reused, err := suite.reserved.Alloc(suite.pageSize)
+	suite.NoError(err)
+	suite.Equal(first, reused)
+
+	suite.NoError(suite.reserved.Free(reused, suite.pageSize))
+	suite.NoError(suite.reserved.Free(second, suite.pageSize))
+	suite.Equal(uintptr(0), suite.reserved.Committed())
+
+	// freeing everything gives the whole range back
+	all, err := suite.reserved.Alloc(suite.reserved.Size())
+	suite.NoError(err)
+	suite.Equal(suite.reserved.Base(), all)
+}
+
+func (suite *ReservedTestSuite) TestExhausted() {
+	_, err := suite.reserved.Alloc(suite.reserved.Size() + 1)
+	suite.ErrorIs(err, ErrReservationExhausted)
+
+	suite.ErrorIs(suite.reserved.Free(suite.reserved.Base()-suite.pageSize, suite.pageSize), ErrNotReserved)
+}
+
+func (suite *ReservedTestSuite) TestInvalidFree() {
+	addr, err := suite.reserved.Alloc(2 * suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	// ranges that were not allocated, or not with that size, are refused
+	suite.ErrorIs(suite.reserved.Free(addr, suite.pageSize), ErrInvalid)
+	suite.ErrorIs(suite.reserved.Free(addr+suite.pageSize, suite.pageSize), ErrInvalid)
+	suite.ErrorIs(suite.reserved.Free(addr+2*suite.pageSize, suite.pageSize), ErrInvalid)
+	suite.Equal(2*suite.pageSize, suite.reserved.Committed())
+
+	suite.NoError(suite.reserved.Free(addr, 2*suite.pageSize))
+	suite.ErrorIs(suite.reserved.Free(addr, 2*suite.pageSize), ErrInvalid)
+
+	// the free list was not corrupted by the refused frees
+	all, err := suite.reserved.Alloc(suite.reserved.Size())
+	suite.NoError(err)
+	suite.Equal(suite.reserved.Base(), all)
+}
+
+func (suite *ReservedTestSuite) TestZeroSize() {
+	_, err := suite.reserved.Alloc(0)
+	suite.ErrorIs(err, ErrInvalid)
+	suite.Equal(uintptr(0), suite.reserved.Committed())
+}
+
+func (suite *ReservedTestSuite) TestUncommittedRange() {
+	addr, err := suite.reserved.Alloc(suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	if suite.reserved.Capabilities().Has(CapAdvise) {
+		suite.NoError(suite.reserved.Advise(addr, suite.pageSize, AdviceDontNeed))
+	}
+
+	// ranges running past the committed range are refused
+	suite.ErrorIs(suite.reserved.Protect(addr, 2*suite.pageSize, ProtRead|ProtWrite), ErrInvalid)
+	suite.ErrorIs(suite.reserved.Advise(addr, 2*suite.pageSize, AdviceDontNeed), ErrInvalid)
+	suite.ErrorIs(suite.reserved.Lock(addr, 2*suite.pageSize), ErrInvalid)
+	suite.ErrorIs(suite.reserved.Protect(addr, suite.reserved.Size()+suite.pageSize, ProtRead|ProtWrite), ErrNotReserved)
+
+	// so are ranges spanning two allocations, even adjacent ones
+	next, err := suite.reserved.Alloc(suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+	suite.Equal(addr+suite.pageSize, next)
+	suite.ErrorIs(suite.reserved.Protect(addr, 2*suite.pageSize, ProtRead|ProtWrite), ErrInvalid)
+
+	// and freed ranges
+	suite.NoError(suite.reserved.Free(next, suite.pageSize))
+	suite.ErrorIs(suite.reserved.Protect(next, suite.pageSize, ProtRead|ProtWrite), ErrInvalid)
+}
+
+func TestReservedTestSuite(t *testing.T) {
+	suite.Run(t, new(ReservedTestSuite))
+}
@@ -0,0 +1,140 @@
+package memsyscall
+
+import (
+	"github.com/stretchr/testify/suite"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// platforms are the platforms the package must build for,
+// the ones with a system backend and a few that get the heap fallback.
+var platforms = []string{
+	"aix/ppc64",
+	"darwin/arm64",
+	"dragonfly/amd64",
+	"freebsd/amd64",
+	"illumos/amd64",
+	"js/wasm",
+	"linux/amd64",
+	"linux/arm64",
+	"netbsd/amd64",
+	"openbsd/amd64",
+	"plan9/amd64",
+	"solaris/amd64",
+	"wasip1/wasm",
+	"windows/amd64",
+}
+
+type SyscallTestSuite struct {
+	suite.Suite
+	sys      Syscall
+	pageSize uintptr
+}
+
+func (suite *SyscallTestSuite) SetupTest() {
+	suite.sys = New()
+	suite.pageSize = suite.sys.PageSize()
+}
+
+func (suite *SyscallTestSuite) TestProtect() {
+	if !suite.sys.Capabilities().Has(CapProtect) {
+		suite.T().Skip("protect is not supported")
+	}
+
+	addr, err := suite.sys.Alloc(suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+	defer suite.sys.Free(addr, suite.pageSize)
+
+	*(*int)(unsafe.Pointer(addr)) = 42
+
+	suite.NoError(suite.sys.Protect(addr, suite.pageSize, ProtRead))
+	suite.Equal(42, *(*int)(unsafe.Pointer(addr)))
+	suite.NoError(suite.sys.Protect(addr, suite.pageSize, ProtRead|ProtWrite))
+}
+
+func (suite *SyscallTestSuite) TestAdvise() {
+	addr, err := suite.sys.Alloc(suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+	defer suite.sys.Free(addr, suite.pageSize)
+
+	*(*int)(unsafe.Pointer(addr)) = 42
+
+	err = suite.sys.Advise(addr, suite.pageSize, AdviceDontNeed)
+	if !suite.sys.Capabilities().Has(CapAdvise) {
+		suite.ErrorIs(err, ErrUnsupported)
+		return
+	}
+
+	suite.NoError(err)
+	// the range is still mapped
+	*(*int)(unsafe.Pointer(addr)) = 43
+	suite.Equal(43, *(*int)(unsafe.Pointer(addr)))
+}
+
+func (suite *SyscallTestSuite) TestRemap() {
+	addr, err := suite.sys.Alloc(suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+
+	*(*int)(unsafe.Pointer(addr)) = 42
+
+	newAddr, err := suite.sys.Remap(addr, suite.pageSize, 4*suite.pageSize)
+	if !suite.sys.Capabilities().Has(CapRemap) {
+		suite.ErrorIs(err, ErrUnsupported)
+		suite.NoError(suite.sys.Free(addr, suite.pageSize))
+		return
+	}
+
+	suite.NoError(err)
+	suite.Equal(42, *(*int)(unsafe.Pointer(newAddr)))
+	*(*int)(unsafe.Pointer(newAddr + 3*suite.pageSize)) = 43
+	suite.NoError(suite.sys.Free(newAddr, 4*suite.pageSize))
+}
+
+func (suite *SyscallTestSuite) TestLock() {
+	addr, err := suite.sys.Alloc(suite.pageSize)
+	if err != nil {
+		suite.FailNow("Failed to allocate memory", err)
+	}
+	defer suite.sys.Free(addr, suite.pageSize)
+
+	err = suite.sys.Lock(addr, suite.pageSize)
+	if !suite.sys.Capabilities().Has(CapLock) {
+		suite.ErrorIs(err, ErrUnsupported)
+		return
+	}
+
+	suite.NoError(err)
+	suite.NoError(suite.sys.Unlock(addr, suite.pageSize))
+}
+
+func (suite *SyscallTestSuite) TestCrossBuild() {
+	if testing.Short() {
+		suite.T().Skip("cross-building takes a while")
+	}
+
+	goCommand, err := exec.LookPath("go")
+	if err != nil {
+		suite.T().Skip("the go command is not available")
+	}
+
+	for _, platform := range platforms {
+		goos, goarch, _ := strings.Cut(platform, "/")
+		cmd := exec.Command(goCommand, "build", ".")
+		cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH="+goarch, "CGO_ENABLED=0")
+		out, err := cmd.CombinedOutput()
+		suite.NoError(err, "%s: %s", platform, out)
+	}
+}
+
+func TestSyscallTestSuite(t *testing.T) {
+	suite.Run(t, new(SyscallTestSuite))
+}
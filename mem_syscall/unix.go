@@ -1,10 +1,12 @@
-//go:build darwin || dragonfly || freebsd || linux || nacl || netbsd || openbsd || solaris
+//go:build (darwin || dragonfly || freebsd || linux || nacl || netbsd || openbsd) && !goumem_heap
 
 package memsyscall
 
 import (
 	"fmt"
 	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
 type unixSyscall struct{}
@@ -14,12 +16,68 @@ func New() *unixSyscall {
 }
 
 func (u *unixSyscall) Alloc(size uintptr) (uintptr, error) {
+	return u.mmap(size, 0)
+}
+
+// Map maps size bytes like [unixSyscall.Alloc], applying as many of flags as the system supports.
+//
+// [MapHugeTLB] is only applied if size is a multiple of the huge page size.
+// If no huge pages are reserved, it falls back to [MapHugePageAdvise].
+func (u *unixSyscall) Map(size uintptr, flags MapFlags) (uintptr, MapFlags, error) {
+	extraFlags, applied := mmapFlags(flags)
+
+	if flags&MapHugeTLB != 0 {
+		if huge := hugePageSize(); huge != 0 && size%huge == 0 {
+			mem, err := u.mmap(size, extraFlags|mmapHugeTLB)
+			if err == nil {
+				return mem, u.lock(mem, size, flags, applied|MapHugeTLB), nil
+			}
+
+			// no huge pages reserved, fall back to transparent huge pages
+			flags |= MapHugePageAdvise
+		}
+	}
+
+	mem, err := u.mmap(size, extraFlags)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if flags&MapHugePageAdvise != 0 && hugePageSize() != 0 {
+		_, _, errno := syscall.Syscall(
+			syscall.SYS_MADVISE,
+			mem,
+			size,
+			madvHugePage,
+		)
+		if errno == 0 {
+			applied |= MapHugePageAdvise
+		}
+	}
+
+	return mem, u.lock(mem, size, flags, applied), nil
+}
+
+// lock applies [MapLockOnFault] to a new mapping, if requested and supported.
+func (u *unixSyscall) lock(addr, size uintptr, flags, applied MapFlags) MapFlags {
+	if flags&MapLockOnFault != 0 && lockOnFault(addr, size) == nil {
+		applied |= MapLockOnFault
+	}
+
+	return applied
+}
+
+func (u *unixSyscall) HugePageSize() uintptr {
+	return hugePageSize()
+}
+
+func (u *unixSyscall) mmap(size uintptr, flags uintptr) (uintptr, error) {
 	mem, _, errno := syscall.Syscall6(
 		syscall.SYS_MMAP,
 		0,
 		size,
 		syscall.PROT_READ|syscall.PROT_WRITE,
-		syscall.MAP_ANONYMOUS|syscall.MAP_PRIVATE,
+		syscall.MAP_ANON|syscall.MAP_PRIVATE|flags,
 		0,
 		0,
 	)
@@ -52,3 +110,154 @@ func (u *unixSyscall) Free(addr uintptr, size uintptr) error {
 func (u *unixSyscall) PageSize() uintptr {
 	return uintptr(syscall.Getpagesize())
 }
+
+func (u *unixSyscall) Protect(addr, size uintptr, prot Prot) error {
+	var flags uintptr = syscall.PROT_NONE
+	if prot&ProtRead != 0 {
+		flags |= syscall.PROT_READ
+	}
+	if prot&ProtWrite != 0 {
+		flags |= syscall.PROT_WRITE
+	}
+	if prot&ProtExec != 0 {
+		flags |= syscall.PROT_EXEC
+	}
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_MPROTECT,
+		addr,
+		size,
+		flags,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to MPROTECT memory: %w", errno)
+	}
+
+	return nil
+}
+
+func (u *unixSyscall) Remap(addr, oldSize, newSize uintptr) (uintptr, error) {
+	return remap(addr, oldSize, newSize)
+}
+
+func (u *unixSyscall) Lock(addr, size uintptr) error {
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_MLOCK,
+		addr,
+		size,
+		0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to MLOCK memory: %w", errno)
+	}
+
+	return nil
+}
+
+func (u *unixSyscall) Unlock(addr, size uintptr) error {
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_MUNLOCK,
+		addr,
+		size,
+		0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to MUNLOCK memory: %w", errno)
+	}
+
+	return nil
+}
+
+func (u *unixSyscall) Capabilities() Capabilities {
+	capabilities := CapProtect | CapAdvise | CapLock | platformCapabilities
+	if hugePageSize() != 0 {
+		capabilities |= CapHugePages
+	}
+
+	return capabilities
+}
+
+func (u *unixSyscall) Advise(addr, size uintptr, advice Advice) error {
+	var flag uintptr
+	switch advice {
+	case AdviceDontNeed:
+		flag = unix.MADV_DONTNEED
+	case AdviceFree:
+		flag = unix.MADV_FREE
+	default:
+		return fmt.Errorf("unknown advice %d: %w", advice, syscall.EINVAL)
+	}
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_MADVISE,
+		addr,
+		size,
+		flag,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to MADVISE memory: %w", errno)
+	}
+
+	return nil
+}
+
+// reserveMemory maps size bytes of address space that can not be accessed,
+// and are not backed by any memory until they are committed.
+func reserveMemory(size uintptr) (uintptr, error) {
+	mem, _, errno := syscall.Syscall6(
+		syscall.SYS_MMAP,
+		0,
+		size,
+		syscall.PROT_NONE,
+		syscall.MAP_ANON|syscall.MAP_PRIVATE,
+		0,
+		0,
+	)
+	if errno != 0 {
+		return 0, fmt.Errorf("failed to reserve memory with MMAP: %w", errno)
+	}
+
+	return mem, nil
+}
+
+func commitMemory(addr, size uintptr) error {
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_MPROTECT,
+		addr,
+		size,
+		syscall.PROT_READ|syscall.PROT_WRITE,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to commit memory with MPROTECT: %w", errno)
+	}
+
+	return nil
+}
+
+func decommitMemory(addr, size uintptr) error {
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_MADVISE,
+		addr,
+		size,
+		unix.MADV_DONTNEED,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to decommit memory with MADVISE: %w", errno)
+	}
+
+	_, _, errno = syscall.Syscall(
+		syscall.SYS_MPROTECT,
+		addr,
+		size,
+		syscall.PROT_NONE,
+	)
+	if errno != 0 {
+		return fmt.Errorf("failed to decommit memory with MPROTECT: %w", errno)
+	}
+
+	return nil
+}
+
+func releaseMemory(addr, size uintptr) error {
+	return New().Free(addr, size)
+}
@@ -0,0 +1,103 @@
+//go:build linux && !goumem_heap
+
+package memsyscall
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	mmapHugeTLB  = unix.MAP_HUGETLB
+	madvHugePage = unix.MADV_HUGEPAGE
+	mlockOnFault = 0x1 // MLOCK_ONFAULT
+
+	platformCapabilities = CapRemap
+)
+
+func remap(addr, oldSize, newSize uintptr) (uintptr, error) {
+	mem, _, errno := syscall.Syscall6(
+		syscall.SYS_MREMAP,
+		addr,
+		oldSize,
+		newSize,
+		unix.MREMAP_MAYMOVE,
+		0,
+		0,
+	)
+	if errno != 0 {
+		return 0, fmt.Errorf("failed to MREMAP memory: %w", errno)
+	}
+
+	return mem, nil
+}
+
+// mmapFlags translates the flags that are applied by MMAP itself.
+func mmapFlags(flags MapFlags) (uintptr, MapFlags) {
+	var mmapFlags uintptr
+	var applied MapFlags
+
+	if flags&MapPopulate != 0 {
+		mmapFlags |= unix.MAP_POPULATE
+		applied |= MapPopulate
+	}
+
+	if flags&MapNoReserve != 0 {
+		mmapFlags |= unix.MAP_NORESERVE
+		applied |= MapNoReserve
+	}
+
+	return mmapFlags, applied
+}
+
+func lockOnFault(addr, size uintptr) error {
+	_, _, errno := syscall.Syscall(
+		unix.SYS_MLOCK2,
+		addr,
+		size,
+		mlockOnFault,
+	)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+var hugePageSize = sync.OnceValue(func() uintptr {
+	// size of the transparent huge pages
+	b, err := os.ReadFile("/sys/kernel/mm/transparent_hugepage/hpage_pmd_size")
+	if err == nil {
+		size, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+		if err == nil {
+			return uintptr(size)
+		}
+	}
+
+	// default size of the hugetlbfs pages
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "Hugepagesize:" && fields[2] == "kB" {
+			size, err := strconv.ParseUint(fields[1], 10, 64)
+			if err == nil {
+				return uintptr(size) << 10
+			}
+		}
+	}
+
+	return 0
+})
@@ -0,0 +1,30 @@
+//go:build (darwin || dragonfly || freebsd || nacl || netbsd || openbsd) && !goumem_heap
+
+package memsyscall
+
+import "syscall"
+
+// Huge pages, prefaulting, no-reserve and lock-on-fault mappings,
+// and remapping are only supported on linux.
+const (
+	mmapHugeTLB  = 0
+	madvHugePage = 0
+
+	platformCapabilities Capabilities = 0
+)
+
+func remap(addr, oldSize, newSize uintptr) (uintptr, error) {
+	return 0, ErrUnsupported
+}
+
+func hugePageSize() uintptr {
+	return 0
+}
+
+func mmapFlags(flags MapFlags) (uintptr, MapFlags) {
+	return 0, 0
+}
+
+func lockOnFault(addr, size uintptr) error {
+	return syscall.ENOSYS
+}
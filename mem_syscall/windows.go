@@ -1,9 +1,13 @@
-//go:build windows
+//go:build windows && !goumem_heap
 
 package memsyscall
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
 
 	"golang.org/x/sys/windows"
 )
@@ -25,7 +29,7 @@ func (w *windowsSyscall) Alloc(size uintptr) (uintptr, error) {
 		uintptr(0x04),   // PAGE_READWRITE
 	)
 	if r1 == 0 {
-		return 0, fmt.Errorf("failed to make VirtualAlloc allocator: %w", err)
+		return 0, fmt.Errorf("failed to make VirtualAlloc allocator: %w", systemError(err))
 	}
 
 	return r1, nil
@@ -41,14 +45,196 @@ func (w *windowsSyscall) Free(addr, size uintptr) error {
 		uintptr(0x8000), // MEM_RELEASE
 	)
 	if r1 == 0 {
-		return fmt.Errorf("failed to make VirtualFree allocator: %w", err)
+		return fmt.Errorf("failed to make VirtualFree allocator: %w", systemError(err))
 	}
 
 	return nil
 }
 
 func (w *windowsSyscall) PageSize() uintptr {
-	var info windows.SYSTEM_INFO
-	windows.GetSystemInfo(&info)
-	return uintptr(info.PageSize)
+	return uintptr(os.Getpagesize())
+}
+
+func (w *windowsSyscall) Advise(addr, size uintptr, advice Advice) error {
+	kernel32 := windows.NewLazySystemDLL("kernel32.dll")
+
+	switch advice {
+	case AdviceDontNeed:
+		// decommit and commit the range again, so that it reads as zeroes
+		virtualFree := kernel32.NewProc("VirtualFree")
+		r1, _, err := virtualFree.Call(
+			addr,
+			size,
+			uintptr(0x4000), // MEM_DECOMMIT
+		)
+		if r1 == 0 {
+			return fmt.Errorf("failed to decommit memory with VirtualFree: %w", systemError(err))
+		}
+
+		virtualAlloc := kernel32.NewProc("VirtualAlloc")
+		r1, _, err = virtualAlloc.Call(
+			addr,
+			size,
+			uintptr(0x1000), // MEM_COMMIT
+			uintptr(0x04),   // PAGE_READWRITE
+		)
+		if r1 == 0 {
+			return fmt.Errorf("failed to commit memory with VirtualAlloc: %w", systemError(err))
+		}
+	case AdviceFree:
+		virtualAlloc := kernel32.NewProc("VirtualAlloc")
+		r1, _, err := virtualAlloc.Call(
+			addr,
+			size,
+			uintptr(0x80000), // MEM_RESET
+			uintptr(0x04),    // PAGE_READWRITE
+		)
+		if r1 == 0 {
+			return fmt.Errorf("failed to reset memory with VirtualAlloc: %w", systemError(err))
+		}
+	default:
+		return fmt.Errorf("unknown advice %d: %w", advice, syscall.EINVAL)
+	}
+
+	return nil
+}
+
+func (w *windowsSyscall) Protect(addr, size uintptr, prot Prot) error {
+	kernel32 := windows.NewLazySystemDLL("kernel32.dll")
+	virtualProtect := kernel32.NewProc("VirtualProtect")
+
+	var flags uintptr
+	switch {
+	case prot&ProtExec != 0 && prot&ProtWrite != 0:
+		flags = 0x40 // PAGE_EXECUTE_READWRITE
+	case prot&ProtExec != 0 && prot&ProtRead != 0:
+		flags = 0x20 // PAGE_EXECUTE_READ
+	case prot&ProtExec != 0:
+		flags = 0x10 // PAGE_EXECUTE
+	case prot&ProtWrite != 0:
+		flags = 0x04 // PAGE_READWRITE
+	case prot&ProtRead != 0:
+		flags = 0x02 // PAGE_READONLY
+	default:
+		flags = 0x01 // PAGE_NOACCESS
+	}
+
+	var oldProtect uint32
+	r1, _, err := virtualProtect.Call(
+		addr,
+		size,
+		flags,
+		uintptr(unsafe.Pointer(&oldProtect)),
+	)
+	if r1 == 0 {
+		return fmt.Errorf("failed to protect memory with VirtualProtect: %w", systemError(err))
+	}
+
+	return nil
+}
+
+func (w *windowsSyscall) Remap(addr, oldSize, newSize uintptr) (uintptr, error) {
+	return 0, ErrUnsupported
+}
+
+func (w *windowsSyscall) Lock(addr, size uintptr) error {
+	kernel32 := windows.NewLazySystemDLL("kernel32.dll")
+	virtualLock := kernel32.NewProc("VirtualLock")
+
+	r1, _, err := virtualLock.Call(addr, size)
+	if r1 == 0 {
+		return fmt.Errorf("failed to lock memory with VirtualLock: %w", systemError(err))
+	}
+
+	return nil
+}
+
+func (w *windowsSyscall) Unlock(addr, size uintptr) error {
+	kernel32 := windows.NewLazySystemDLL("kernel32.dll")
+	virtualUnlock := kernel32.NewProc("VirtualUnlock")
+
+	r1, _, err := virtualUnlock.Call(addr, size)
+	if r1 == 0 {
+		return fmt.Errorf("failed to unlock memory with VirtualUnlock: %w", systemError(err))
+	}
+
+	return nil
+}
+
+func (w *windowsSyscall) Capabilities() Capabilities {
+	return CapProtect | CapAdvise | CapLock
+}
+
+// reserveMemory reserves size bytes of address space that can not be accessed,
+// and are not backed by any memory until they are committed.
+func reserveMemory(size uintptr) (uintptr, error) {
+	kernel32 := windows.NewLazySystemDLL("kernel32.dll")
+	virtualAlloc := kernel32.NewProc("VirtualAlloc")
+
+	r1, _, err := virtualAlloc.Call(
+		0,
+		size,
+		uintptr(0x2000), // MEM_RESERVE
+		uintptr(0x01),   // PAGE_NOACCESS
+	)
+	if r1 == 0 {
+		return 0, fmt.Errorf("failed to reserve memory with VirtualAlloc: %w", systemError(err))
+	}
+
+	return r1, nil
+}
+
+func commitMemory(addr, size uintptr) error {
+	kernel32 := windows.NewLazySystemDLL("kernel32.dll")
+	virtualAlloc := kernel32.NewProc("VirtualAlloc")
+
+	r1, _, err := virtualAlloc.Call(
+		addr,
+		size,
+		uintptr(0x1000), // MEM_COMMIT
+		uintptr(0x04),   // PAGE_READWRITE
+	)
+	if r1 == 0 {
+		return fmt.Errorf("failed to commit memory with VirtualAlloc: %w", systemError(err))
+	}
+
+	return nil
+}
+
+func decommitMemory(addr, size uintptr) error {
+	kernel32 := windows.NewLazySystemDLL("kernel32.dll")
+	virtualFree := kernel32.NewProc("VirtualFree")
+
+	r1, _, err := virtualFree.Call(
+		addr,
+		size,
+		uintptr(0x4000), // MEM_DECOMMIT
+	)
+	if r1 == 0 {
+		return fmt.Errorf("failed to decommit memory with VirtualFree: %w", systemError(err))
+	}
+
+	return nil
+}
+
+func releaseMemory(addr, size uintptr) error {
+	return New().Free(addr, size)
+}
+
+// systemError wraps err, returned by a call of the system, with the errno it amounts to,
+// so that out of memory and invalid argument errors match [syscall.ENOMEM] and [syscall.EINVAL] like on unix.
+func systemError(err error) error {
+	var errno windows.Errno
+	if !errors.As(err, &errno) {
+		return err
+	}
+
+	switch errno {
+	case windows.ERROR_NOT_ENOUGH_MEMORY, windows.ERROR_OUTOFMEMORY, windows.ERROR_COMMITMENT_LIMIT:
+		return fmt.Errorf("%w: %w", syscall.ENOMEM, err)
+	case windows.ERROR_INVALID_PARAMETER, windows.ERROR_INVALID_ADDRESS:
+		return fmt.Errorf("%w: %w", syscall.EINVAL, err)
+	}
+
+	return err
 }
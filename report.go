@@ -0,0 +1,232 @@
+package goumem
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exapsy/goumem/allocator"
+)
+
+// maxReportSites is the count of allocation sites listed by [WriteReport].
+const maxReportSites = 10
+
+// NotifyOnSignal writes a report of the global allocator to w, like [WriteReport],
+// every time the process receives sig, typically SIGUSR1, without stopping it.
+// It returns a function that stops listening to sig,
+// once the report being written, if any, is done. It can be called more than once.
+func NotifyOnSignal(sig os.Signal, w io.Writer) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	signal.Notify(signals, sig)
+
+	go func() {
+		defer close(stopped)
+
+		for {
+			select {
+			case <-signals:
+				// a report that can not be written is dropped, w is the caller's to watch
+				_ = WriteReport(w)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+		<-stopped
+	}
+}
+
+// WriteReport writes a report of the global allocator to w:
+// its statistics, a summary of the fragmentation of its heap,
+// its top allocation sites if GOUMEMDEBUG has leakcheck=1, and the result of verifying it.
+// Each section is only written if the allocator supports it.
+// The checkers enabled with GOUMEMDEBUG are looked through,
+// unless [SetMemoryAllocator] replaced them since.
+func WriteReport(w io.Writer) error {
+	current := mem
+
+	var base allocator.MemoryAllocator
+	var verifier allocator.Verifier
+	var leaks *allocator.LeakChecker
+	debug.mutex.Lock()
+	if debug.top != nil && current == debug.top {
+		base, verifier, leaks = debug.base, debug.verifier, debug.leaks
+	}
+	debug.mutex.Unlock()
+
+	if l, ok := current.(*allocator.LeakChecker); ok {
+		leaks = l
+	}
+
+	r := reportWriter{w: w}
+	r.printf("goumem report at %s\n", time.Now().Format(time.RFC3339))
+
+	if stats, ok := allocator.ReadStats(current); ok {
+		r.stats(stats)
+	}
+
+	walker, ok := current.(allocator.HeapWalker)
+	if !ok {
+		walker, ok = base.(allocator.HeapWalker)
+	}
+	if ok {
+		r.fragmentation(walker)
+	}
+
+	if leaks != nil {
+		r.sites(leaks.Leaks())
+	}
+
+	if v, ok := current.(allocator.Verifier); ok {
+		verifier = v
+	}
+	if verifier != nil {
+		r.verify(verifier)
+	}
+
+	return r.err
+}
+
+// reportWriter writes the sections of a report, keeping the first error.
+type reportWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (r *reportWriter) printf(format string, args ...interface{}) {
+	if r.err == nil {
+		_, r.err = fmt.Fprintf(r.w, format, args...)
+	}
+}
+
+func (r *reportWriter) stats(stats allocator.Stats) {
+	r.printf("\nstats:\n")
+	r.printf("\tmapped      %d bytes in %d chunks\n", stats.MappedBytes, stats.Chunks)
+	r.printf("\tcommitted   %d bytes\n", stats.CommittedBytes)
+	r.printf("\tlive        %d bytes in %d blocks\n", stats.LiveBytes, stats.LiveBlocks)
+	r.printf("\tallocs      %d, %d bytes\n", stats.Allocs, stats.AllocatedBytes)
+	r.printf("\tfrees       %d\n", stats.Frees)
+	r.printf("\tscavenged   %d bytes\n", stats.ScavengedBytes)
+	r.printf("\thard limit  %d bytes, %d failures\n", stats.HardLimit, stats.LimitFailures)
+	if l := stats.GCLimit; l != nil {
+		r.printf("\tgc limit    %d bytes of %d, %d off heap, %d adjustments", l.Limit, l.Budget, l.OffHeapBytes, l.Adjustments)
+		if l.Clamped {
+			r.printf(", clamped to the minimum")
+		}
+		r.printf("\n")
+	}
+
+	if len(stats.Tags) > 0 {
+		tags := make([]allocator.Tag, 0, len(stats.Tags))
+		for tag := range stats.Tags {
+			tags = append(tags, tag)
+		}
+		sort.Slice(tags, func(i, j int) bool {
+			return tags[i] < tags[j]
+		})
+
+		r.printf("\ntags:\n")
+		for _, tag := range tags {
+			t := stats.Tags[tag]
+			r.printf("\t%-16s live %d bytes in %d blocks, %d allocs\n", tag, t.LiveBytes, t.LiveBlocks, t.Allocs)
+		}
+	}
+
+	if h := stats.Histograms; h != nil {
+		r.printf("\nhistograms:              p50          p99\n")
+		r.printf("\tsize        %12d %12d bytes\n", h.Size.Quantile(0.5), h.Size.Quantile(0.99))
+		r.printf("\talloc       %12v %12v\n", time.Duration(h.AllocLatency.Quantile(0.5)), time.Duration(h.AllocLatency.Quantile(0.99)))
+		r.printf("\tfree        %12v %12v\n", time.Duration(h.FreeLatency.Quantile(0.5)), time.Duration(h.FreeLatency.Quantile(0.99)))
+		r.printf("\tlifetime    %12v %12v\n", time.Duration(h.Lifetime.Quantile(0.5)), time.Duration(h.Lifetime.Quantile(0.99)))
+	}
+}
+
+func (r *reportWriter) fragmentation(walker allocator.HeapWalker) {
+	var used, free, largest uintptr
+	var usedBlocks, freeBlocks int
+	walker.WalkHeap(func(block allocator.HeapBlock) bool {
+		if !block.Free {
+			used += block.Size
+			usedBlocks++
+			return true
+		}
+
+		free += block.Size
+		freeBlocks++
+		if block.Size > largest {
+			largest = block.Size
+		}
+		return true
+	})
+
+	r.printf("\nfragmentation:\n")
+	r.printf("\tused        %d bytes in %d blocks\n", used, usedBlocks)
+	r.printf("\tfree        %d bytes in %d blocks, the largest of %d bytes\n", free, freeBlocks, largest)
+	if free > 0 {
+		// the share of the free memory that can not be handed out in a single block
+		r.printf("\texternal    %.1f%%\n", 100*(1-float64(largest)/float64(free)))
+	}
+}
+
+func (r *reportWriter) sites(leaks []allocator.Leak) {
+	type site struct {
+		name   string
+		blocks int
+		bytes  uintptr
+	}
+
+	bySite := map[string]*site{}
+	for _, leak := range leaks {
+		name := leak.Site()
+		s, ok := bySite[name]
+		if !ok {
+			s = &site{name: name}
+			bySite[name] = s
+		}
+		s.blocks++
+		s.bytes += leak.Size
+	}
+
+	sites := make([]*site, 0, len(bySite))
+	for _, s := range bySite {
+		sites = append(sites, s)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].bytes != sites[j].bytes {
+			return sites[i].bytes > sites[j].bytes
+		}
+		return sites[i].name < sites[j].name
+	})
+	if len(sites) > maxReportSites {
+		sites = sites[:maxReportSites]
+	}
+
+	r.printf("\ntop allocation sites:\n")
+	for _, s := range sites {
+		r.printf("\t%10d bytes in %6d blocks  %s\n", s.bytes, s.blocks, s.name)
+	}
+}
+
+func (r *reportWriter) verify(verifier allocator.Verifier) {
+	r.printf("\nverify:\n")
+	if err := verifier.Verify(); err != nil {
+		r.printf("\t%s\n", strings.ReplaceAll(err.Error(), "\n", "\n\t"))
+		return
+	}
+
+	r.printf("\tok\n")
+}
@@ -0,0 +1,86 @@
+package goumem
+
+import (
+	"bytes"
+	"github.com/exapsy/goumem/allocator"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"unsafe"
+)
+
+type ReportTestSuite struct {
+	suite.Suite
+	mem allocator.MemoryAllocator
+}
+
+func (s *ReportTestSuite) SetupTest() {
+	s.mem = mem
+	mem = debug.wrap(allocator.Default(), debugConfig{canary: true, leakcheck: true}, &bytes.Buffer{})
+}
+
+func (s *ReportTestSuite) TearDownTest() {
+	mem = s.mem
+
+	debug.mutex.Lock()
+	debug.base, debug.verifier, debug.leaks, debug.recorder, debug.top = nil, nil, nil, nil, nil
+	debug.mutex.Unlock()
+}
+
+func (s *ReportTestSuite) TestWriteReport() {
+	first, err := mem.Alloc(100)
+	if err != nil {
+		s.FailNow("Failed to allocate block", err)
+	}
+	second, err := mem.Alloc(100)
+	if err != nil {
+		s.FailNow("Failed to allocate block", err)
+	}
+	s.NoError(mem.Free(first))
+
+	var report bytes.Buffer
+	s.NoError(WriteReport(&report))
+
+	s.Contains(report.String(), "stats:\n\tmapped")
+	s.Contains(report.String(), "live        132 bytes in 1 blocks")
+	s.Contains(report.String(), "fragmentation:\n\tused        144 bytes in 1 blocks")
+	s.Contains(report.String(), "top allocation sites:\n\t       100 bytes in      1 blocks  github.com/exapsy/goumem.(*ReportTestSuite).TestWriteReport")
+	s.Contains(report.String(), "verify:\n\tok")
+
+	// overflow the live block
+	*(*byte)(unsafe.Pointer(second.Addr() + 100)) = 0
+	report.Reset()
+	s.NoError(WriteReport(&report))
+	s.Contains(report.String(), "verify:\n\tgoumem: block canary corrupted: overflow")
+
+	s.ErrorIs(mem.Free(second), allocator.ErrCanaryCorrupted)
+}
+
+func (s *ReportTestSuite) TestReplacedAllocator() {
+	block, err := mem.Alloc(100)
+	if err != nil {
+		s.FailNow("Failed to allocate block", err)
+	}
+	checked := mem
+
+	SetMemoryAllocator(allocator.NewDefaultMemoryAllocator())
+	replaced, err := mem.Alloc(64)
+	if err != nil {
+		s.FailNow("Failed to allocate block", err)
+	}
+
+	var report bytes.Buffer
+	s.NoError(WriteReport(&report))
+
+	// every section describes the installed allocator, not the checkers it replaced
+	s.Contains(report.String(), "live        64 bytes in 1 blocks")
+	s.Contains(report.String(), "fragmentation:\n\tused        64 bytes in 1 blocks")
+	s.NotContains(report.String(), "top allocation sites")
+	s.Contains(report.String(), "verify:\n\tok")
+
+	s.NoError(mem.Free(replaced))
+	s.NoError(checked.Free(block))
+}
+
+func TestReportSuite(t *testing.T) {
+	suite.Run(t, new(ReportTestSuite))
+}
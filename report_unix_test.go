@@ -0,0 +1,48 @@
+//go:build unix
+
+package goumem
+
+import (
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func (s *ReportTestSuite) TestNotifyOnSignal() {
+	reports := make(chan string, 1)
+	stop := NotifyOnSignal(syscall.SIGUSR1, writerFunc(func(p []byte) (int, error) {
+		select {
+		case reports <- string(p):
+		default:
+		}
+		return len(p), nil
+	}))
+	defer stop()
+
+	s.NoError(syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+
+	select {
+	case report := <-reports:
+		s.True(strings.HasPrefix(report, "goumem report at "))
+	case <-time.After(5 * time.Second):
+		s.Fail("No report written")
+	}
+
+	// stopping twice, even concurrently, does not panic
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stop()
+		}()
+	}
+	wg.Wait()
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}